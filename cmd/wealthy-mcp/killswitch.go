@@ -0,0 +1,57 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package main
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wealthy/wealthy-mcp/internal/risk"
+	"github.com/wealthy/wealthy-mcp/internal/utils"
+)
+
+// killSwitchRequest is the optional body of POST /kill-switch.
+type killSwitchRequest struct {
+	Reason    string `json:"reason"`
+	SquareOff bool   `json:"square_off"`
+}
+
+// addKillSwitchRoutes serves the kill switch: GET reports its state, POST
+// engages it with the server login and DELETE re-arms it. POST and DELETE are
+// only served with an API key, without one any web page or local process
+// could cancel the user's orders or re-arm the switch. The rearm command is
+// left to re-arm it then.
+func addKillSwitchRoutes(router *gin.Engine, cfg config) {
+	group := router.Group("/kill-switch", mcpMiddleware(cfg)...)
+	group.GET("", func(c *gin.Context) {
+		c.JSON(http.StatusOK, risk.Switch.State())
+	})
+	if cfg.apiKey == "" {
+		slog.Info("no API key configured, the kill switch cannot be engaged or re-armed over HTTP")
+		return
+	}
+	group.POST("", func(c *gin.Context) {
+		var req killSwitchRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		if req.Reason == "" {
+			req.Reason = "engaged over HTTP"
+		}
+		c.JSON(http.StatusOK, risk.Switch.Engage(c.Request.Context(), utils.FalconService, req.Reason, req.SquareOff))
+	})
+	group.DELETE("", func(c *gin.Context) {
+		if err := risk.Switch.Rearm(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, risk.Switch.State())
+	})
+}
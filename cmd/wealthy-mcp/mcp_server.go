@@ -10,19 +10,70 @@ import (
 	"flag"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/wealthy/wealthy-mcp/internal"
+	"github.com/wealthy/wealthy-mcp/internal/alerts"
+	"github.com/wealthy/wealthy-mcp/internal/notify"
+	"github.com/wealthy/wealthy-mcp/internal/orders"
+	"github.com/wealthy/wealthy-mcp/internal/resources"
+	"github.com/wealthy/wealthy-mcp/internal/risk"
+	"github.com/wealthy/wealthy-mcp/internal/tokenstore"
+	"github.com/wealthy/wealthy-mcp/internal/transport"
+	"github.com/wealthy/wealthy-mcp/internal/utils"
+	"github.com/wealthy/wealthy-mcp/internal/websocket"
 	"github.com/wealthy/wealthy-mcp/tools"
 )
 
+const (
+	// passphraseEnv names the environment variable holding the token store passphrase.
+	passphraseEnv = "WEALTHY_MCP_TOKEN_PASSPHRASE"
+	// apiKeyEnv names the environment variable holding the API key of the HTTP transports.
+	apiKeyEnv = "WEALTHY_MCP_API_KEY"
+)
+
+// config holds the command line options of the server.
+type config struct {
+	transport   string
+	addr        string
+	publicURL   string
+	loginMode   string
+	apiKey      string
+	sessionAuth bool
+	dryRun      bool
+	riskLimits  string
+	killSwitch  string
+	alertsFile  string
+	alertHook   alerts.Hook
+	recordDir   string
+	logLevel    slog.Level
+}
+
+// callbackURL is the login redirect URL, reachable by the user's browser.
+func (c config) callbackURL() string {
+	base := "http://" + c.addr
+	if c.publicURL != "" {
+		base = strings.TrimSuffix(c.publicURL, "/")
+	}
+	return base + "/auth/callback"
+}
+
 func newServer() *server.MCPServer {
+	hooks := &server.Hooks{}
+	notify.Clients.Attach(hooks)
+	internal.Sessions.Attach(hooks)
 	s := server.NewMCPServer(
 		"wealthy-mcp",
 		"0.1.1",
+		server.WithHooks(hooks),
+		server.WithLogging(),
+		server.WithResourceCapabilities(true, false),
 	)
 
 	//add tools
@@ -32,7 +83,16 @@ func newServer() *server.MCPServer {
 	tools.AddOrderTool(s)
 	tools.AddWatchlistTool(s)
 	tools.AddPriceTool(s)
+	tools.AddIndicatorsTool(s)
 	tools.AddUserTool(s)
+	tools.AddAuthTool(s)
+	tools.AddResultsTool(s)
+	tools.AddRiskTool(s)
+	tools.AddKillSwitchTool(s)
+	tools.AddAlertTool(s)
+
+	//add resources
+	resources.Add(s, notify.Clients)
 
 	//register prompt
 	s.AddPrompt(placeOrderPrompt(), server.PromptHandlerFunc(placeOrderPromptHandler))
@@ -42,20 +102,82 @@ func newServer() *server.MCPServer {
 	return s
 }
 
-func newGinServer() *gin.Engine {
+func newGinServer(cfg config) *gin.Engine {
 	router := gin.Default()
 	router.GET("/health", healthHandler)
 	router.GET("/auth/callback/", internal.AuthHandler)
+	addKillSwitchRoutes(router, cfg)
 	return router
 }
 
-func run(transport, addr string, logLevel slog.Level) error {
-	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel})))
+func run(cfg config) error {
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: cfg.logLevel})))
 	s := newServer()
 
-	switch transport {
+	switch cfg.loginMode {
+	case internal.LoginModeBrowser:
+	case internal.LoginModeHeadless:
+		internal.LoginMode = internal.LoginModeHeadless
+		// Fail fast with login instructions instead of holding up tool calls
+		internal.LoginWaitTimeout = 10 * time.Second
+		if cfg.transport != "stdio" {
+			go internal.PromptForToken(context.Background(), os.Stdin)
+		}
+	default:
+		return fmt.Errorf("invalid login mode: %s. must be 'browser' or 'headless'", cfg.loginMode)
+	}
+
+	internal.Auth.SetCallbackURL(cfg.callbackURL())
+	orders.DryRun = cfg.dryRun
+	if cfg.riskLimits != "" {
+		limits, err := risk.Load(cfg.riskLimits)
+		if err != nil {
+			return err
+		}
+		risk.Current = limits
+		slog.Info("Risk limits loaded", "path", cfg.riskLimits)
+	}
+	ks, err := risk.OpenKillSwitch(cfg.killSwitch)
+	if err != nil {
+		return err
+	}
+	risk.Switch = ks
+	if state := ks.State(); state.Engaged {
+		slog.Warn("kill switch is engaged, orders are refused until it is re-armed", "reason", state.Reason, "since", state.EngagedAt)
+	}
+
+	if cfg.recordDir != "" {
+		rec, err := websocket.NewRecorder(cfg.recordDir)
+		if err != nil {
+			return err
+		}
+		defer rec.Close()
+		utils.Stream.SetRecorder(rec)
+		slog.Info("Recording the live feed", "dir", cfg.recordDir)
+	}
+
+	engine, err := alerts.Open(cfg.alertsFile, utils.Stream, utils.FalconService)
+	if err != nil {
+		return err
+	}
+	alerts.Current = engine
+
+	if !cfg.sessionAuth {
+		// The feed uses the server login, with per session logins its order
+		// updates belong to none of the clients
+		defer notify.ForwardOrders(notify.Clients, websocket.Orders)()
+		// Alerts are checked with the server login too
+		defer engine.Start()()
+		defer notify.ForwardAlerts(notify.Clients, engine)()
+		if cfg.alertHook.Enabled() {
+			defer cfg.alertHook.Forward(engine)()
+		}
+	}
+
+	addr := cfg.addr
+	switch cfg.transport {
 	case "stdio":
-		router := newGinServer()
+		router := newGinServer(cfg)
 		srv := server.NewStdioServer(s)
 
 		// Start HTTP server for auth in background
@@ -65,43 +187,88 @@ func run(transport, addr string, logLevel slog.Level) error {
 			}
 		}()
 
-		// Force user to login through browser
-		if internal.AuthStage == internal.AUTH_NOT_STARTED || internal.AuthStage == internal.AUTH_FAILED {
-			internal.BrowserLogin("http://" + addr + "/auth/callback")
-		}
+		// Reuse the stored token or force user to login
+		login(cfg)
 
 		slog.Info("Starting Wealthy MCP server using stdio transport")
-		return srv.Listen(context.Background(), os.Stdin, os.Stdout)
+		// The stdio session of mcp-go is always "stdio"
+		in, out := transport.InterceptStdio(os.Stdin, os.Stdout, "stdio", notify.Clients.Methods())
+		return srv.Listen(context.Background(), in, out)
 	case "sse":
-		router := newGinServer()
-		srv := server.NewSSEServer(s,
-			server.WithBasePath("/mcp"),
-		)
+		router := newGinServer(cfg)
+		opts := []server.SSEOption{server.WithBasePath("/mcp")}
+		if cfg.sessionAuth {
+			// Every client logs in with its own account, bound to its session ID
+			opts = append(opts, server.WithSSEContextFunc(func(ctx context.Context, r *http.Request) context.Context {
+				return utils.WithSession(ctx, r.URL.Query().Get("sessionId"))
+			}))
+		}
+		srv := server.NewSSEServer(s, opts...)
 
 		// Handle both the base path and wildcard paths
-		router.Any("/mcp", gin.WrapF(srv.ServeHTTP))
-		router.Any("/mcp/*path", gin.WrapF(srv.ServeHTTP))
-		// Force user to login through browser
-		var loginOnce sync.Once
-		loginOnce.Do(func() {
-			if internal.AuthStage == internal.AUTH_NOT_STARTED || internal.AuthStage == internal.AUTH_FAILED {
-				internal.BrowserLogin("http://" + addr + "/auth/callback")
-			}
-		})
+		mcpGroup := router.Group("/mcp", mcpMiddleware(cfg)...)
+		mcpGroup.Any("", gin.WrapF(srv.ServeHTTP))
+		mcpGroup.Any("/*path", gin.WrapF(srv.ServeHTTP))
+		// Reuse the stored token or force user to login
+		if !cfg.sessionAuth {
+			var loginOnce sync.Once
+			loginOnce.Do(func() {
+				login(cfg)
+			})
+		}
 		slog.Info("Starting Wealthy MCP server using SSE transport", "address", addr)
 		if err := router.Run(addr); err != nil {
 			return fmt.Errorf("HTTP server error: %v", err)
 		}
+	case "http":
+		router := newGinServer(cfg)
+		opts := []transport.StreamableOption{transport.WithMethods(notify.Clients.Methods())}
+		if cfg.sessionAuth {
+			// Every client logs in with its own account, bound to its session ID
+			opts = append(opts, transport.WithHTTPContextFunc(func(ctx context.Context, sessionID string, r *http.Request) context.Context {
+				return utils.WithSession(ctx, sessionID)
+			}))
+		}
+		srv := transport.NewStreamableHTTPServer(s, opts...)
+		defer srv.Close()
 
+		// Single endpoint for POST, GET and DELETE
+		router.Any("/mcp", append(mcpMiddleware(cfg), gin.WrapH(srv))...)
+		// Reuse the stored token or force user to login
+		if !cfg.sessionAuth {
+			login(cfg)
+		}
+		slog.Info("Starting Wealthy MCP server using Streamable HTTP transport", "address", addr)
+		if err := router.Run(addr); err != nil {
+			return fmt.Errorf("HTTP server error: %v", err)
+		}
 	default:
 		return fmt.Errorf(
-			"invalid transport type: %s. must be 'stdio' or 'sse'",
-			transport,
+			"invalid transport type: %s. must be 'stdio', 'sse' or 'http'",
+			cfg.transport,
 		)
 	}
 	return nil
 }
 
+// login restores a persisted auth token and falls back to a new login when
+// there is none or it has expired.
+func login(cfg config) {
+	if !internal.AuthRequired() || internal.LoadStoredToken() {
+		return
+	}
+	internal.StartLogin(cfg.callbackURL())
+}
+
+// mcpMiddleware guards the MCP endpoints of the HTTP transports.
+func mcpMiddleware(cfg config) []gin.HandlerFunc {
+	if cfg.apiKey == "" {
+		slog.Warn("no API key configured, anyone who can reach the server can use it", "env", apiKeyEnv)
+		return nil
+	}
+	return []gin.HandlerFunc{internal.APIKeyAuth(cfg.apiKey)}
+}
+
 // healthHandler responds with a simple health status
 func healthHandler(c *gin.Context) {
 	c.String(200, "OK")
@@ -109,27 +276,84 @@ func healthHandler(c *gin.Context) {
 
 func main() {
 	gin.SetMode(gin.ReleaseMode)
-	var transport string
+	var cfg config
 	var debug bool
-	flag.StringVar(&transport, "t", "stdio", "Transport type (stdio or sse)")
+	var tokenFile string
+	var logLevel string
+	flag.StringVar(&cfg.transport, "t", "stdio", "Transport type (stdio, sse or http)")
 	flag.StringVar(
-		&transport,
+		&cfg.transport,
 		"transport",
 		"stdio",
-		"Transport type (stdio or sse)",
+		"Transport type (stdio, sse or http)",
 	)
-	addr := flag.String("addr", "localhost:8004", "The host and port to start the sse server on")
-	logLevel := flag.String("log-level", "info", "Log level (debug, info, warn, error)")
-	flag.BoolVar(&debug, "debug", false, "Enable debug mode to save auth token to file")
+	flag.StringVar(&cfg.addr, "addr", "localhost:8004", "The host and port to start the sse or http server on")
+	flag.StringVar(&logLevel, "log-level", "info", "Log level (debug, info, warn, error)")
+	flag.StringVar(&cfg.loginMode, "login-mode", internal.LoginModeBrowser, "Login mode (browser or headless), headless prints the login link instead of opening a browser")
+	flag.StringVar(&cfg.publicURL, "public-url", "", "Base URL at which the login callback of this server is reachable (default: http://<addr>)")
+	flag.StringVar(&cfg.apiKey, "api-key", "", "API key clients must send as a bearer token to the /mcp endpoints (default: $"+apiKeyEnv+")")
+	flag.BoolVar(&cfg.sessionAuth, "session-auth", false, "Let every sse or http client log in with its own Wealthy account instead of sharing the server login")
+	flag.BoolVar(&cfg.dryRun, "dry-run", false, "Preview every order first, orders are only sent when confirmed with the preview's confirmation token")
+	flag.StringVar(&cfg.riskLimits, "risk-limits", "", "Path of a JSON file with the risk limits orders must stay within")
+	flag.StringVar(&cfg.killSwitch, "kill-switch-file", "", "Path of the kill switch state (default: <user config dir>/wealthy-mcp/killswitch.json)")
+	flag.StringVar(&cfg.alertsFile, "alerts-file", "", "Path of the price alerts (default: <user config dir>/wealthy-mcp/alerts.json)")
+	flag.StringVar(&cfg.alertHook.URL, "alert-webhook", "", "URL a fired price alert is POSTed to as JSON")
+	flag.StringVar(&cfg.alertHook.Command, "alert-command", "", "Program run with a fired price alert as JSON on its standard input")
+	flag.StringVar(&cfg.recordDir, "record-dir", "", "Directory to record the live feed to, one file per day, for replaying it offline")
+	flag.BoolVar(&debug, "debug", false, "Deprecated: auth tokens are always persisted to the encrypted token store")
+	flag.StringVar(&tokenFile, "token-file", "", "Path of the encrypted auth token store (default: <user config dir>/wealthy-mcp/token.enc)")
+	flag.Usage = usage
 	flag.Parse()
 
-	internal.DebugMode = debug
+	store, err := tokenstore.New(tokenFile, os.Getenv(passphraseEnv))
+	if err != nil {
+		slog.Warn("auth token persistence disabled", "error", err)
+	} else {
+		internal.TokenStore = store
+	}
 
-	if err := run(transport, *addr, parseLevel(*logLevel)); err != nil {
+	if flag.Arg(0) == "logout" {
+		if err := internal.Logout(); err != nil {
+			fmt.Fprintln(os.Stderr, "logout failed:", err)
+			os.Exit(1)
+		}
+		fmt.Fprintln(os.Stderr, "Logged out, stored auth token removed")
+		return
+	}
+
+	if flag.Arg(0) == "rearm" {
+		ks, err := risk.OpenKillSwitch(cfg.killSwitch)
+		if err == nil {
+			err = ks.Rearm()
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "rearm failed:", err)
+			os.Exit(1)
+		}
+		fmt.Fprintln(os.Stderr, "Kill switch re-armed, orders are accepted again, running servers pick it up on their next order")
+		return
+	}
+
+	cfg.logLevel = parseLevel(logLevel)
+	if cfg.apiKey == "" {
+		cfg.apiKey = os.Getenv(apiKeyEnv)
+	}
+	if err := run(cfg); err != nil {
 		panic(err)
 	}
 }
 
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] [logout|rearm]\n\n", os.Args[0])
+	fmt.Fprintln(out, "Commands:")
+	fmt.Fprintln(out, "  logout\tRemove the stored auth token and exit")
+	fmt.Fprintln(out, "  rearm\tRe-arm the kill switch so orders are accepted again, then exit")
+	fmt.Fprintln(out, "\nFlags:")
+	flag.PrintDefaults()
+	fmt.Fprintf(out, "\nSet %s to encrypt the token store with a passphrase instead of a machine derived key.\n", passphraseEnv)
+}
+
 func parseLevel(level string) slog.Level {
 	var l slog.Level
 	if err := l.UnmarshalText([]byte(level)); err != nil {
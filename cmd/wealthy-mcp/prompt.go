@@ -49,7 +49,7 @@ var (
 		2. For each holding:
    			- Perform a SWOT (Strengths, Weaknesses, Opportunities, Threats) analysis using up-to-date internet search.
    			- Assign a rating to each stock based on the SWOT analysis (e.g., Strong Buy, Buy, Hold, Sell, Strong Sell).
-		3. Use the "get_price" tool to retrieve the latest prices for all stocks in the portfolio to calculate the total value of the portfolio. Multiple stock symbols may be passed at once. if ltp is zero then check internet for latest price of the stock in NSE exchange
+		3. Use the "get_price" tool to retrieve the latest prices for all stocks in the portfolio to calculate the total value of the portfolio. Multiple stock symbols may be passed at once. If ltp is zero, use the "get_historical_prices" tool for the last close of the stock on NSE
 		4. Summarize the portfolio analysis with a brief overview highlighting strengths, weaknesses, and key insights.
 `
 )
@@ -62,7 +62,7 @@ func placeOrderPrompt() mcp.Prompt {
 		mcp.WithArgument("transaction_type", mcp.ArgumentDescription("1 for Buy, 2 for Sell"), mcp.RequiredArgument()),
 		mcp.WithArgument("price_type", mcp.ArgumentDescription("MKT(2) for market price, LMT(1) for limit price"), mcp.RequiredArgument()),
 		mcp.WithArgument("price", mcp.ArgumentDescription("Price for limit orders (required if price_type is LMT)")),
-		mcp.WithArgument("order_type", mcp.ArgumentDescription("1 for Market, 2 for Limit, 3 for Stop, 4 for Stop Limit"), mcp.RequiredArgument()),
+		mcp.WithArgument("order_type", mcp.ArgumentDescription("Product, 1 for CNC, 2 for MIS, 3 for NRML, 4 for COVER, 5 for BRACKET, 6 for MTF"), mcp.RequiredArgument()),
 	)
 }
 
@@ -0,0 +1,224 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package alerts watches the prices of instruments and fires alerts when they
+// reach the levels the user set, or when their candles meet a technical
+// condition.
+package alerts
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+	"github.com/wealthy/wealthy-mcp/internal/websocket"
+)
+
+// Condition is what an alert waits for.
+type Condition string
+
+const (
+	// Above fires when the last price is at or above the alert price.
+	Above Condition = "above"
+	// Below fires when the last price is at or below the alert price.
+	Below Condition = "below"
+	// Crossing fires when the last price moves through the alert price in
+	// either direction.
+	Crossing Condition = "crossing"
+	// PercentChange fires when the last price has moved by the alert percent
+	// from the reference price, up for a positive and down for a negative
+	// percent.
+	PercentChange Condition = "pct_change"
+	// Technical fires when the expression of the alert over prices and
+	// indicators holds at the close of a candle of its interval.
+	Technical Condition = "technical"
+)
+
+// defaultInterval is the candle interval technical alerts are evaluated on
+// when they do not name one.
+const defaultInterval = falcon.Interval5Minutes
+
+// Status is the stage of an alert.
+type Status string
+
+const (
+	StatusActive    Status = "active"
+	StatusTriggered Status = "triggered"
+)
+
+// Alert is a price level of an instrument the user wants to hear about. An
+// alert fires once, modifying it arms it again.
+type Alert struct {
+	ID            string    `json:"id"`
+	ExchangeName  int       `json:"exchange_name"`
+	Token         string    `json:"token"`
+	TradingSymbol string    `json:"trading_symbol"`
+	Condition     Condition `json:"condition"`
+	// Price is the level of the above, below and crossing conditions
+	Price falcon.Money `json:"price,omitempty"`
+	// Percent is the change of the pct_change condition
+	Percent float64 `json:"percent,omitempty"`
+	// Reference is the price pct_change measures from, the first price seen
+	// when it is not set
+	Reference falcon.Money `json:"reference_price,omitempty"`
+	// Expression is the condition of a technical alert, see Expression
+	Expression string `json:"expression,omitempty"`
+	// Interval is the candle interval a technical alert is evaluated on, and
+	// of the operands of its expression that do not name one
+	Interval  falcon.CandleInterval `json:"interval,omitempty"`
+	Note      string                `json:"note,omitempty"`
+	Status    Status                `json:"status"`
+	CreatedAt time.Time             `json:"created_at"`
+	// LastPrice is the price the alert was last checked against
+	LastPrice    falcon.Money `json:"last_price,omitempty"`
+	CheckedAt    time.Time    `json:"checked_at,omitempty"`
+	TriggeredAt  time.Time    `json:"triggered_at,omitempty"`
+	TriggerPrice falcon.Money `json:"trigger_price,omitempty"`
+	// CandleAt is the close of the candle a technical alert was last
+	// evaluated at, Values the operands of its expression then and Error
+	// why the evaluation failed
+	CandleAt time.Time          `json:"candle_at,omitempty"`
+	Values   map[string]float64 `json:"values,omitempty"`
+	Error    string             `json:"error,omitempty"`
+}
+
+// rearm clears what the checks recorded, so the alert starts over.
+func (a *Alert) rearm() {
+	a.LastPrice, a.CheckedAt, a.TriggeredAt, a.TriggerPrice = 0, time.Time{}, time.Time{}, 0
+	a.CandleAt, a.Values, a.Error = time.Time{}, nil, ""
+}
+
+// interval is the candle interval of a technical alert.
+func (a Alert) interval() falcon.CandleInterval {
+	if a.Interval == "" {
+		return defaultInterval
+	}
+	return a.Interval
+}
+
+// Validate checks that the alert names an instrument and a condition it can
+// be evaluated with.
+func (a *Alert) Validate() error {
+	if falcon.ExchangeName(a.ExchangeName) == "" {
+		return fmt.Errorf("invalid exchange %d, must be 1 (NSE), 2 (NFO), 3 (BSE) or 4 (BFO)", a.ExchangeName)
+	}
+	if a.Token == "" {
+		return errors.New("token is required")
+	}
+	if a.TradingSymbol == "" {
+		return errors.New("trading symbol is required")
+	}
+	switch a.Condition {
+	case Above, Below, Crossing:
+		if a.Price <= 0 {
+			return fmt.Errorf("a %s alert needs a price above zero", a.Condition)
+		}
+	case PercentChange:
+		if a.Percent == 0 || math.IsNaN(a.Percent) || math.IsInf(a.Percent, 0) {
+			return errors.New("a pct_change alert needs a non zero percent")
+		}
+		if a.Reference < 0 {
+			return errors.New("reference price cannot be negative")
+		}
+	case Technical:
+		if _, err := ParseExpression(a.Expression); err != nil {
+			return fmt.Errorf("invalid expression: %w", err)
+		}
+		// the alert is evaluated when the live feed completes a candle
+		if _, err := websocket.ParseInterval(string(a.interval())); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid condition %q, must be above, below, crossing, pct_change or technical", a.Condition)
+	}
+	return nil
+}
+
+// Describe is the condition of the alert in words, such as
+// "RELIANCE-EQ above 2500.00".
+func (a Alert) Describe() string {
+	switch a.Condition {
+	case PercentChange:
+		if a.Reference == 0 {
+			return fmt.Sprintf("%s %+.2f%%", a.TradingSymbol, a.Percent)
+		}
+		return fmt.Sprintf("%s %+.2f%% from %s", a.TradingSymbol, a.Percent, a.Reference)
+	case Technical:
+		return fmt.Sprintf("%s %s on %s candles", a.TradingSymbol, a.Expression, a.interval())
+	default:
+		return fmt.Sprintf("%s %s %s", a.TradingSymbol, a.Condition, a.Price)
+	}
+}
+
+// Event is published when an alert fires.
+type Event struct {
+	Alert   Alert        `json:"alert"`
+	Price   falcon.Money `json:"price"`
+	Message string       `json:"message"`
+}
+
+// check evaluates an active alert against a price and records the price. It
+// reports whether the alert fired.
+func (a *Alert) check(price falcon.Money, at time.Time) bool {
+	if a.Status != StatusActive || price <= 0 {
+		return false
+	}
+	last := a.LastPrice
+	a.LastPrice, a.CheckedAt = price, at
+
+	fired := false
+	switch a.Condition {
+	case Above:
+		fired = price >= a.Price
+	case Below:
+		fired = price <= a.Price
+	case Crossing:
+		// the first price only tells which side of the level we are on
+		fired = last != 0 && ((last < a.Price && price >= a.Price) || (last > a.Price && price <= a.Price))
+	case PercentChange:
+		if a.Reference == 0 {
+			a.Reference = price
+			return false
+		}
+		change := float64(price-a.Reference) / float64(a.Reference) * 100
+		fired = (a.Percent > 0 && change >= a.Percent) || (a.Percent < 0 && change <= a.Percent)
+	}
+	if fired {
+		a.Status, a.TriggeredAt, a.TriggerPrice = StatusTriggered, at, price
+	}
+	return fired
+}
+
+// message is the one line account of a fired alert for the user.
+func (a Alert) message() string {
+	var what string
+	switch a.Condition {
+	case Above:
+		what = fmt.Sprintf("is at or above %s", a.Price)
+	case Below:
+		what = fmt.Sprintf("is at or below %s", a.Price)
+	case Crossing:
+		what = fmt.Sprintf("crossed %s", a.Price)
+	case PercentChange:
+		change := float64(a.TriggerPrice-a.Reference) / float64(a.Reference) * 100
+		what = fmt.Sprintf("moved %+.2f%% from %s", change, a.Reference)
+	case Technical:
+		values := make([]string, 0, len(a.Values))
+		for name, v := range a.Values {
+			values = append(values, fmt.Sprintf("%s %.2f", name, v))
+		}
+		sort.Strings(values)
+		what = fmt.Sprintf("%s at the %s candle closing %s (%s)", a.Expression, a.interval(), a.TriggeredAt.In(falcon.IST).Format("15:04"), strings.Join(values, ", "))
+	}
+	msg := fmt.Sprintf("Alert %s: %s %s, last price %s", a.ID, a.TradingSymbol, what, a.TriggerPrice)
+	if a.Note != "" {
+		msg += " (" + a.Note + ")"
+	}
+	return msg
+}
@@ -0,0 +1,359 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+	"github.com/wealthy/wealthy-mcp/internal/websocket"
+)
+
+func TestAlertCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		alert  Alert
+		prices []float64
+		// fired is the index of the price that fires the alert, -1 for none
+		fired int
+	}{
+		{"above", Alert{Condition: Above, Price: falcon.Rupees(100)}, []float64{99, 99.95, 100}, 2},
+		{"above already", Alert{Condition: Above, Price: falcon.Rupees(100)}, []float64{101}, 0},
+		{"below", Alert{Condition: Below, Price: falcon.Rupees(100)}, []float64{101, 100.5, 99}, 2},
+		{"below never", Alert{Condition: Below, Price: falcon.Rupees(100)}, []float64{101, 100.05}, -1},
+		{"crossing up", Alert{Condition: Crossing, Price: falcon.Rupees(100)}, []float64{99, 99.5, 100.5}, 2},
+		{"crossing down", Alert{Condition: Crossing, Price: falcon.Rupees(100)}, []float64{101, 100}, 1},
+		{"crossing needs a side", Alert{Condition: Crossing, Price: falcon.Rupees(100)}, []float64{101, 102}, -1},
+		{"rise", Alert{Condition: PercentChange, Percent: 2}, []float64{100, 101, 102}, 2},
+		{"fall", Alert{Condition: PercentChange, Percent: -2}, []float64{100, 102, 98.5, 97.9}, 3},
+		{"fall from reference", Alert{Condition: PercentChange, Percent: -5, Reference: falcon.Rupees(200)}, []float64{190}, 0},
+		{"rise ignores a fall", Alert{Condition: PercentChange, Percent: 1}, []float64{100, 90}, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := tt.alert
+			a.Status = StatusActive
+			fired := -1
+			for i, p := range tt.prices {
+				if a.check(falcon.Rupees(p), time.Now()) {
+					fired = i
+					break
+				}
+			}
+			assert.Equal(t, tt.fired, fired)
+			if tt.fired >= 0 {
+				assert.Equal(t, StatusTriggered, a.Status)
+				assert.Equal(t, falcon.Rupees(tt.prices[tt.fired]), a.TriggerPrice)
+				assert.False(t, a.check(falcon.Rupees(tt.prices[tt.fired]), time.Now()), "an alert fires once")
+			}
+		})
+	}
+}
+
+func TestAlertValidate(t *testing.T) {
+	valid := Alert{ExchangeName: falcon.ExchangeNSE, Token: "2885", TradingSymbol: "RELIANCE-EQ", Condition: Above, Price: falcon.Rupees(2500)}
+	require.NoError(t, valid.Validate())
+
+	tests := []struct {
+		name   string
+		change func(*Alert)
+		err    string
+	}{
+		{"exchange", func(a *Alert) { a.ExchangeName = 9 }, "invalid exchange"},
+		{"token", func(a *Alert) { a.Token = "" }, "token is required"},
+		{"symbol", func(a *Alert) { a.TradingSymbol = "" }, "trading symbol is required"},
+		{"condition", func(a *Alert) { a.Condition = "near" }, "invalid condition"},
+		{"price", func(a *Alert) { a.Price = 0 }, "needs a price"},
+		{"percent", func(a *Alert) { a.Condition = PercentChange }, "non zero percent"},
+		{"expression", func(a *Alert) { a.Condition = Technical; a.Expression = "rsi(14) <" }, "invalid expression"},
+		{"interval", func(a *Alert) {
+			a.Condition = Technical
+			a.Expression = "rsi(14) < 30"
+			a.Interval = falcon.Interval1Day
+		}, "invalid interval"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := valid
+			tt.change(&a)
+			assert.ErrorContains(t, a.Validate(), tt.err)
+		})
+	}
+}
+
+func TestEnginePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alerts.json")
+	e, err := Open(path, nil, nil)
+	require.NoError(t, err)
+
+	a, err := e.Create(Alert{ExchangeName: falcon.ExchangeNSE, Token: "2885", TradingSymbol: "RELIANCE-EQ", Condition: Above, Price: falcon.Rupees(2500), Note: "breakout"})
+	require.NoError(t, err)
+	assert.Equal(t, "1", a.ID)
+	assert.Equal(t, StatusActive, a.Status)
+	b, err := e.Create(Alert{ExchangeName: falcon.ExchangeNSE, Token: "1594", TradingSymbol: "INFY-EQ", Condition: PercentChange, Percent: -3})
+	require.NoError(t, err)
+	_, err = e.Create(Alert{ExchangeName: falcon.ExchangeNSE, Token: "1594", TradingSymbol: "INFY-EQ", Condition: Below})
+	assert.ErrorContains(t, err, "needs a price")
+
+	_, err = e.Modify(a.ID, func(a *Alert) { a.Price = falcon.Rupees(2600) })
+	require.NoError(t, err)
+	_, err = e.Modify(a.ID, func(a *Alert) { a.Condition = "near" })
+	assert.ErrorContains(t, err, "invalid condition")
+	_, err = e.Modify("9", func(*Alert) {})
+	assert.ErrorContains(t, err, "not found")
+
+	reopened, err := Open(path, nil, nil)
+	require.NoError(t, err)
+	list := reopened.List()
+	require.Len(t, list, 2)
+	assert.Equal(t, falcon.Rupees(2600), list[0].Price)
+	assert.Equal(t, "breakout", list[0].Note)
+	assert.Equal(t, b.ID, list[1].ID)
+
+	require.NoError(t, reopened.Delete(a.ID))
+	assert.ErrorContains(t, reopened.Delete(a.ID), "not found")
+	c, err := reopened.Create(Alert{ExchangeName: falcon.ExchangeBSE, Token: "500325", TradingSymbol: "RELIANCE", Condition: Crossing, Price: falcon.Rupees(2500)})
+	require.NoError(t, err)
+	assert.Equal(t, "3", c.ID, "IDs are not reused")
+
+	reopened, err = Open(path, nil, nil)
+	require.NoError(t, err)
+	_, ok := reopened.Get(a.ID)
+	assert.False(t, ok)
+	assert.Len(t, reopened.List(), 2)
+}
+
+type failingSource struct{}
+
+func (failingSource) GetWebsocketURL(ctx context.Context) (string, error) {
+	return "", errors.New("offline")
+}
+
+type stubQuoter struct {
+	mu      sync.Mutex
+	quotes  falcon.Quotes
+	reqs    [][]string
+	candles map[falcon.CandleInterval]falcon.Candles
+}
+
+func (q *stubQuoter) GetPrice(ctx context.Context, req *falcon.PriceReq) (falcon.Quotes, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.reqs = append(q.reqs, req.Symbols)
+	return q.quotes, nil
+}
+
+func (q *stubQuoter) GetHistoricalCandles(ctx context.Context, exchange int, token string, interval falcon.CandleInterval, from, to time.Time) (falcon.Candles, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	candles := falcon.Candles{}
+	for _, c := range q.candles[interval] {
+		if !c.Time.Before(from) && !c.Time.After(to) {
+			candles = append(candles, c)
+		}
+	}
+	return candles, nil
+}
+
+func newStream(t *testing.T) (*websocket.StreamManager, *websocket.PriceStore) {
+	store := websocket.NewPriceStore()
+	stream := websocket.NewStreamManager(failingSource{}, websocket.WithPriceStore(store), websocket.WithBackoff(time.Hour, time.Hour))
+	t.Cleanup(stream.Stop)
+	return stream, store
+}
+
+func nextEvent(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(2 * time.Second):
+		t.Fatal("no alert event")
+		return Event{}
+	}
+}
+
+func TestEngineLiveTicks(t *testing.T) {
+	stream, store := newStream(t)
+	e := New(stream, &stubQuoter{})
+	e.pollInterval = time.Hour
+	a, err := e.Create(Alert{ExchangeName: falcon.ExchangeNSE, Token: "2885", TradingSymbol: "RELIANCE-EQ", Condition: Crossing, Price: falcon.Rupees(2500), Note: "breakout"})
+	require.NoError(t, err)
+	events, cancel := e.Watch()
+	defer cancel()
+	stop := e.Start()
+	defer stop()
+
+	instr := websocket.Instrument{Exchange: falcon.ExchangeNSE, Token: "2885"}
+	assert.Equal(t, []websocket.Instrument{instr}, stream.Subscriptions()[websocket.ModeLTPC])
+
+	store.Update(&websocket.Feed{Exchange: 1, Token: 2885, Live: true, Ltpc: &websocket.LTPC{Ltp: 249000}}, time.Now())
+	// crossing needs a price below the level first
+	require.Eventually(t, func() bool {
+		got, _ := e.Get(a.ID)
+		return got.LastPrice == falcon.Rupees(2490)
+	}, 2*time.Second, time.Millisecond)
+	store.Update(&websocket.Feed{Exchange: 1, Token: 2885, Live: true, Ltpc: &websocket.LTPC{Ltp: 250150}}, time.Now())
+	event := nextEvent(t, events)
+	assert.Equal(t, a.ID, event.Alert.ID)
+	assert.Equal(t, falcon.Rupees(2501.50), event.Price)
+	assert.Equal(t, "Alert 1: RELIANCE-EQ crossed 2500.00, last price 2501.50 (breakout)", event.Message)
+
+	got, _ := e.Get(a.ID)
+	assert.Equal(t, StatusTriggered, got.Status)
+	assert.Empty(t, stream.Subscriptions(), "the instrument is released once no alert is active")
+
+	_, err = e.Modify(a.ID, func(a *Alert) { a.Condition = Below; a.Price = falcon.Rupees(2450) })
+	require.NoError(t, err)
+	assert.Len(t, stream.Subscriptions()[websocket.ModeLTPC], 1, "a modified alert is armed again")
+	store.Update(&websocket.Feed{Exchange: 1, Token: 2885, Live: true, Ltpc: &websocket.LTPC{Ltp: 244000}}, time.Now())
+	event = nextEvent(t, events)
+	assert.Equal(t, "Alert 1: RELIANCE-EQ is at or below 2450.00, last price 2440.00 (breakout)", event.Message)
+}
+
+func TestEnginePolls(t *testing.T) {
+	stream, store := newStream(t)
+	quoter := &stubQuoter{quotes: falcon.Quotes{"nse:INFY-EQ": {LastPrice: falcon.Rupees(1450)}}}
+	e := New(stream, quoter)
+	e.pollInterval = 20 * time.Millisecond
+	_, err := e.Create(Alert{ExchangeName: falcon.ExchangeNSE, Token: "1594", TradingSymbol: "INFY-EQ", Condition: Below, Price: falcon.Rupees(1500)})
+	require.NoError(t, err)
+	_, err = e.Create(Alert{ExchangeName: falcon.ExchangeNSE, Token: "2885", TradingSymbol: "RELIANCE-EQ", Condition: Above, Price: falcon.Rupees(3000)})
+	require.NoError(t, err)
+	// RELIANCE-EQ streams, so only INFY-EQ is polled
+	go func() {
+		for i := 0; i < 20; i++ {
+			store.Update(&websocket.Feed{Exchange: 1, Token: 2885, Live: true, Ltpc: &websocket.LTPC{Ltp: 250000}}, time.Now())
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+	events, cancel := e.Watch()
+	defer cancel()
+	stop := e.Start()
+	defer stop()
+
+	event := nextEvent(t, events)
+	assert.Equal(t, "INFY-EQ", event.Alert.TradingSymbol)
+	assert.Equal(t, falcon.Rupees(1450), event.Price)
+	quoter.mu.Lock()
+	defer quoter.mu.Unlock()
+	assert.Equal(t, []string{"nse:INFY-EQ"}, quoter.reqs[0])
+}
+
+func TestEngineReplay(t *testing.T) {
+	dir := t.TempDir()
+	rec, err := websocket.NewRecorder(dir)
+	require.NoError(t, err)
+	start := time.Date(2025, 7, 1, 9, 15, 0, 0, time.Local)
+	for i, ltp := range []uint32{249000, 249500, 250500, 251000} {
+		feed := &websocket.Feed{Exchange: 1, Token: 2885, Live: true, Ltpc: &websocket.LTPC{Ltp: ltp}}
+		require.NoError(t, rec.Record(&websocket.Message{Data: &websocket.Message_Feed{Feed: feed}}, start.Add(time.Duration(i)*time.Second)))
+	}
+	require.NoError(t, rec.Close())
+
+	stream, _ := newStream(t)
+	e := New(stream, &stubQuoter{})
+	e.pollInterval = time.Hour
+	_, err = e.Create(Alert{ExchangeName: falcon.ExchangeNSE, Token: "2885", TradingSymbol: "RELIANCE-EQ", Condition: Above, Price: falcon.Rupees(2500)})
+	require.NoError(t, err)
+	events, cancel := e.Watch()
+	defer cancel()
+	stop := e.Start()
+	defer stop()
+
+	// a session replayed at 100 times the speed
+	require.NoError(t, stream.ReplayFile(context.Background(), websocket.RecordingPath(dir, start), 100))
+	event := nextEvent(t, events)
+	assert.Equal(t, falcon.Rupees(2505), event.Price)
+	assert.True(t, start.Add(2*time.Second).Equal(event.Alert.TriggeredAt), "the alert fires at the recorded time")
+}
+
+func TestHookFire(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	event := Event{Alert: Alert{ID: "1", TradingSymbol: "RELIANCE-EQ"}, Price: falcon.Rupees(2501.5), Message: "Alert 1"}
+	require.NoError(t, Hook{URL: srv.URL}.Fire(context.Background(), event))
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(body, &got))
+	assert.Equal(t, "Alert 1", got["message"])
+	assert.Equal(t, 2501.5, got["price"], "prices are sent in rupees")
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	assert.ErrorContains(t, Hook{URL: failing.URL}.Fire(context.Background(), event), "502")
+	assert.Error(t, Hook{Command: filepath.Join(t.TempDir(), "missing")}.Fire(context.Background(), event))
+}
+
+func TestEngineTechnical(t *testing.T) {
+	store, builder := websocket.NewPriceStore(), websocket.NewCandleBuilder(100)
+	stream := websocket.NewStreamManager(failingSource{}, websocket.WithPriceStore(store), websocket.WithCandleBuilder(builder), websocket.WithBackoff(time.Hour, time.Hour))
+	t.Cleanup(stream.Stop)
+	// a fall from 130 to 101 over the morning
+	open := time.Date(2025, 7, 1, 9, 15, 0, 0, falcon.IST)
+	quoter := &stubQuoter{candles: map[falcon.CandleInterval]falcon.Candles{}}
+	for i := 0; i < 30; i++ {
+		price := falcon.Rupees(float64(130 - i))
+		quoter.candles[falcon.Interval5Minutes] = append(quoter.candles[falcon.Interval5Minutes], falcon.Candle{Time: open.Add(time.Duration(i) * 5 * time.Minute), Open: price, High: price, Low: price, Close: price, Volume: 100})
+	}
+	e := New(stream, quoter)
+	e.pollInterval = time.Hour
+	a, err := e.Create(Alert{ExchangeName: falcon.ExchangeNSE, Token: "2885", TradingSymbol: "RELIANCE-EQ", Condition: Technical, Expression: "close crosses_above sma(5)"})
+	require.NoError(t, err)
+	events, cancel := e.Watch()
+	defer cancel()
+	stop := e.Start()
+	defer stop()
+	assert.Equal(t, []websocket.Instrument{{Exchange: falcon.ExchangeNSE, Token: "2885"}}, stream.Subscriptions()[websocket.ModeFull])
+
+	tick := func(at time.Time, ltp float64) {
+		builder.Add(websocket.Tick{Exchange: falcon.ExchangeNSE, Token: "2885", LastPrice: falcon.Rupees(ltp), Live: true, UpdatedAt: at})
+		store.Update(&websocket.Feed{Exchange: 1, Token: 2885, Live: true, Ltpc: &websocket.LTPC{Ltp: uint32(ltp * 100)}}, at)
+	}
+	// the first live candle evaluates the morning, still falling
+	liveOpen := open.Add(150 * time.Minute)
+	tick(liveOpen, 120)
+	require.Eventually(t, func() bool {
+		got, _ := e.Get(a.ID)
+		return got.CandleAt.Equal(liveOpen)
+	}, 2*time.Second, time.Millisecond)
+	got, _ := e.Get(a.ID)
+	assert.Equal(t, StatusActive, got.Status)
+	assert.Equal(t, map[string]float64{"close": 101, "sma(5)": 103}, got.Values)
+
+	// the candle closing at 11:50 jumps above the average
+	tick(liveOpen.Add(5*time.Minute), 121)
+	event := nextEvent(t, events)
+	assert.Equal(t, falcon.Rupees(120), event.Price)
+	assert.Equal(t, map[string]float64{"close": 120, "sma(5)": 106}, event.Alert.Values)
+	assert.Equal(t, "Alert 1: RELIANCE-EQ close crosses_above sma(5) at the 5m candle closing 11:50 (close 120.00, sma(5) 106.00), last price 120.00", event.Message)
+	assert.True(t, liveOpen.Add(5*time.Minute).Equal(event.Alert.TriggeredAt))
+	assert.Empty(t, stream.Subscriptions())
+
+	_, err = e.Modify(a.ID, func(a *Alert) { a.Expression = "rsi(14,1d) < 30" })
+	require.NoError(t, err)
+	tick(liveOpen.Add(10*time.Minute), 122)
+	require.Eventually(t, func() bool {
+		got, _ := e.Get(a.ID)
+		return got.Error != ""
+	}, 2*time.Second, time.Millisecond)
+	got, _ = e.Get(a.ID)
+	assert.Equal(t, "rsi(14,1d) needs more 1d candles than the 0 available", got.Error)
+	assert.Equal(t, StatusActive, got.Status)
+}
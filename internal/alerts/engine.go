@@ -0,0 +1,598 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+	"github.com/wealthy/wealthy-mcp/internal/indicators"
+	"github.com/wealthy/wealthy-mcp/internal/utils"
+	"github.com/wealthy/wealthy-mcp/internal/websocket"
+)
+
+const (
+	alertsFileName = "alerts.json"
+
+	// defaultPollInterval is how often the prices of instruments without live
+	// ticks are fetched
+	defaultPollInterval = 30 * time.Second
+	// pollTimeout bounds one price request of the polling fallback
+	pollTimeout = 10 * time.Second
+)
+
+// Quoter fetches prices and the candles of technical alerts, FalconService
+// does so for the server login.
+type Quoter interface {
+	GetPrice(ctx context.Context, req *falcon.PriceReq) (falcon.Quotes, error)
+	indicators.History
+}
+
+// Current is the alert engine of the server. It is kept in memory only until
+// main opens the persisted one.
+var Current = New(utils.Stream, utils.FalconService)
+
+// stored is the layout of the alerts file.
+type stored struct {
+	NextID int      `json:"next_id"`
+	Alerts []record `json:"alerts"`
+}
+
+// record is an alert as saved. Money is written in rupees and read in paisa,
+// so the prices are saved in paisa to read them back.
+type record struct {
+	Alert
+	Price        int64 `json:"price,omitempty"`
+	Reference    int64 `json:"reference_price,omitempty"`
+	LastPrice    int64 `json:"last_price,omitempty"`
+	TriggerPrice int64 `json:"trigger_price,omitempty"`
+}
+
+// Engine keeps the alerts and checks them against every live tick of their
+// instruments. Instruments without ticks, such as when the stream is down,
+// are checked by polling their price instead. Technical alerts are evaluated
+// whenever the live feed completes a candle of their interval.
+type Engine struct {
+	path         string
+	stream       *websocket.StreamManager
+	quotes       Quoter
+	pollInterval time.Duration
+
+	mu     sync.Mutex
+	alerts map[string]*Alert
+	nextID int
+	// subs holds the instruments of the active alerts on the stream while
+	// the engine runs
+	subs      map[websocket.Instrument]*websocket.Subscription
+	cancel    context.CancelFunc
+	watchers  map[int]chan Event
+	nextWatch int
+}
+
+// DefaultPath returns the alerts file location under the user config
+// directory.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve config dir: %w", err)
+	}
+	return filepath.Join(dir, "wealthy-mcp", alertsFileName), nil
+}
+
+// New creates an engine that keeps its alerts in memory only.
+func New(stream *websocket.StreamManager, quotes Quoter) *Engine {
+	return &Engine{
+		stream:       stream,
+		quotes:       quotes,
+		pollInterval: defaultPollInterval,
+		alerts:       make(map[string]*Alert),
+		nextID:       1,
+		subs:         make(map[websocket.Instrument]*websocket.Subscription),
+		watchers:     make(map[int]chan Event),
+	}
+}
+
+// Open restores the alerts persisted at path. An empty path selects
+// DefaultPath.
+func Open(path string, stream *websocket.StreamManager, quotes Quoter) (*Engine, error) {
+	if path == "" {
+		p, err := DefaultPath()
+		if err != nil {
+			return nil, err
+		}
+		path = p
+	}
+	e := New(stream, quotes)
+	e.path = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return e, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alerts: %w", err)
+	}
+	var s stored
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse alerts %s: %w", path, err)
+	}
+	for _, r := range s.Alerts {
+		a := r.Alert
+		a.Price, a.Reference = falcon.Paisa(r.Price), falcon.Paisa(r.Reference)
+		a.LastPrice, a.TriggerPrice = falcon.Paisa(r.LastPrice), falcon.Paisa(r.TriggerPrice)
+		e.alerts[a.ID] = &a
+	}
+	e.nextID = max(s.NextID, 1)
+	return e, nil
+}
+
+// Create adds an active alert and returns it with its ID.
+func (e *Engine) Create(a Alert) (Alert, error) {
+	if err := a.Validate(); err != nil {
+		return Alert{}, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	a.ID = strconv.Itoa(e.nextID)
+	a.Status, a.CreatedAt = StatusActive, time.Now().UTC()
+	a.rearm()
+	e.alerts[a.ID] = &a
+	e.nextID++
+	if err := e.save(); err != nil {
+		delete(e.alerts, a.ID)
+		e.nextID--
+		return Alert{}, err
+	}
+	e.syncLocked()
+	return a, nil
+}
+
+// Get returns an alert by ID.
+func (e *Engine) Get(id string) (Alert, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	a, ok := e.alerts[id]
+	if !ok {
+		return Alert{}, false
+	}
+	return *a, true
+}
+
+// List returns the alerts in the order they were created.
+func (e *Engine) List() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	alerts := make([]Alert, 0, len(e.alerts))
+	for _, a := range e.alerts {
+		alerts = append(alerts, *a)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if !alerts[i].CreatedAt.Equal(alerts[j].CreatedAt) {
+			return alerts[i].CreatedAt.Before(alerts[j].CreatedAt)
+		}
+		return alerts[i].ID < alerts[j].ID
+	})
+	return alerts
+}
+
+// Modify applies change to an alert and arms it again, so a triggered alert
+// fires once more.
+func (e *Engine) Modify(id string, change func(*Alert)) (Alert, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	old, ok := e.alerts[id]
+	if !ok {
+		return Alert{}, fmt.Errorf("alert %s not found", id)
+	}
+	a := *old
+	change(&a)
+	a.ID, a.CreatedAt = old.ID, old.CreatedAt
+	if err := a.Validate(); err != nil {
+		return Alert{}, err
+	}
+	a.Status = StatusActive
+	a.rearm()
+	e.alerts[id] = &a
+	if err := e.save(); err != nil {
+		e.alerts[id] = old
+		return Alert{}, err
+	}
+	e.syncLocked()
+	return a, nil
+}
+
+// Delete removes an alert.
+func (e *Engine) Delete(id string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	a, ok := e.alerts[id]
+	if !ok {
+		return fmt.Errorf("alert %s not found", id)
+	}
+	delete(e.alerts, id)
+	if err := e.save(); err != nil {
+		e.alerts[id] = a
+		return err
+	}
+	e.syncLocked()
+	return nil
+}
+
+// Watch returns a channel receiving every subsequent alert event and a
+// function to cancel the watch. Slow watchers miss events rather than
+// blocking the engine.
+func (e *Engine) Watch() (<-chan Event, func()) {
+	ch := make(chan Event, 32)
+	e.mu.Lock()
+	id := e.nextWatch
+	e.nextWatch++
+	e.watchers[id] = ch
+	e.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			e.mu.Lock()
+			delete(e.watchers, id)
+			e.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Start subscribes the instruments of the active alerts and checks the
+// alerts in the background. It returns the function that stops the engine.
+// The stream uses the server login, so the engine is not started for session
+// logins.
+func (e *Engine) Start() func() {
+	e.mu.Lock()
+	if e.cancel != nil {
+		e.mu.Unlock()
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	e.cancel = cancel
+	e.syncLocked()
+	e.mu.Unlock()
+	go e.run(ctx, done)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			<-done
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			e.cancel = nil
+			for instr, sub := range e.subs {
+				sub.Close()
+				delete(e.subs, instr)
+			}
+		})
+	}
+}
+
+// run checks the alerts on every change of the price store, and polls the
+// prices the stream does not deliver.
+func (e *Engine) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(e.pollInterval)
+	defer ticker.Stop()
+	prices := e.stream.Prices()
+	for {
+		changed := prices.Changed()
+		e.checkLive(prices)
+		e.checkTechnical(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+		case <-ticker.C:
+			e.poll(ctx)
+		}
+	}
+}
+
+// checkLive checks the active alerts against the ticks they have not seen.
+func (e *Engine) checkLive(prices *websocket.PriceStore) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var events []Event
+	dirty := false
+	for _, a := range e.alerts {
+		if a.Status != StatusActive {
+			continue
+		}
+		tick, ok := prices.Get(a.ExchangeName, a.Token)
+		if !ok || !tick.UpdatedAt.After(a.CheckedAt) {
+			continue
+		}
+		events, dirty = e.checkLocked(a, tick.LastPrice, tick.UpdatedAt, events, dirty)
+	}
+	e.finishLocked(events, dirty)
+}
+
+// poll fetches the prices of the instruments of active alerts that had no
+// tick for a poll interval, and checks their alerts.
+func (e *Engine) poll(ctx context.Context) {
+	now := time.Now()
+	prices := e.stream.Prices()
+	e.mu.Lock()
+	seen := make(map[string]bool)
+	var symbols []string
+	for _, a := range e.alerts {
+		if a.Status != StatusActive {
+			continue
+		}
+		if tick, ok := prices.Get(a.ExchangeName, a.Token); ok && now.Sub(tick.UpdatedAt) < e.pollInterval {
+			continue
+		}
+		symbol := falcon.QuoteSymbol(a.ExchangeName, a.TradingSymbol)
+		if !seen[symbol] {
+			seen[symbol] = true
+			symbols = append(symbols, symbol)
+		}
+	}
+	e.mu.Unlock()
+	if len(symbols) == 0 {
+		return
+	}
+	sort.Strings(symbols)
+
+	ctx, cancel := context.WithTimeout(ctx, pollTimeout)
+	defer cancel()
+	quotes, err := e.quotes.GetPrice(ctx, &falcon.PriceReq{Symbols: symbols})
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Warn("failed to poll prices for alerts", "error", err)
+		}
+		return
+	}
+
+	at := time.Now().UTC()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var events []Event
+	dirty := false
+	for _, a := range e.alerts {
+		symbol := falcon.QuoteSymbol(a.ExchangeName, a.TradingSymbol)
+		if a.Status != StatusActive || !seen[symbol] {
+			continue
+		}
+		q, ok := quotes.Lookup(symbol)
+		if !ok {
+			continue
+		}
+		events, dirty = e.checkLocked(a, q.LastPrice, at, events, dirty)
+	}
+	e.finishLocked(events, dirty)
+}
+
+// checkTechnical evaluates the technical alerts whose instrument started a
+// new candle of their interval since they were last evaluated, on the
+// candles completed before it.
+func (e *Engine) checkTechnical(ctx context.Context) {
+	type due struct {
+		alert Alert
+		at    time.Time
+	}
+	candles := e.stream.Candles()
+	e.mu.Lock()
+	var evaluate []due
+	for _, a := range e.alerts {
+		if a.Status != StatusActive || a.Condition != Technical {
+			continue
+		}
+		size, err := websocket.ParseInterval(string(a.interval()))
+		if err != nil {
+			continue
+		}
+		last := candles.Get(a.ExchangeName, a.Token, size, 1)
+		if len(last) == 1 && last[0].Start.After(a.CandleAt) {
+			evaluate = append(evaluate, due{*a, last[0].Start})
+		}
+	}
+	e.mu.Unlock()
+
+	type result struct {
+		due
+		fired  bool
+		close  float64
+		values map[string]float64
+		err    error
+	}
+	results := make([]result, 0, len(evaluate))
+	for _, d := range evaluate {
+		r := result{due: d}
+		r.fired, r.close, r.values, r.err = e.evaluate(ctx, d.alert, d.at)
+		if ctx.Err() != nil {
+			return
+		}
+		results = append(results, r)
+	}
+	if len(results) == 0 {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var events []Event
+	for _, r := range results {
+		a, ok := e.alerts[r.alert.ID]
+		// skip alerts changed while they were evaluated
+		if !ok || a.Status != StatusActive || !a.CandleAt.Equal(r.alert.CandleAt) || a.Expression != r.alert.Expression || a.Interval != r.alert.Interval {
+			continue
+		}
+		a.CandleAt, a.Values, a.Error = r.at, r.values, ""
+		if r.err != nil {
+			a.Error = r.err.Error()
+			slog.Warn("failed to evaluate alert", "id", a.ID, "alert", a.Describe(), "error", r.err)
+			continue
+		}
+		if r.fired {
+			price := falcon.Rupees(r.close)
+			a.Status, a.TriggeredAt, a.TriggerPrice = StatusTriggered, r.at, price
+			events = append(events, Event{Alert: *a, Price: price, Message: a.message()})
+		}
+	}
+	e.finishLocked(events, true)
+}
+
+// evaluate evaluates the expression of a technical alert on the candles
+// started before at. It returns whether the expression holds, the close of
+// the last candle and the values of the operands.
+func (e *Engine) evaluate(ctx context.Context, a Alert, at time.Time) (bool, float64, map[string]float64, error) {
+	x, err := ParseExpression(a.Expression)
+	if err != nil {
+		return false, 0, nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, pollTimeout)
+	defer cancel()
+	bars := make(map[falcon.CandleInterval][]indicators.Bar)
+	for _, interval := range append(x.Intervals(a.interval()), a.interval()) {
+		if _, ok := bars[interval]; ok {
+			continue
+		}
+		// the candle starting at at is still forming
+		b, err := indicators.Load(ctx, e.quotes, e.stream.Candles(), a.ExchangeName, a.Token, interval, at.Add(-time.Nanosecond))
+		if err != nil {
+			return false, 0, nil, fmt.Errorf("failed to load %s candles: %w", interval, err)
+		}
+		bars[interval] = b
+	}
+	size, _ := websocket.ParseInterval(string(a.interval()))
+	fired, values, err := x.Eval(bars, a.interval(), at, at.Add(-size))
+	if err != nil {
+		return false, 0, values, err
+	}
+	trigger := bars[a.interval()]
+	n := len(trigger)
+	for n > 0 && !trigger[n-1].Time.Before(at) {
+		n--
+	}
+	if n == 0 {
+		return false, 0, values, fmt.Errorf("no %s candle before %s", a.interval(), at.Format(time.RFC3339))
+	}
+	return fired, trigger[n-1].Close, values, nil
+}
+
+// checkLocked checks an alert against a price, collecting the event if it
+// fired. dirty reports whether an alert changed in a way worth saving.
+// Callers must hold e.mu.
+func (e *Engine) checkLocked(a *Alert, price falcon.Money, at time.Time, events []Event, dirty bool) ([]Event, bool) {
+	reference := a.Reference
+	if a.check(price, at) {
+		events = append(events, Event{Alert: *a, Price: price, Message: a.message()})
+		return events, true
+	}
+	return events, dirty || a.Reference != reference
+}
+
+// finishLocked saves the checked alerts and publishes the events. Callers
+// must hold e.mu.
+func (e *Engine) finishLocked(events []Event, dirty bool) {
+	if !dirty {
+		return
+	}
+	if err := e.save(); err != nil {
+		slog.Error("failed to persist alerts", "error", err)
+	}
+	if len(events) > 0 {
+		e.syncLocked()
+	}
+	for _, event := range events {
+		slog.Info("alert triggered", "id", event.Alert.ID, "alert", event.Alert.Describe(), "price", event.Price)
+		for _, ch := range e.watchers {
+			select {
+			case ch <- event:
+			default:
+			}
+		}
+	}
+}
+
+// syncLocked holds the instruments of the active alerts on the stream and
+// releases the others. Callers must hold e.mu.
+func (e *Engine) syncLocked() {
+	if e.cancel == nil {
+		return
+	}
+	// technical alerts stream the volume of the candles too
+	wanted := make(map[websocket.Instrument]int)
+	for _, a := range e.alerts {
+		if a.Status != StatusActive {
+			continue
+		}
+		instr := websocket.Instrument{Exchange: a.ExchangeName, Token: a.Token}
+		mode := websocket.ModeLTPC
+		if a.Condition == Technical {
+			mode = websocket.ModeFull
+		}
+		wanted[instr] = max(wanted[instr], mode)
+	}
+	for instr, sub := range e.subs {
+		if sub.Mode() != wanted[instr] {
+			sub.Close()
+			delete(e.subs, instr)
+		}
+	}
+	for instr, mode := range wanted {
+		if _, ok := e.subs[instr]; ok {
+			continue
+		}
+		sub, err := e.stream.Subscribe(mode, instr)
+		if err != nil {
+			// polling covers the instrument
+			slog.Warn("failed to stream prices for alert", "exchange", instr.Exchange, "token", instr.Token, "error", err)
+			continue
+		}
+		e.subs[instr] = sub
+	}
+	if len(e.subs) > 0 {
+		e.stream.Start()
+	}
+}
+
+// save writes the alerts to the alerts file. Callers must hold e.mu.
+func (e *Engine) save() error {
+	if e.path == "" {
+		return nil
+	}
+	s := stored{NextID: e.nextID, Alerts: make([]record, 0, len(e.alerts))}
+	for _, a := range e.alerts {
+		s.Alerts = append(s.Alerts, record{Alert: *a, Price: a.Price.Paisa(), Reference: a.Reference.Paisa(),
+			LastPrice: a.LastPrice.Paisa(), TriggerPrice: a.TriggerPrice.Paisa()})
+	}
+	sort.Slice(s.Alerts, func(i, j int) bool {
+		return s.Alerts[i].CreatedAt.Before(s.Alerts[j].CreatedAt)
+	})
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize alerts: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(e.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create alerts dir: %w", err)
+	}
+	tmp := e.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write alerts: %w", err)
+	}
+	if err := os.Rename(tmp, e.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace alerts: %w", err)
+	}
+	return nil
+}
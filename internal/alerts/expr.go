@@ -0,0 +1,566 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package alerts
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+	"github.com/wealthy/wealthy-mcp/internal/indicators"
+)
+
+// Expression is the condition of a technical alert, comparisons of prices
+// and indicators joined with and/or, such as
+//
+//	rsi(14,5m) < 30
+//	ema(9) crosses_above ema(21)
+//	close > high(20d) and volume > 100000
+//
+// An operand is a number, a price field of the candles (open, high, low,
+// close, volume) or an indicator (sma, ema, rsi, macd, bollinger, atr, vwap,
+// supertrend) with its parameters in brackets. The last argument may name the
+// interval of the candles, 1m, 5m, 15m, 1h or 1d, the alert's interval
+// otherwise. A period may carry the unit of its candles instead: high(20d)
+// is high(20,1d). Indicators with several lines pick one after a dot, such as
+// macd.signal or bollinger(20,2).upper.
+//
+// high(n) and low(n) are the highest high and lowest low of the n candles
+// before the current one, so close > high(20d) is a breakout above the range
+// of the last 20 days.
+type Expression struct {
+	root node
+}
+
+// ParseExpression parses the condition of a technical alert.
+func ParseExpression(s string) (*Expression, error) {
+	p := &parser{src: s}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("expression is empty")
+	}
+	root, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEnd {
+		return nil, fmt.Errorf("unexpected %q at %d in %q", t.text, t.pos+1, s)
+	}
+	return &Expression{root: root}, nil
+}
+
+// String is the expression in its normal form.
+func (x *Expression) String() string {
+	return x.root.String()
+}
+
+// Intervals returns the candle intervals the expression reads, def standing
+// for the operands without one.
+func (x *Expression) Intervals(def falcon.CandleInterval) []falcon.CandleInterval {
+	var out []falcon.CandleInterval
+	x.root.walk(func(s *series) {
+		if i := s.intervalOr(def); !slices.Contains(out, i) {
+			out = append(out, i)
+		}
+	})
+	return out
+}
+
+// Eval evaluates the expression on the candles started before at, by
+// interval, with def as the interval of the operands without one. prev is the
+// time of the evaluation before, the crossings compare against it. It returns
+// the values of the operands at at.
+func (x *Expression) Eval(bars map[falcon.CandleInterval][]indicators.Bar, def falcon.CandleInterval, at, prev time.Time) (bool, map[string]float64, error) {
+	e := &evaluation{bars: bars, def: def, at: at, prev: prev, values: make(map[string]float64)}
+	ok, err := x.root.eval(e)
+	return ok, e.values, err
+}
+
+type evaluation struct {
+	bars     map[falcon.CandleInterval][]indicators.Bar
+	def      falcon.CandleInterval
+	at, prev time.Time
+	values   map[string]float64
+}
+
+// before returns the bars of an interval started before t.
+func (e *evaluation) before(interval falcon.CandleInterval, t time.Time) []indicators.Bar {
+	bars := e.bars[interval]
+	n := len(bars)
+	for n > 0 && !bars[n-1].Time.Before(t) {
+		n--
+	}
+	return bars[:n]
+}
+
+type node interface {
+	eval(e *evaluation) (bool, error)
+	walk(func(*series))
+	String() string
+}
+
+// logical joins two conditions with and or or.
+type logical struct {
+	op          string
+	left, right node
+}
+
+func (l *logical) eval(e *evaluation) (bool, error) {
+	left, err := l.left.eval(e)
+	if err != nil {
+		return false, err
+	}
+	// both sides are evaluated for their values, without short circuit
+	right, err := l.right.eval(e)
+	if err != nil {
+		return false, err
+	}
+	if l.op == "and" {
+		return left && right, nil
+	}
+	return left || right, nil
+}
+
+func (l *logical) walk(f func(*series)) {
+	l.left.walk(f)
+	l.right.walk(f)
+}
+
+func (l *logical) String() string {
+	return fmt.Sprintf("(%s %s %s)", l.left, l.op, l.right)
+}
+
+// comparison compares two operands.
+type comparison struct {
+	op          string
+	left, right operand
+}
+
+var comparisons = []string{"<", "<=", ">", ">=", "crosses_above", "crosses_below"}
+
+func (c *comparison) eval(e *evaluation) (bool, error) {
+	left, err := c.left.value(e, e.at)
+	if err != nil {
+		return false, err
+	}
+	right, err := c.right.value(e, e.at)
+	if err != nil {
+		return false, err
+	}
+	switch c.op {
+	case "<":
+		return left < right, nil
+	case "<=":
+		return left <= right, nil
+	case ">":
+		return left > right, nil
+	case ">=":
+		return left >= right, nil
+	}
+	// a crossing needs the side the operands were on at the evaluation
+	// before, there is none when they had no value yet
+	if e.prev.IsZero() {
+		return false, nil
+	}
+	prevLeft, err := c.left.value(e, e.prev)
+	if err != nil {
+		return false, nil
+	}
+	prevRight, err := c.right.value(e, e.prev)
+	if err != nil {
+		return false, nil
+	}
+	if c.op == "crosses_above" {
+		return prevLeft <= prevRight && left > right, nil
+	}
+	return prevLeft >= prevRight && left < right, nil
+}
+
+func (c *comparison) walk(f func(*series)) {
+	for _, o := range []operand{c.left, c.right} {
+		if o.series != nil {
+			f(o.series)
+		}
+	}
+}
+
+func (c *comparison) String() string {
+	return fmt.Sprintf("%s %s %s", c.left, c.op, c.right)
+}
+
+// operand is a number or a series.
+type operand struct {
+	number float64
+	series *series
+}
+
+func (o operand) value(e *evaluation, at time.Time) (float64, error) {
+	if o.series == nil {
+		return o.number, nil
+	}
+	return o.series.value(e, at)
+}
+
+func (o operand) String() string {
+	if o.series == nil {
+		return strconv.FormatFloat(o.number, 'f', -1, 64)
+	}
+	return o.series.String()
+}
+
+// priceFields are the operands read from the candles themselves.
+var priceFields = []string{"open", "high", "low", "close", "volume"}
+
+// series is a price field or an indicator of the candles of an interval.
+type series struct {
+	name string
+	// spec is the indicator, output the line of it the series is
+	spec   indicators.Spec
+	output int
+	// period is the range of high(n) and low(n), zero for the current
+	// candle
+	period   int
+	interval falcon.CandleInterval
+}
+
+func (s *series) intervalOr(def falcon.CandleInterval) falcon.CandleInterval {
+	if s.interval != "" {
+		return s.interval
+	}
+	return def
+}
+
+func (s *series) String() string {
+	var args []string
+	if slices.Contains(priceFields, s.name) {
+		if s.period > 0 {
+			args = append(args, strconv.Itoa(s.period))
+		}
+	} else {
+		for _, p := range s.spec.Params {
+			args = append(args, strconv.FormatFloat(p, 'f', -1, 64))
+		}
+	}
+	if s.interval != "" {
+		args = append(args, string(s.interval))
+	}
+	text := s.name
+	if len(args) > 0 {
+		text += "(" + strings.Join(args, ",") + ")"
+	}
+	if s.output > 0 {
+		names, _ := s.spec.Series(nil)
+		text += "." + names[s.output]
+	}
+	return text
+}
+
+// value computes the series on the candles started before at, recording it
+// when at is the time of the evaluation.
+func (s *series) value(e *evaluation, at time.Time) (float64, error) {
+	interval := s.intervalOr(e.def)
+	bars := e.before(interval, at)
+	v := math.NaN()
+	if n := len(bars); n > 0 {
+		switch s.name {
+		case "open":
+			v = bars[n-1].Open
+		case "high", "low":
+			v = s.extreme(bars)
+		case "close":
+			v = bars[n-1].Close
+		case "volume":
+			v = float64(bars[n-1].Volume)
+		default:
+			_, lines := s.spec.Series(bars)
+			v = lines[s.output][n-1]
+		}
+	}
+	if math.IsNaN(v) {
+		return 0, fmt.Errorf("%s needs more %s candles than the %d available", s, interval, len(bars))
+	}
+	if at.Equal(e.at) {
+		e.values[s.String()] = math.Round(v*100) / 100
+	}
+	return v, nil
+}
+
+// extreme is the high or low of the last bar, or of the period bars before
+// it.
+func (s *series) extreme(bars []indicators.Bar) float64 {
+	n := len(bars)
+	if s.period == 0 {
+		if s.name == "high" {
+			return bars[n-1].High
+		}
+		return bars[n-1].Low
+	}
+	if n <= s.period {
+		return math.NaN()
+	}
+	v := bars[n-1-s.period].High
+	if s.name == "low" {
+		v = bars[n-1-s.period].Low
+	}
+	for _, b := range bars[n-s.period : n-1] {
+		if s.name == "high" {
+			v = max(v, b.High)
+		} else {
+			v = min(v, b.Low)
+		}
+	}
+	return v
+}
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenIdent
+	tokenNumber
+	// tokenDuration is a number with the unit of an interval, such as 5m or
+	// 20d
+	tokenDuration
+	tokenOp
+	tokenPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+type parser struct {
+	src    string
+	tokens []token
+	next   int
+}
+
+func (p *parser) lex() error {
+	s := p.src
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(s) && (unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j])) || s[j] == '_') {
+				j++
+			}
+			p.tokens = append(p.tokens, token{tokenIdent, strings.ToLower(s[i:j]), i})
+			i = j
+		case unicode.IsDigit(c) || c == '.' && i+1 < len(s) && unicode.IsDigit(rune(s[i+1])):
+			j := i
+			for j < len(s) && (unicode.IsDigit(rune(s[j])) || s[j] == '.') {
+				j++
+			}
+			kind := tokenNumber
+			if j < len(s) && strings.ContainsRune("mhdMHD", rune(s[j])) && (j+1 == len(s) || !unicode.IsLetter(rune(s[j+1]))) {
+				kind = tokenDuration
+				j++
+			}
+			p.tokens = append(p.tokens, token{kind, strings.ToLower(s[i:j]), i})
+			i = j
+		case strings.ContainsRune("<>", c):
+			j := i + 1
+			if j < len(s) && s[j] == '=' {
+				j++
+			}
+			p.tokens = append(p.tokens, token{tokenOp, s[i:j], i})
+			i = j
+		case strings.ContainsRune("(),.", c):
+			p.tokens = append(p.tokens, token{tokenPunct, string(c), i})
+			i++
+		default:
+			return fmt.Errorf("unexpected %q at %d in %q", c, i+1, s)
+		}
+	}
+	return nil
+}
+
+func (p *parser) peek() token {
+	if p.next < len(p.tokens) {
+		return p.tokens[p.next]
+	}
+	return token{kind: tokenEnd, text: "end of expression", pos: len(p.src)}
+}
+
+func (p *parser) take() token {
+	t := p.peek()
+	if t.kind != tokenEnd {
+		p.next++
+	}
+	return t
+}
+
+func (p *parser) expect(text string) error {
+	if t := p.take(); t.text != text {
+		return fmt.Errorf("expected %q at %d in %q, got %q", text, t.pos+1, p.src, t.text)
+	}
+	return nil
+}
+
+func (p *parser) or() (node, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().text == "or" {
+		p.take()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (node, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().text == "and" {
+		p.take()
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) term() (node, error) {
+	if p.peek().text == "(" {
+		p.take()
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return n, p.expect(")")
+	}
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	op := p.take()
+	if !slices.Contains(comparisons, op.text) {
+		return nil, fmt.Errorf("expected a comparison (%s) at %d in %q, got %q", strings.Join(comparisons, ", "), op.pos+1, p.src, op.text)
+	}
+	right, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	if left.series == nil && right.series == nil {
+		return nil, fmt.Errorf("%s %s %s compares two numbers", left, op.text, right)
+	}
+	return &comparison{op: op.text, left: left, right: right}, nil
+}
+
+func (p *parser) operand() (operand, error) {
+	t := p.take()
+	switch t.kind {
+	case tokenNumber:
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return operand{}, fmt.Errorf("invalid number %q at %d", t.text, t.pos+1)
+		}
+		return operand{number: v}, nil
+	case tokenIdent:
+		s, err := p.series(t)
+		return operand{series: s}, err
+	}
+	return operand{}, fmt.Errorf("expected a number, price or indicator at %d in %q, got %q", t.pos+1, p.src, t.text)
+}
+
+func (p *parser) series(name token) (*series, error) {
+	s := &series{name: name.text}
+	if s.name == "price" {
+		s.name = "close"
+	}
+	var params []float64
+	if p.peek().text == "(" {
+		p.take()
+		for p.peek().text != ")" {
+			if len(params) > 0 || s.interval != "" {
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+			arg := p.take()
+			if s.interval != "" {
+				return nil, fmt.Errorf("the interval must be the last argument of %s", s.name)
+			}
+			switch arg.kind {
+			case tokenNumber:
+				v, err := strconv.ParseFloat(arg.text, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid number %q at %d", arg.text, arg.pos+1)
+				}
+				params = append(params, v)
+			case tokenDuration:
+				if interval, err := falcon.ParseCandleInterval(arg.text); err == nil {
+					s.interval = interval
+					continue
+				}
+				// a period in the unit of its candles, 20d for 20 daily ones
+				n, err := strconv.Atoi(arg.text[:len(arg.text)-1])
+				if err != nil || n <= 0 {
+					return nil, fmt.Errorf("invalid period %q of %s", arg.text, s.name)
+				}
+				params = append(params, float64(n))
+				s.interval = falcon.CandleInterval("1" + arg.text[len(arg.text)-1:])
+			default:
+				return nil, fmt.Errorf("expected a number or an interval at %d in %q, got %q", arg.pos+1, p.src, arg.text)
+			}
+		}
+		p.take()
+	}
+
+	if slices.Contains(priceFields, s.name) {
+		switch {
+		case len(params) == 0:
+		case len(params) == 1 && (s.name == "high" || s.name == "low") && params[0] == math.Trunc(params[0]) && params[0] > 0:
+			s.period = int(params[0])
+		default:
+			return nil, fmt.Errorf("%s takes only an interval, except high(n) and low(n) with the number of candles", s.name)
+		}
+		if p.peek().text == "." {
+			return nil, fmt.Errorf("%s has no lines to pick with a dot", s.name)
+		}
+		return s, nil
+	}
+
+	if !slices.Contains(indicators.Names(), s.name) {
+		return nil, fmt.Errorf("unknown price or indicator %q, use one of %s, %s", s.name, strings.Join(priceFields, ", "), strings.Join(indicators.Names(), ", "))
+	}
+	spec, err := indicators.NewSpec(s.name, params...)
+	if err != nil {
+		return nil, err
+	}
+	s.spec = spec
+	if p.peek().text == "." {
+		p.take()
+		line := p.take()
+		names, _ := spec.Series(nil)
+		s.output = slices.Index(names, line.text)
+		if s.output < 0 {
+			return nil, fmt.Errorf("%s has no line %q, use one of %s", s.name, line.text, strings.Join(names, ", "))
+		}
+	}
+	return s, nil
+}
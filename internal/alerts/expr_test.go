@@ -0,0 +1,100 @@
+package alerts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+	"github.com/wealthy/wealthy-mcp/internal/indicators"
+)
+
+func TestParseExpression(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  string
+	}{
+		{in: "rsi(14,5m) < 30", want: "rsi(14,5m) < 30"},
+		{in: "RSI(14, 5m)<30", want: "rsi(14,5m) < 30"},
+		{in: "rsi < 30", want: "rsi(14) < 30"},
+		{in: "ema(9) crosses_above ema(21)", want: "ema(9) crosses_above ema(21)"},
+		{in: "close > high(20d)", want: "close > high(20,1d)"},
+		{in: "price >= low(2h)", want: "close >= low(2,1h)"},
+		{in: "close(15m) > vwap(15m) and volume > 1e5", err: `unexpected "e5"`},
+		{in: "close(15m) > vwap(15m) and volume > 100000", want: "(close(15m) > vwap(15m) and volume > 100000)"},
+		{in: "macd.histogram > 0 or (rsi(7) < 20 and close < bollinger(20,2.5).lower)", want: "(macd(12,26,9).histogram > 0 or (rsi(7) < 20 and close < bollinger(20,2.5).lower))"},
+		{in: "supertrend(10,3,1d).trend > 0", want: "supertrend(10,3,1d).trend > 0"},
+		{in: "", err: "expression is empty"},
+		{in: "rsi(14) <", err: "expected a number, price or indicator"},
+		{in: "rsi(14) = 30", err: `unexpected '='`},
+		{in: "rsi(14) 30", err: "expected a comparison"},
+		{in: "stoch < 20", err: `unknown price or indicator "stoch"`},
+		{in: "30 < 40", err: "compares two numbers"},
+		{in: "rsi(5m,14) < 30", err: "the interval must be the last argument"},
+		{in: "ema(9,21) > 0", err: "at most 1 parameters"},
+		{in: "close(5) > 0", err: "close takes only an interval"},
+		{in: "macd.upper > 0", err: `macd has no line "upper"`},
+		{in: "(rsi < 30", err: `expected ")"`},
+	}
+	for _, tt := range tests {
+		x, err := ParseExpression(tt.in)
+		if tt.err != "" {
+			assert.ErrorContains(t, err, tt.err, tt.in)
+			continue
+		}
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, x.String())
+	}
+}
+
+func TestExpressionEval(t *testing.T) {
+	day := time.Date(2025, 7, 1, 0, 0, 0, 0, falcon.IST)
+	var daily, intraday []indicators.Bar
+	for i := 0; i < 25; i++ {
+		// a range between 95 and 105
+		c := 100 + float64(i%5) - 2
+		daily = append(daily, indicators.Bar{Time: day.AddDate(0, 0, i-25), Open: c, High: c + 3, Low: c - 3, Close: c, Volume: 1000})
+	}
+	for i, c := range []float64{100, 101, 102, 108, 109} {
+		intraday = append(intraday, indicators.Bar{Time: day.Add(9*time.Hour + 15*time.Minute + time.Duration(i)*5*time.Minute), Open: c, High: c, Low: c, Close: c, Volume: 10})
+	}
+	daily = append(daily, indicators.Bar{Time: day, Open: 100, High: 109, Low: 100, Close: 109, Volume: 50})
+	bars := map[falcon.CandleInterval][]indicators.Bar{falcon.Interval1Day: daily, falcon.Interval5Minutes: intraday}
+	at := func(i int) time.Time {
+		return day.Add(9*time.Hour + 15*time.Minute + time.Duration(i)*5*time.Minute)
+	}
+
+	tests := []struct {
+		expr   string
+		at     int
+		want   bool
+		values map[string]float64
+	}{
+		{"close > high(20d)", 5, true, map[string]float64{"close": 109, "high(20,1d)": 105}},
+		{"close > high(20d)", 3, false, map[string]float64{"close": 102, "high(20,1d)": 105}},
+		{"close crosses_above high(20d)", 4, true, nil},
+		{"close crosses_above high(20d)", 5, false, nil},
+		{"close crosses_below 101.5", 1, false, nil},
+		{"sma(2) crosses_above 105", 5, true, map[string]float64{"sma(2)": 108.5}},
+		{"close > 105 and volume(1d) > 40", 5, true, map[string]float64{"close": 109, "volume(1d)": 50}},
+		{"close < 100 or low(1d) <= 100", 5, true, nil},
+	}
+	for _, tt := range tests {
+		x, err := ParseExpression(tt.expr)
+		require.NoError(t, err, tt.expr)
+		got, values, err := x.Eval(bars, falcon.Interval5Minutes, at(tt.at), at(tt.at-1))
+		require.NoError(t, err, tt.expr)
+		assert.Equal(t, tt.want, got, "%s at candle %d", tt.expr, tt.at)
+		if tt.values != nil {
+			assert.Equal(t, tt.values, values, tt.expr)
+		}
+	}
+
+	x, err := ParseExpression("rsi(14) < 30")
+	require.NoError(t, err)
+	assert.Equal(t, []falcon.CandleInterval{falcon.Interval5Minutes}, x.Intervals(falcon.Interval5Minutes))
+	_, _, err = x.Eval(bars, falcon.Interval5Minutes, at(5), at(4))
+	assert.ErrorContains(t, err, "rsi(14) needs more 5m candles than the 5 available")
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os/exec"
+	"time"
+)
+
+// hookTimeout bounds one webhook request or command run.
+const hookTimeout = 10 * time.Second
+
+// Hook hands fired alerts to the user's own tooling, besides the MCP
+// notifications. The event is sent as JSON to the webhook with a POST and
+// written to the standard input of the command.
+type Hook struct {
+	// URL is the webhook, none when empty
+	URL string
+	// Command is the program to run, none when empty. It is run directly,
+	// without a shell.
+	Command string
+	Client  *http.Client
+}
+
+// Enabled reports whether the hook has a webhook or a command.
+func (h Hook) Enabled() bool {
+	return h.URL != "" || h.Command != ""
+}
+
+// Forward fires the hook for every event of engine. It returns a function
+// that stops forwarding.
+func (h Hook) Forward(engine *Engine) func() {
+	events, cancel := engine.Watch()
+	go func() {
+		for e := range events {
+			if err := h.Fire(context.Background(), e); err != nil {
+				slog.Warn("alert hook failed", "id", e.Alert.ID, "error", err)
+			}
+		}
+	}()
+	return cancel
+}
+
+// Fire sends an event to the webhook and the command.
+func (h Hook) Fire(ctx context.Context, e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to serialize alert event: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, hookTimeout)
+	defer cancel()
+	if h.URL != "" {
+		if err := h.post(ctx, data); err != nil {
+			return err
+		}
+	}
+	if h.Command != "" {
+		cmd := exec.CommandContext(ctx, h.Command)
+		cmd.Stdin = bytes.NewReader(data)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("alert command %s failed: %w: %s", h.Command, err, bytes.TrimSpace(out))
+		}
+	}
+	return nil
+}
+
+func (h Hook) post(ctx context.Context, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("invalid alert webhook: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("alert webhook failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("alert webhook returned %s", resp.Status)
+	}
+	return nil
+}
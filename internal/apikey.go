@@ -0,0 +1,31 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package internal
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyAuth rejects requests that do not present the API key, either as a
+// bearer token in the Authorization header or in the X-API-Key header.
+func APIKeyAuth(apiKey string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		presented := c.GetHeader("X-API-Key")
+		if bearer, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+			presented = strings.TrimSpace(bearer)
+		}
+		if presented == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(apiKey)) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="wealthy-mcp"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or missing API key"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -9,88 +9,179 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
-	"net/url"
 	"os"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/browser"
-)
-
-const (
-	AUTH_NOT_STARTED = iota
-	AUTH_STARTED
-	AUTH_SUCCESS
-	AUTH_FAILED
+	"github.com/wealthy/wealthy-mcp/internal/tokenstore"
 )
 
 var (
 	loginURL   = "https://api.wealthy.in/wealthyauth/dashboard/login/"
-	AuthToken  string
 	httpClient = &http.Client{
 		Timeout: 30 * time.Second,
 	}
-	AuthStage   int
-	CallbackURL string
 
-	DebugMode bool // Set from main if debug flag is enabled
+	// Auth holds the auth token and login state of the server.
+	Auth = NewAuthManager()
+
+	TokenStore *tokenstore.Store // Set from main, nil disables token persistence
+
+	errLoginNotConfigured = errors.New("login callback is not configured")
 )
 
+func init() {
+	// Keep the stdio transport clean, the browser helpers write to Stdout.
+	browser.Stdout = os.Stderr
+}
+
 func AuthRequired() bool {
-	return AuthStage == AUTH_NOT_STARTED || AuthStage == AUTH_FAILED
+	return Auth.LoginRequired()
+}
+
+// LoadStoredToken restores a previously persisted auth token so that the
+// browser login can be skipped. It reports whether a usable token was loaded.
+func LoadStoredToken() bool {
+	if TokenStore == nil {
+		return false
+	}
+	token, err := TokenStore.Load()
+	switch {
+	case errors.Is(err, tokenstore.ErrNotFound):
+		return false
+	case errors.Is(err, tokenstore.ErrExpired):
+		slog.Info("stored auth token has expired, login required", "expired_at", token.ExpiresAt)
+		return false
+	case err != nil:
+		slog.Warn("failed to load stored auth token", "error", err)
+		return false
+	}
+	Auth.Succeed(token.AccessToken, token.ExpiresAt)
+	slog.Info("loaded stored auth token", "expires_at", token.ExpiresAt)
+	return true
+}
+
+// Logout forgets the current auth token and wipes it from the token store.
+func Logout() error {
+	Auth.Reset()
+	if TokenStore == nil {
+		return nil
+	}
+	return TokenStore.Clear()
+}
+
+func saveToken(token *tokenstore.Token) {
+	if TokenStore == nil {
+		return
+	}
+	if err := TokenStore.Save(token); err != nil {
+		slog.Warn("failed to persist auth token", "error", err)
+	}
+}
+
+// StartLogin begins a new login that redirects to cbURL once the user has
+// signed in. In browser mode the login page is opened locally, in headless
+// mode (or when no browser is available) the link is printed instead.
+func StartLogin(cbURL string) {
+	Auth.SetCallbackURL(cbURL)
+	Auth.Start()
+	openLoginPage()
+}
+
+// Relogin is called when the API rejected staleToken. It starts a new login,
+// unless one is already in progress or the token was already replaced, and
+// waits for it so the caller can replay its request with the returned token.
+// Client sessions get a new login link through ErrLoginRequired instead.
+func Relogin(ctx context.Context, staleToken string, timeout time.Duration) (string, error) {
+	m := AuthFromContext(ctx)
+	if m.SessionID() != "" {
+		if m.Invalidate(staleToken) {
+			newLoginLink(m)
+		}
+		if m.State() == AUTH_SUCCESS {
+			return m.Token(), nil
+		}
+		return "", ErrLoginRequired
+	}
+	if m.Invalidate(staleToken) {
+		if m.CallbackURL() == "" {
+			m.Fail(errLoginNotConfigured)
+		} else {
+			slog.Info("auth token rejected, starting a new login")
+			openLoginPage()
+		}
+	}
+	return m.WaitForToken(ctx, timeout)
+}
+
+func openLoginPage() {
+	link := newLoginLink(Auth)
+	if LoginMode == LoginModeBrowser {
+		err := browser.OpenURL(link.URL)
+		if err == nil {
+			slog.Info("opened browser for login", "url", link.URL, "code", link.Code)
+			return
+		}
+		slog.Warn("failed to open browser, falling back to headless login", "error", err)
+	}
+	printLoginInstructions(link)
+}
+
+// CompleteLogin exchanges an authorization token from the login redirect for
+// an auth token of the caller's session. The input may also be the full
+// redirect URL.
+func CompleteLogin(ctx context.Context, authorizationToken string) error {
+	return completeLogin(ctx, AuthFromContext(ctx), authorizationToken)
 }
 
-func BrowserLogin(cbURL string) {
-	// if DebugMode {
-	// 	if f, err := os.Open("auth_token.txt"); err == nil {
-	// 		defer f.Close()
-	// 		buf := make([]byte, 4096)
-	// 		n, _ := f.Read(buf)
-	// 		token := string(buf[:n])
-	// 		if len(token) > 0 {
-	// 			AuthToken = token
-	// 			AuthStage = AUTH_SUCCESS
-	// 			fmt.Println("Loaded auth token from file in debug mode, skipping browser login.")
-	// 			return
-	// 		}
-	// 	}
-	// }
-	if CallbackURL == "" {
-		CallbackURL = cbURL
-	}
-	loginURL := fmt.Sprintf(loginURL+"?redirect_url=%s", url.QueryEscape(CallbackURL))
-	fmt.Println("opening browser", loginURL)
-	AuthStage = AUTH_STARTED
-	err := browser.OpenURL(loginURL)
+func completeLogin(ctx context.Context, m *AuthManager, authorizationToken string) error {
+	authCode := parseAuthorizationToken(authorizationToken)
+	if authCode == "" {
+		return errors.New("authorization_token is empty")
+	}
+	token, err := generateAuthToken(ctx, authCode)
 	if err != nil {
-		log.Fatal("authentication failed", err)
+		slog.Error("authentication failed", "error", err, "session", m.SessionID())
+		m.Fail(err)
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	stored := tokenstore.NewToken(token, time.Now())
+	m.Succeed(token, stored.ExpiresAt)
+	// Only the server wide login belongs to the local user
+	if m == Auth {
+		saveToken(stored)
 	}
+	slog.Info("login successful", "expires_at", stored.ExpiresAt, "session", m.SessionID())
+	return nil
 }
 
 func AuthHandler(c *gin.Context) {
 	authcode := c.Query("authorization_token")
 	if authcode != "" {
-		token, err := generateAuthToken(c.Request.Context(), authcode)
-		if err != nil {
-			AuthStage = AUTH_FAILED
-			log.Fatal("authentication failed", err)
-		}
-		AuthToken = token
-		AuthStage = AUTH_SUCCESS
-
-		// If debug mode, save token to file
-		if DebugMode {
-			f, err := os.Create("auth_token.txt")
-			if err == nil {
-				f.WriteString(AuthToken)
-				f.Close()
-			} else {
-				log.Println("Failed to write auth token to file:", err)
+		m := Auth
+		code := c.Query(loginCodeParam)
+		if session := c.Query(sessionParam); session != "" {
+			m = Sessions.Lookup(session)
+			// A session login must carry the code of the link issued to it
+			if m == nil || code == "" {
+				c.String(http.StatusBadRequest, "Unknown login session, please request a new login link.")
+				return
 			}
 		}
+		if code != "" && !matchesLoginCode(m, code) {
+			c.String(http.StatusBadRequest, "This login link has expired, please use the latest one.")
+			return
+		}
+		if err := completeLogin(c.Request.Context(), m, authcode); err != nil {
+			c.String(http.StatusUnauthorized, "Authentication failed, please try logging in again.")
+			return
+		}
+
 		// Return success with HTML that will close the window
 		c.Header("Content-Type", "text/html")
 		c.Status(200)
@@ -116,8 +207,11 @@ func AuthHandler(c *gin.Context) {
 
 func generateAuthToken(ctx context.Context, authCode string) (string, error) {
 	url := "https://api.wealthy.in/wealthyauth/dashboard/fetch-internal-token-details/"
-	reqBody := `{"authorization_token":"` + authCode + `"}`
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer([]byte(reqBody)))
+	reqBody, err := json.Marshal(map[string]string{"authorization_token": authCode})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(reqBody))
 	if err != nil {
 		return "", err
 	}
@@ -132,5 +226,9 @@ func generateAuthToken(ctx context.Context, authCode string) (string, error) {
 	if err := json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
 		return "", err
 	}
-	return respBody["access_token"].(string), nil
+	token, ok := respBody["access_token"].(string)
+	if !ok || token == "" {
+		return "", fmt.Errorf("no access token in response, status code: %d", resp.StatusCode)
+	}
+	return token, nil
 }
@@ -0,0 +1,320 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// AuthState is a stage of the login lifecycle.
+type AuthState int
+
+const (
+	AUTH_NOT_STARTED AuthState = iota
+	AUTH_STARTED
+	AUTH_SUCCESS
+	AUTH_FAILED
+	AUTH_EXPIRED
+)
+
+func (s AuthState) String() string {
+	switch s {
+	case AUTH_NOT_STARTED:
+		return "not_started"
+	case AUTH_STARTED:
+		return "started"
+	case AUTH_SUCCESS:
+		return "success"
+	case AUTH_FAILED:
+		return "failed"
+	case AUTH_EXPIRED:
+		return "expired"
+	default:
+		return "unknown"
+	}
+}
+
+var (
+	ErrAuthTimeout = errors.New("timed out waiting for login to complete")
+	ErrAuthFailed  = errors.New("login failed")
+)
+
+// AuthEvent is published to subscribers on every state transition.
+type AuthEvent struct {
+	State AuthState
+	Err   error
+	At    time.Time
+}
+
+// AuthManager owns the auth token and the login state machine. It is safe for
+// concurrent use by the callback handler, the transport loop and API calls.
+type AuthManager struct {
+	mu          sync.RWMutex
+	state       AuthState
+	token       string
+	expiresAt   time.Time
+	err         error
+	callbackURL string
+	// sessionID is the MCP session this manager belongs to, empty for Auth.
+	sessionID string
+	// link is the login link of the login in progress.
+	link *LoginLink
+	// done is closed when a started login completes, successfully or not.
+	done    chan struct{}
+	subs    map[int]chan AuthEvent
+	nextSub int
+}
+
+// NewAuthManager creates an AuthManager in the AUTH_NOT_STARTED state.
+func NewAuthManager() *AuthManager {
+	return &AuthManager{
+		done: make(chan struct{}),
+		subs: make(map[int]chan AuthEvent),
+	}
+}
+
+// State returns the current login state, moving to AUTH_EXPIRED if the token
+// has passed its expiry.
+func (m *AuthManager) State() AuthState {
+	m.expireIfDue()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.state
+}
+
+// Token returns the current auth token, empty if there is none.
+func (m *AuthManager) Token() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.token
+}
+
+// ExpiresAt returns the expiry of the current token, zero if unknown.
+func (m *AuthManager) ExpiresAt() time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.expiresAt
+}
+
+// Err returns the error of the last failed login.
+func (m *AuthManager) Err() error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.err
+}
+
+// CallbackURL returns the URL the login page redirects to.
+func (m *AuthManager) CallbackURL() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.callbackURL
+}
+
+// SetCallbackURL sets the login redirect URL if it has not been set yet.
+func (m *AuthManager) SetCallbackURL(url string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.callbackURL == "" {
+		m.callbackURL = url
+	}
+}
+
+// SessionID returns the MCP session the manager authenticates, empty for the
+// server wide manager.
+func (m *AuthManager) SessionID() string {
+	return m.sessionID
+}
+
+// PendingLoginLink returns the link of the login in progress, nil if none.
+func (m *AuthManager) PendingLoginLink() *LoginLink {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.state != AUTH_STARTED || m.link == nil {
+		return nil
+	}
+	link := *m.link
+	return &link
+}
+
+func (m *AuthManager) setLoginLink(link *LoginLink) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.link = link
+}
+
+// LoginRequired reports whether a new login has to be started.
+func (m *AuthManager) LoginRequired() bool {
+	switch m.State() {
+	case AUTH_NOT_STARTED, AUTH_FAILED, AUTH_EXPIRED:
+		return true
+	}
+	return false
+}
+
+// Start marks a login as in progress. Waiters block until Succeed or Fail.
+func (m *AuthManager) Start() {
+	m.mu.Lock()
+	if m.state != AUTH_STARTED {
+		m.done = make(chan struct{})
+	}
+	m.state = AUTH_STARTED
+	m.err = nil
+	m.mu.Unlock()
+	m.publish(AuthEvent{State: AUTH_STARTED})
+}
+
+// Succeed stores the token and wakes up waiters. A zero expiresAt means the
+// token does not expire on its own.
+func (m *AuthManager) Succeed(token string, expiresAt time.Time) {
+	m.mu.Lock()
+	m.token = token
+	m.expiresAt = expiresAt
+	m.err = nil
+	m.transition(AUTH_SUCCESS)
+	m.mu.Unlock()
+	m.publish(AuthEvent{State: AUTH_SUCCESS})
+}
+
+// Fail records a failed login and wakes up waiters.
+func (m *AuthManager) Fail(err error) {
+	m.mu.Lock()
+	m.err = err
+	m.transition(AUTH_FAILED)
+	m.mu.Unlock()
+	m.publish(AuthEvent{State: AUTH_FAILED, Err: err})
+}
+
+// Expire marks the current token as no longer valid, e.g. after a 401.
+func (m *AuthManager) Expire() {
+	m.mu.Lock()
+	if m.state != AUTH_SUCCESS {
+		m.mu.Unlock()
+		return
+	}
+	m.transition(AUTH_EXPIRED)
+	m.mu.Unlock()
+	m.publish(AuthEvent{State: AUTH_EXPIRED})
+}
+
+// Invalidate reports that the API rejected staleToken. Only the first caller
+// for a given token moves the manager to AUTH_STARTED and gets true, meaning
+// it is responsible for driving the new login. Callers that get false should
+// simply wait for the login already in progress, or use the newer token.
+func (m *AuthManager) Invalidate(staleToken string) bool {
+	m.mu.Lock()
+	if m.state == AUTH_STARTED || (m.state == AUTH_SUCCESS && m.token != staleToken) {
+		m.mu.Unlock()
+		return false
+	}
+	m.done = make(chan struct{})
+	m.state = AUTH_STARTED
+	m.err = nil
+	m.mu.Unlock()
+	m.publish(AuthEvent{State: AUTH_EXPIRED})
+	m.publish(AuthEvent{State: AUTH_STARTED})
+	return true
+}
+
+// Reset drops the token and returns to AUTH_NOT_STARTED.
+func (m *AuthManager) Reset() {
+	m.mu.Lock()
+	m.token = ""
+	m.expiresAt = time.Time{}
+	m.err = nil
+	m.transition(AUTH_NOT_STARTED)
+	m.mu.Unlock()
+	m.publish(AuthEvent{State: AUTH_NOT_STARTED})
+}
+
+// WaitForToken returns the auth token to use for a request. While a login is
+// in progress it blocks until the login completes, the context is done or the
+// timeout elapses. In every other state it returns the current token, which
+// is empty if the user has not logged in.
+func (m *AuthManager) WaitForToken(ctx context.Context, timeout time.Duration) (string, error) {
+	m.expireIfDue()
+	m.mu.RLock()
+	state, token, done := m.state, m.token, m.done
+	m.mu.RUnlock()
+	if state != AUTH_STARTED {
+		return token, nil
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		return "", ErrAuthTimeout
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.state == AUTH_FAILED {
+		if m.err != nil {
+			return "", errors.Join(ErrAuthFailed, m.err)
+		}
+		return "", ErrAuthFailed
+	}
+	return m.token, nil
+}
+
+// Subscribe returns a channel receiving every subsequent state transition and
+// a function to cancel the subscription. Slow subscribers miss events rather
+// than blocking the manager.
+func (m *AuthManager) Subscribe() (<-chan AuthEvent, func()) {
+	ch := make(chan AuthEvent, 8)
+	m.mu.Lock()
+	id := m.nextSub
+	m.nextSub++
+	m.subs[id] = ch
+	m.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			m.mu.Lock()
+			delete(m.subs, id)
+			m.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// transition moves to a new state and releases waiters of a started login.
+// Callers must hold m.mu.
+func (m *AuthManager) transition(state AuthState) {
+	if m.state == AUTH_STARTED && state != AUTH_STARTED {
+		close(m.done)
+		m.link = nil
+	}
+	m.state = state
+}
+
+func (m *AuthManager) expireIfDue() {
+	m.mu.RLock()
+	due := m.state == AUTH_SUCCESS && !m.expiresAt.IsZero() && !time.Now().Before(m.expiresAt)
+	m.mu.RUnlock()
+	if due {
+		m.Expire()
+	}
+}
+
+func (m *AuthManager) publish(event AuthEvent) {
+	event.At = time.Now()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, ch := range m.subs {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
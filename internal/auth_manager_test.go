@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthManagerWaitForToken(t *testing.T) {
+	m := NewAuthManager()
+
+	token, err := m.WaitForToken(context.Background(), time.Second)
+	require.NoError(t, err)
+	assert.Empty(t, token)
+
+	m.Start()
+	var wg sync.WaitGroup
+	results := make([]string, 5)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = m.WaitForToken(context.Background(), time.Second)
+		}(i)
+	}
+	time.Sleep(10 * time.Millisecond)
+	m.Succeed("test-token", time.Time{})
+	wg.Wait()
+
+	for _, got := range results {
+		assert.Equal(t, "test-token", got)
+	}
+	assert.Equal(t, AUTH_SUCCESS, m.State())
+}
+
+func TestAuthManagerWaitFailures(t *testing.T) {
+	m := NewAuthManager()
+	m.Start()
+
+	_, err := m.WaitForToken(context.Background(), 10*time.Millisecond)
+	assert.ErrorIs(t, err, ErrAuthTimeout)
+
+	loginErr := errors.New("bad code")
+	go m.Fail(loginErr)
+	_, err = m.WaitForToken(context.Background(), time.Second)
+	assert.ErrorIs(t, err, ErrAuthFailed)
+	assert.ErrorIs(t, err, loginErr)
+	assert.True(t, m.LoginRequired())
+}
+
+func TestAuthManagerExpiry(t *testing.T) {
+	m := NewAuthManager()
+	events, cancel := m.Subscribe()
+	defer cancel()
+
+	m.Succeed("test-token", time.Now().Add(-time.Second))
+	assert.Equal(t, AUTH_EXPIRED, m.State())
+	assert.True(t, m.LoginRequired())
+
+	assert.Equal(t, AUTH_SUCCESS, (<-events).State)
+	assert.Equal(t, AUTH_EXPIRED, (<-events).State)
+}
+
+func TestAuthManagerInvalidateSingleFlight(t *testing.T) {
+	m := NewAuthManager()
+	m.Succeed("stale-token", time.Time{})
+
+	var drivers int
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if m.Invalidate("stale-token") {
+				mu.Lock()
+				drivers++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, drivers)
+	assert.Equal(t, AUTH_STARTED, m.State())
+
+	m.Succeed("fresh-token", time.Time{})
+	assert.False(t, m.Invalidate("stale-token"))
+	assert.Equal(t, AUTH_SUCCESS, m.State())
+}
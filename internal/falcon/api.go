@@ -10,17 +10,41 @@ import (
 	"github.com/wealthy/wealthy-mcp/internal"
 )
 
+// callRestAPI sends the request with the current auth token and decodes the
+// response into resp. If the token is rejected, the call waits for a new
+// login and replays the request once with the new token.
 func callRestAPI(ctx context.Context, httpReq *http.Request, resp any, client *http.Client) error {
-	httpReq.Header.Set("Authorization", internal.AuthToken)
+	token, err := internal.Authorize(ctx)
+	if err != nil {
+		return loginError(ctx, err)
+	}
 
-	httpResp, err := client.Do(httpReq)
+	httpResp, err := doRequest(httpReq, token, client)
 	if err != nil {
-		slog.Error("failed to call wealthy api", "error", err)
-		return fmt.Errorf("network error: %w", err)
+		return err
 	}
 	if httpResp.StatusCode == http.StatusUnauthorized {
-		internal.BrowserLogin(internal.CallbackURL)
+		httpResp.Body.Close()
+		replay, err := cloneRequest(ctx, httpReq)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrUnauthorized, err)
+		}
+		token, err = internal.Relogin(ctx, token, internal.LoginWaitTimeout)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrUnauthorized, loginError(ctx, err))
+		}
+		slog.Info("replaying request after login", "url", httpReq.URL.Path)
+		httpResp, err = doRequest(replay, token, client)
+		if err != nil {
+			return err
+		}
+		if httpResp.StatusCode == http.StatusUnauthorized {
+			httpResp.Body.Close()
+			return ErrUnauthorized
+		}
 	}
+	defer httpResp.Body.Close()
+
 	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
 		return fmt.Errorf("response status code: %d", httpResp.StatusCode)
 	}
@@ -28,9 +52,43 @@ func callRestAPI(ctx context.Context, httpReq *http.Request, resp any, client *h
 	if httpResp.StatusCode == http.StatusNoContent || httpResp.StatusCode == http.StatusCreated {
 		return nil
 	}
-	defer httpResp.Body.Close()
 	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
 		return fmt.Errorf("failed to decode response: %w, status code: %d", err, httpResp.StatusCode)
 	}
 	return nil
 }
+
+// loginError explains how to finish the pending login, if there is one.
+func loginError(ctx context.Context, err error) error {
+	if hint := internal.LoginHint(ctx); hint != "" {
+		return fmt.Errorf("login not completed: %w. %s", err, hint)
+	}
+	return fmt.Errorf("login not completed: %w", err)
+}
+
+func doRequest(httpReq *http.Request, token string, client *http.Client) (*http.Response, error) {
+	httpReq.Header.Set("Authorization", token)
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		slog.Error("failed to call wealthy api", "error", err)
+		return nil, fmt.Errorf("network error: %w", err)
+	}
+	return httpResp, nil
+}
+
+// cloneRequest prepares a copy of an already sent request with a fresh body.
+func cloneRequest(ctx context.Context, httpReq *http.Request) (*http.Request, error) {
+	replay := httpReq.Clone(ctx)
+	if httpReq.Body == nil || httpReq.Body == http.NoBody {
+		return replay, nil
+	}
+	if httpReq.GetBody == nil {
+		return nil, fmt.Errorf("request body cannot be replayed")
+	}
+	body, err := httpReq.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("failed to rewind request body: %w", err)
+	}
+	replay.Body = body
+	return replay, nil
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package falcon
+
+import (
+	"fmt"
+)
+
+// Exchange identifiers, the exchange_name of orders and instruments.
+const (
+	ExchangeNSE = 1
+	ExchangeNFO = 2
+	ExchangeBSE = 3
+	ExchangeBFO = 4
+)
+
+// Transaction types.
+const (
+	TransactionBuy  = 1
+	TransactionSell = 2
+)
+
+// Price types.
+const (
+	PriceTypeLimit          = 1
+	PriceTypeMarket         = 2
+	PriceTypeStopLossLimit  = 3
+	PriceTypeStopLossMarket = 4
+	PriceTypeDisclosed      = 5
+	PriceTypeTwoLeg         = 6
+	PriceTypeThreeLeg       = 7
+)
+
+// Product types, the order_type of orders and positions. Whether an order is
+// a market, limit or stop order is its price type.
+const (
+	ProductCNC     = 1
+	ProductMIS     = 2
+	ProductNRML    = 3
+	ProductCover   = 4
+	ProductBracket = 5
+	ProductMTF     = 6
+)
+
+var exchangeNames = map[int]string{
+	ExchangeNSE: "nse",
+	ExchangeNFO: "nfo",
+	ExchangeBSE: "bse",
+	ExchangeBFO: "bfo",
+}
+
+var productNames = map[int]string{
+	ProductCNC:     "CNC",
+	ProductMIS:     "MIS",
+	ProductNRML:    "NRML",
+	ProductCover:   "COVER",
+	ProductBracket: "BRACKET",
+	ProductMTF:     "MTF",
+}
+
+var priceTypeNames = map[int]string{
+	PriceTypeLimit:          "LIMIT",
+	PriceTypeMarket:         "MARKET",
+	PriceTypeStopLossLimit:  "SL-LIMIT",
+	PriceTypeStopLossMarket: "SL-MARKET",
+	PriceTypeDisclosed:      "DS",
+	PriceTypeTwoLeg:         "TWOLEG",
+	PriceTypeThreeLeg:       "THREELEG",
+}
+
+// ExchangeName returns the lower case name of an exchange identifier, as used
+// in quote symbols, or "" if it is unknown.
+func ExchangeName(exchange int) string {
+	return exchangeNames[exchange]
+}
+
+// QuoteSymbol returns the exchange:trading_symbol form GetPrice expects.
+func QuoteSymbol(exchange int, tradingSymbol string) string {
+	return fmt.Sprintf("%s:%s", ExchangeName(exchange), tradingSymbol)
+}
+
+// ProductName returns the upper case name of a product type, or "" if it is
+// unknown.
+func ProductName(orderType int) string {
+	return productNames[orderType]
+}
+
+// TransactionName returns BUY or SELL.
+func TransactionName(transactionType int) string {
+	switch transactionType {
+	case TransactionBuy:
+		return "BUY"
+	case TransactionSell:
+		return "SELL"
+	}
+	return fmt.Sprintf("transaction type %d", transactionType)
+}
+
+// PriceTypeName returns a readable name of a price type.
+func PriceTypeName(priceType int) string {
+	if name, ok := priceTypeNames[priceType]; ok {
+		return name
+	}
+	return fmt.Sprintf("price type %d", priceType)
+}
+
+// IsMarketPrice reports whether orders of the price type execute at the
+// market price, without a limit price.
+func IsMarketPrice(priceType int) bool {
+	return priceType == PriceTypeMarket || priceType == PriceTypeStopLossMarket
+}
+
+// IsStopLoss reports whether orders of the price type need a trigger price.
+func IsStopLoss(priceType int) bool {
+	return priceType == PriceTypeStopLossLimit || priceType == PriceTypeStopLossMarket
+}
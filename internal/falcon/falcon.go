@@ -13,9 +13,10 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strings"
 	"sync"
-
-	"github.com/wealthy/wealthy-mcp/internal"
+	"time"
 )
 
 var (
@@ -32,43 +33,66 @@ var (
 // FalconService defines the interface for Falcon API operations
 type FalconService interface {
 	//order
-	PlaceOrder(ctx context.Context, req []OrderReq) ([]placeOrderResponse, error)
-	ModifyOrder(ctx context.Context, req ModifyOrderReq) (*placeOrderResponse, error)
+	PlaceOrder(ctx context.Context, req []OrderReq) ([]PlaceOrderResponse, error)
+	ModifyOrder(ctx context.Context, req ModifyOrderReq) (*PlaceOrderResponse, error)
 	CancelOrder(ctx context.Context, req CancelOrderReq) (any, error)
+	ValidateOrder(ctx context.Context, req *OrderReq) error
 	//reports
-	GetHoldings(ctx context.Context) (any, error)
-	GetPositions(ctx context.Context) (any, error)
-	GetOrderBook(ctx context.Context) (any, error)
-	GetPrice(ctx context.Context, req *PriceReq) (any, error)
+	GetHoldings(ctx context.Context) (Holdings, error)
+	GetPositions(ctx context.Context) (Positions, error)
+	GetOrderBook(ctx context.Context) (OrderBook, error)
+	GetPrice(ctx context.Context, req *PriceReq) (Quotes, error)
+	GetHistoricalCandles(ctx context.Context, exchange int, token string, interval CandleInterval, from, to time.Time) (Candles, error)
 	//research
-	GetTradeIdeas(ctx context.Context) (any, error)
+	GetTradeIdeas(ctx context.Context) (TradeIdeas, error)
 	GetSecurityInfo(ctx context.Context, req *SecurityInfoReq) (any, error)
 	//watchlist
 	AddToWatchlist(ctx context.Context, req *WatchlistReq) (any, error)
 	GetWatchlists(ctx context.Context) (any, error)
+	GetWatchlist(ctx context.Context, name string) (any, error)
 	CreateWatchlist(ctx context.Context, name string) (any, error)
 	//margin
-	GetUserMargin(ctx context.Context) (any, error)
+	GetUserMargin(ctx context.Context) (*Margin, error)
+	//stream
+	GetWebsocketURL(ctx context.Context) (string, error)
 }
 
 type falconService struct {
 	client       *http.Client
 	baseURL      string
 	midasBaseURl string
+	searchURL    string
+	// instruments caches security search results by exchange:trading_symbol
+	instruments sync.Map
+	// history caches historical prices on disk, nil disables the cache
+	history *historyCache
 }
 
 // NewFalconService creates a new instance of FalconService
 func NewFalconService(client *http.Client) FalconService {
-	return &falconService{
+	s := &falconService{
 		client:       client,
 		baseURL:      falconBaseURL,
 		midasBaseURl: midasBaseURL,
+		searchURL:    searchURL,
+	}
+	if dir, err := DefaultHistoryDir(); err == nil {
+		s.history = newHistoryCache(dir)
+	} else {
+		slog.Warn("historical price cache disabled", "error", err)
 	}
+	return s
 }
 
 // PlaceOrder places a new order
-func (s *falconService) PlaceOrder(ctx context.Context, req []OrderReq) ([]placeOrderResponse, error) {
+func (s *falconService) PlaceOrder(ctx context.Context, req []OrderReq) ([]PlaceOrderResponse, error) {
 	for i := range req {
+		if err := s.ValidateOrder(ctx, &req[i]); err != nil {
+			if len(req) > 1 {
+				return nil, fmt.Errorf("order %d: %w", i+1, err)
+			}
+			return nil, err
+		}
 		req[i].OrderSource = 5
 	}
 	orderJSON, err := json.Marshal(req)
@@ -83,9 +107,7 @@ func (s *falconService) PlaceOrder(ctx context.Context, req []OrderReq) ([]place
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	httpReq.Header.Set("Authorization", internal.AuthToken)
-
-	var resp []placeOrderResponse
+	var resp []PlaceOrderResponse
 
 	if err := callRestAPI(ctx, httpReq, &resp, s.client); err != nil {
 		return nil, fmt.Errorf("failed to place order: %w", err)
@@ -94,15 +116,14 @@ func (s *falconService) PlaceOrder(ctx context.Context, req []OrderReq) ([]place
 }
 
 // GetHoldings retrieves holdings for an account
-func (s *falconService) GetHoldings(ctx context.Context) (any, error) {
+func (s *falconService) GetHoldings(ctx context.Context) (Holdings, error) {
 	url := fmt.Sprintf("%s/v1/report/holdings/", s.baseURL)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
-	httpReq.Header.Set("Authorization", internal.AuthToken)
 
-	var resp any
+	var resp Holdings
 	if err := callRestAPI(ctx, httpReq, &resp, s.client); err != nil {
 		return nil, fmt.Errorf("failed to get holdings: %w", err)
 	}
@@ -110,37 +131,35 @@ func (s *falconService) GetHoldings(ctx context.Context) (any, error) {
 }
 
 // GetPositions retrieves positions for an account
-func (s *falconService) GetPositions(ctx context.Context) (any, error) {
+func (s *falconService) GetPositions(ctx context.Context) (Positions, error) {
 	url := fmt.Sprintf("%s/v0/report/positions/", s.baseURL)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
-	httpReq.Header.Set("Authorization", internal.AuthToken)
 
-	var resp any
+	var resp Positions
 	if err := callRestAPI(ctx, httpReq, &resp, s.client); err != nil {
 		return nil, fmt.Errorf("failed to get positions: %w", err)
 	}
 	return resp, nil
 }
 
-func (s *falconService) GetOrderBook(ctx context.Context) (any, error) {
+func (s *falconService) GetOrderBook(ctx context.Context) (OrderBook, error) {
 	url := fmt.Sprintf("%s/v0/report/orders/", s.baseURL)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
-	httpReq.Header.Set("Authorization", internal.AuthToken)
 
-	var resp any
+	var resp OrderBook
 	if err := callRestAPI(ctx, httpReq, &resp, s.client); err != nil {
 		return nil, fmt.Errorf("failed to get order book: %w", err)
 	}
 	return resp, nil
 }
 
-func (s *falconService) GetPrice(ctx context.Context, req *PriceReq) (any, error) {
+func (s *falconService) GetPrice(ctx context.Context, req *PriceReq) (Quotes, error) {
 	req.Mode = 3
 	url := fmt.Sprintf("%s/v1/stock/quotes/", s.baseURL)
 	jsonReq, _ := json.Marshal(req)
@@ -148,23 +167,22 @@ func (s *falconService) GetPrice(ctx context.Context, req *PriceReq) (any, error
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
-	httpReq.Header.Set("Authorization", internal.AuthToken)
 
-	var resp any
+	var resp Quotes
 	if err := callRestAPI(ctx, httpReq, &resp, s.client); err != nil {
 		return nil, fmt.Errorf("failed to get price: %w", err)
 	}
 	return resp, nil
 }
 
-func (s *falconService) GetTradeIdeas(ctx context.Context) (any, error) {
+func (s *falconService) GetTradeIdeas(ctx context.Context) (TradeIdeas, error) {
 	url := fmt.Sprintf("%s/v0/idea/?status=2", s.midasBaseURl)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	var resp any
+	var resp TradeIdeas
 	if err := callRestAPI(ctx, httpReq, &resp, s.client); err != nil {
 		return nil, fmt.Errorf("failed to get trade ideas: %w, browse internet for trade ideas", err)
 	}
@@ -172,7 +190,7 @@ func (s *falconService) GetTradeIdeas(ctx context.Context) (any, error) {
 }
 
 func (s *falconService) GetSecurityInfo(ctx context.Context, req *SecurityInfoReq) (any, error) {
-	url := fmt.Sprintf(searchURL, req.Name)
+	url := fmt.Sprintf(s.searchEndpoint(), req.Name)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -185,12 +203,13 @@ func (s *falconService) GetSecurityInfo(ctx context.Context, req *SecurityInfoRe
 	return resp, nil
 }
 
+// GetWebsocketURL returns the URL of the streaming feed, authenticated for
+// the current login.
 func (s *falconService) GetWebsocketURL(ctx context.Context) (string, error) {
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, wsURL, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
-	httpReq.Header.Set("Authorization", internal.AuthToken)
 
 	var resp WebsocketURLResponse
 	if err := callRestAPI(ctx, httpReq, &resp, s.client); err != nil {
@@ -207,7 +226,6 @@ func (s *falconService) AddToWatchlist(ctx context.Context, req *WatchlistReq) (
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
-	httpReq.Header.Set("Authorization", internal.AuthToken)
 
 	var resp any
 	if err := callRestAPI(ctx, httpReq, &resp, s.client); err != nil {
@@ -229,16 +247,9 @@ func (s *falconService) GetWatchlists(ctx context.Context) (any, error) {
 		var mu sync.Mutex
 		wg.Add(1)
 		go func(n string) {
-			var resp any
 			defer wg.Done()
-			url := fmt.Sprintf("%s/v0/watchlist/", s.baseURL)
-			jsonReq, _ := json.Marshal(WatchlistReq{Name: n})
-			httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonReq))
+			resp, err := s.GetWatchlist(ctx, n)
 			if err != nil {
-				return
-			}
-			httpReq.Header.Set("Authorization", internal.AuthToken)
-			if err := callRestAPI(ctx, httpReq, &resp, s.client); err != nil {
 				mu.Lock()
 				userWatchlists = append(userWatchlists, map[string]any{n: nil})
 				mu.Unlock()
@@ -253,13 +264,28 @@ func (s *falconService) GetWatchlists(ctx context.Context) (any, error) {
 	return userWatchlists, nil
 }
 
+// GetWatchlist returns the securities of one watchlist.
+func (s *falconService) GetWatchlist(ctx context.Context, name string) (any, error) {
+	url := fmt.Sprintf("%s/v0/watchlist/", s.baseURL)
+	jsonReq, _ := json.Marshal(WatchlistReq{Name: name})
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonReq))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	var resp any
+	if err := callRestAPI(ctx, httpReq, &resp, s.client); err != nil {
+		return nil, fmt.Errorf("failed to get watchlist: %w", err)
+	}
+	return resp, nil
+}
+
 func (s *falconService) getAllWatchlists(ctx context.Context) (any, error) {
 	url := fmt.Sprintf("%s/v0/watchlist/", s.baseURL)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
-	httpReq.Header.Set("Authorization", internal.AuthToken)
 
 	var resp any
 	if err := callRestAPI(ctx, httpReq, &resp, s.client); err != nil {
@@ -274,7 +300,6 @@ func (s *falconService) getWatchlistNames(ctx context.Context) ([]string, error)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
-	httpReq.Header.Set("Authorization", internal.AuthToken)
 
 	var resp []string
 	if err := callRestAPI(ctx, httpReq, &resp, s.client); err != nil {
@@ -290,7 +315,6 @@ func (s *falconService) CreateWatchlist(ctx context.Context, name string) (any,
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
-	httpReq.Header.Set("Authorization", internal.AuthToken)
 
 	var resp any
 	if err := callRestAPI(ctx, httpReq, &resp, s.client); err != nil {
@@ -299,16 +323,21 @@ func (s *falconService) CreateWatchlist(ctx context.Context, name string) (any,
 	return resp, nil
 }
 
-func (s *falconService) ModifyOrder(ctx context.Context, req ModifyOrderReq) (*placeOrderResponse, error) {
+func (s *falconService) ModifyOrder(ctx context.Context, req ModifyOrderReq) (*PlaceOrderResponse, error) {
+	if req.OrderID == "" {
+		return nil, &ValidationError{Errors: []FieldError{{Field: "order_id", Message: "is required"}}}
+	}
+	if err := s.ValidateOrder(ctx, &req.OrderReq); err != nil {
+		return nil, err
+	}
 	url := fmt.Sprintf("%s/v0/order/%s/", s.baseURL, req.OrderID)
 	jsonReq, _ := json.Marshal(req)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewBuffer(jsonReq))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
-	httpReq.Header.Set("Authorization", internal.AuthToken)
 
-	var resp placeOrderResponse
+	var resp PlaceOrderResponse
 	if err := callRestAPI(ctx, httpReq, &resp, s.client); err != nil {
 		return nil, fmt.Errorf("failed to modify order: %w", err)
 	}
@@ -322,7 +351,6 @@ func (s *falconService) CancelOrder(ctx context.Context, req CancelOrderReq) (an
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
-	httpReq.Header.Set("Authorization", internal.AuthToken)
 
 	var resp any
 	if err := callRestAPI(ctx, httpReq, &resp, s.client); err != nil {
@@ -331,17 +359,74 @@ func (s *falconService) CancelOrder(ctx context.Context, req CancelOrderReq) (an
 	return resp, nil
 }
 
-func (s *falconService) GetUserMargin(ctx context.Context) (any, error) {
+func (s *falconService) GetUserMargin(ctx context.Context) (*Margin, error) {
 	url := fmt.Sprintf("%s/v0/report/fund-limits/", s.baseURL)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
-	httpReq.Header.Set("Authorization", internal.AuthToken)
 
-	var resp any
+	var resp Margin
 	if err := callRestAPI(ctx, httpReq, &resp, s.client); err != nil {
 		return nil, fmt.Errorf("failed to get user margin: %w", err)
 	}
-	return resp, nil
+	return &resp, nil
+}
+
+// ValidateOrder checks an order before it is sent and rounds its prices to
+// the tick size of the instrument. Tick and lot sizes are looked up with the
+// security search, when the lookup fails only the instrument independent
+// checks are applied.
+func (s *falconService) ValidateOrder(ctx context.Context, req *OrderReq) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	instr, err := s.lookupInstrument(ctx, req.ExchangeName, req.TradingSymbol)
+	if err != nil {
+		slog.Warn("instrument lookup failed, skipping tick and lot size checks", "symbol", req.TradingSymbol, "error", err)
+	}
+	return validateOrder(req, instr)
+}
+
+// lookupInstrument finds the instrument of an exchange and trading symbol,
+// nil if the search does not know it.
+func (s *falconService) lookupInstrument(ctx context.Context, exchange int, tradingSymbol string) (*Instrument, error) {
+	key := QuoteSymbol(exchange, strings.ToUpper(tradingSymbol))
+	if instr, ok := s.instruments.Load(key); ok {
+		return instr.(*Instrument), nil
+	}
+
+	query := strings.TrimSuffix(strings.ToUpper(tradingSymbol), "-EQ")
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(s.searchEndpoint(), url.QueryEscape(query)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	var resp struct {
+		Stocks []Instrument `json:"stocks"`
+	}
+	if err := callRestAPI(ctx, httpReq, &resp, s.client); err != nil {
+		return nil, fmt.Errorf("failed to search security: %w", err)
+	}
+	for i := range resp.Stocks {
+		instr := &resp.Stocks[i]
+		if instr.ExchangeName == exchange && sameSymbol(instr.TradingSymbol, tradingSymbol) {
+			s.instruments.Store(key, instr)
+			return instr, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *falconService) searchEndpoint() string {
+	if s.searchURL != "" {
+		return s.searchURL
+	}
+	return searchURL
+}
+
+// sameSymbol compares trading symbols, ignoring the -EQ suffix of equities.
+func sameSymbol(a, b string) bool {
+	a = strings.TrimSuffix(strings.ToUpper(a), "-EQ")
+	b = strings.TrimSuffix(strings.ToUpper(b), "-EQ")
+	return a == b
 }
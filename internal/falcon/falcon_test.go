@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -23,6 +24,7 @@ func setupTestServer(t *testing.T, handler http.HandlerFunc) (*falconService, *h
 		client:       client,
 		baseURL:      server.URL,
 		midasBaseURl: server.URL,
+		searchURL:    server.URL + "/search/?q=%s&pt=stocks",
 	}
 	return service, server
 }
@@ -44,6 +46,8 @@ func TestPlaceOrder(t *testing.T) {
 					Quantity:        100,
 					Price:           "150.00",
 					TransactionType: 1,
+					PriceType:       PriceTypeLimit,
+					Validity:        ValidityDay,
 				},
 			},
 			want: &Order{
@@ -76,6 +80,10 @@ func TestPlaceOrder(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			service, server := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/search/" {
+					w.Write([]byte(`{"stocks":[]}`))
+					return
+				}
 				assert.Equal(t, http.MethodPost, r.Method)
 				assert.Equal(t, "/v0/order/create/", r.URL.Path)
 
@@ -122,23 +130,31 @@ func TestPlaceOrder(t *testing.T) {
 func TestGetHoldings(t *testing.T) {
 	tests := []struct {
 		name    string
-		want    interface{}
+		body    string
+		want    Holdings
 		wantErr bool
 	}{
 		{
 			name: "successful holdings retrieval",
-			want: map[string]interface{}{
-				"data": map[string]interface{}{
-					"holdings": []interface{}{
-						map[string]interface{}{
-							"symbol": "AAPL",
-							"qty":    float64(100),
-						},
-					},
-				},
+			body: `{"data":{"holdings":[{"trading_symbol":"RELIANCE-EQ","exchange_name":1,"token":"2885","quantity":10,"t1_quantity":2,"average_price":"245050","ltp":250000}]}}`,
+			want: Holdings{
+				{TradingSymbol: "RELIANCE-EQ", ExchangeName: 1, Token: "2885", Quantity: 10, T1Quantity: 2, AveragePrice: Paisa(245050), LastPrice: Paisa(250000)},
 			},
 			wantErr: false,
 		},
+		{
+			name: "bare list",
+			body: `[{"trading_symbol":"INFY-EQ","quantity":1,"average_price":"150000.5"}]`,
+			want: Holdings{
+				{TradingSymbol: "INFY-EQ", Quantity: 1, AveragePrice: Paisa(150001)},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "invalid money",
+			body:    `[{"trading_symbol":"INFY-EQ","average_price":"abc"}]`,
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -146,13 +162,7 @@ func TestGetHoldings(t *testing.T) {
 			service, server := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
 				assert.Equal(t, http.MethodGet, r.Method)
 				assert.Equal(t, "/v1/report/holdings/", r.URL.Path)
-
-				if tt.wantErr {
-					w.WriteHeader(http.StatusBadRequest)
-					return
-				}
-
-				json.NewEncoder(w).Encode(tt.want)
+				w.Write([]byte(tt.body))
 			})
 			defer server.Close()
 
@@ -167,23 +177,25 @@ func TestGetHoldings(t *testing.T) {
 	}
 }
 
+func TestHoldingPnL(t *testing.T) {
+	h := Holding{Quantity: 10, T1Quantity: 2, AveragePrice: Paisa(245050), LastPrice: Paisa(250000)}
+	assert.Equal(t, Paisa(2940600), h.Invested())
+	assert.Equal(t, Paisa(3000000), h.Value())
+	assert.Equal(t, "594.00", h.PnL().String())
+}
+
 func TestGetPositions(t *testing.T) {
 	tests := []struct {
 		name    string
-		want    interface{}
+		body    string
+		want    Positions
 		wantErr bool
 	}{
 		{
 			name: "successful positions retrieval",
-			want: map[string]interface{}{
-				"data": map[string]interface{}{
-					"positions": []interface{}{
-						map[string]interface{}{
-							"symbol": "AAPL",
-							"qty":    float64(100),
-						},
-					},
-				},
+			body: `{"data":{"positions":[{"trading_symbol":"TATAMOTORS-EQ","net_quantity":-5,"sell_average_price":"98000","realised_pnl":"-1250","unrealised_pnl":"3000"}]}}`,
+			want: Positions{
+				{TradingSymbol: "TATAMOTORS-EQ", NetQuantity: -5, SellAveragePrice: Paisa(98000), RealisedPnL: Paisa(-1250), UnrealisedPnL: Paisa(3000)},
 			},
 			wantErr: false,
 		},
@@ -194,13 +206,7 @@ func TestGetPositions(t *testing.T) {
 			service, server := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
 				assert.Equal(t, http.MethodGet, r.Method)
 				assert.Equal(t, "/v0/report/positions/", r.URL.Path)
-
-				if tt.wantErr {
-					w.WriteHeader(http.StatusBadRequest)
-					return
-				}
-
-				json.NewEncoder(w).Encode(tt.want)
+				w.Write([]byte(tt.body))
 			})
 			defer server.Close()
 
@@ -211,6 +217,7 @@ func TestGetPositions(t *testing.T) {
 			}
 			require.NoError(t, err)
 			assert.Equal(t, tt.want, got)
+			assert.Equal(t, Paisa(1750), got[0].PnL())
 		})
 	}
 }
@@ -218,23 +225,22 @@ func TestGetPositions(t *testing.T) {
 func TestGetOrderBook(t *testing.T) {
 	tests := []struct {
 		name    string
-		want    interface{}
+		body    string
+		want    OrderBook
 		wantErr bool
 	}{
 		{
 			name: "successful order book retrieval",
-			want: map[string]interface{}{
-				"data": map[string]interface{}{
-					"orders": []interface{}{
-						map[string]interface{}{
-							"symbol": "AAPL",
-							"qty":    float64(100),
-						},
-					},
-				},
+			body: `{"data":{"orders":[{"order_id":"1","trading_symbol":"IOC-EQ","quantity":100,"filled_shares":40,"price":"14050","average_price":"14045","status":2}]}}`,
+			want: OrderBook{
+				{OrderID: "1", TradingSymbol: "IOC-EQ", Quantity: 100, FilledShares: 40, Price: Paisa(14050), AveragePrice: Paisa(14045), Status: 2},
 			},
 			wantErr: false,
 		},
+		{
+			name:    "server error",
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -247,8 +253,7 @@ func TestGetOrderBook(t *testing.T) {
 					w.WriteHeader(http.StatusBadRequest)
 					return
 				}
-
-				json.NewEncoder(w).Encode(tt.want)
+				w.Write([]byte(tt.body))
 			})
 			defer server.Close()
 
@@ -259,6 +264,7 @@ func TestGetOrderBook(t *testing.T) {
 			}
 			require.NoError(t, err)
 			assert.Equal(t, tt.want, got)
+			assert.Equal(t, 60, got[0].PendingQuantity())
 		})
 	}
 }
@@ -267,20 +273,18 @@ func TestGetPrice(t *testing.T) {
 	tests := []struct {
 		name    string
 		req     *PriceReq
-		want    interface{}
+		body    string
+		want    Quotes
 		wantErr bool
 	}{
 		{
 			name: "successful price retrieval",
 			req: &PriceReq{
-				Symbols: []string{"AAPL"},
+				Symbols: []string{"nse:RELIANCE-EQ"},
 			},
-			want: map[string]interface{}{
-				"data": map[string]interface{}{
-					"AAPL": map[string]interface{}{
-						"price": "150.00",
-					},
-				},
+			body: `{"data":{"nse:RELIANCE-EQ":{"ltp":250000,"open":"248000","close":"249000","volume":120000}}}`,
+			want: Quotes{
+				"nse:RELIANCE-EQ": {LastPrice: Paisa(250000), Open: Paisa(248000), Close: Paisa(249000), Volume: 120000},
 			},
 			wantErr: false,
 		},
@@ -291,13 +295,7 @@ func TestGetPrice(t *testing.T) {
 			service, server := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
 				assert.Equal(t, http.MethodPost, r.Method)
 				assert.Equal(t, "/v1/stock/quotes/", r.URL.Path)
-
-				if tt.wantErr {
-					w.WriteHeader(http.StatusBadRequest)
-					return
-				}
-
-				json.NewEncoder(w).Encode(tt.want)
+				w.Write([]byte(tt.body))
 			})
 			defer server.Close()
 
@@ -315,20 +313,15 @@ func TestGetPrice(t *testing.T) {
 func TestGetTradeIdeas(t *testing.T) {
 	tests := []struct {
 		name    string
-		want    interface{}
+		body    string
+		want    TradeIdeas
 		wantErr bool
 	}{
 		{
 			name: "successful trade ideas retrieval",
-			want: map[string]interface{}{
-				"data": map[string]interface{}{
-					"ideas": []interface{}{
-						map[string]interface{}{
-							"symbol": "AAPL",
-							"action": "BUY",
-						},
-					},
-				},
+			body: `{"data":{"ideas":[{"id":7,"trading_symbol":"INFY-EQ","transaction_type":1,"entry_price":"150000","target_price":"165000","stop_loss_price":"142000"}]}}`,
+			want: TradeIdeas{
+				{ID: 7, TradingSymbol: "INFY-EQ", TransactionType: 1, EntryPrice: Paisa(150000), TargetPrice: Paisa(165000), StopLossPrice: Paisa(142000)},
 			},
 			wantErr: false,
 		},
@@ -340,13 +333,7 @@ func TestGetTradeIdeas(t *testing.T) {
 				assert.Equal(t, http.MethodGet, r.Method)
 				assert.Equal(t, "/v0/idea/", r.URL.Path)
 				assert.Equal(t, "2", r.URL.Query().Get("status"))
-
-				if tt.wantErr {
-					w.WriteHeader(http.StatusBadRequest)
-					return
-				}
-
-				json.NewEncoder(w).Encode(tt.want)
+				w.Write([]byte(tt.body))
 			})
 			defer server.Close()
 
@@ -361,6 +348,19 @@ func TestGetTradeIdeas(t *testing.T) {
 	}
 }
 
+func TestGetUserMargin(t *testing.T) {
+	service, server := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v0/report/fund-limits/", r.URL.Path)
+		w.Write([]byte(`{"cash":"1000000","margin_used":"250050"}`))
+	})
+	defer server.Close()
+
+	got, err := service.GetUserMargin(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &Margin{Cash: Paisa(1000000), MarginUsed: Paisa(250050)}, got)
+	assert.Equal(t, "7499.50", got.Available().String())
+}
+
 func TestGetSecurityInfo(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -555,17 +555,13 @@ func TestGetWatchlists(t *testing.T) {
 }
 
 func TestGetWebsocketURL(t *testing.T) {
-	// Save the original auth token, stage, and wsURL, and restore them after the test
-	origAuthToken := internal.AuthToken
-	origAuthStage := internal.AuthStage
+	// Log in with a test token and restore the auth state and wsURL after the test
 	origWsURL := wsURL
 	defer func() {
-		internal.AuthToken = origAuthToken
-		internal.AuthStage = origAuthStage
+		internal.Auth.Reset()
 		wsURL = origWsURL
 	}()
-	internal.AuthToken = "test-token"
-	internal.AuthStage = internal.AUTH_SUCCESS
+	internal.Auth.Succeed("test-token", time.Time{})
 
 	tests := []struct {
 		name    string
@@ -610,3 +606,46 @@ func TestGetWebsocketURL(t *testing.T) {
 		})
 	}
 }
+
+func TestCallRestAPIReplaysAfterLogin(t *testing.T) {
+	defer internal.Auth.Reset()
+	internal.Auth.Succeed("stale-token", time.Time{})
+
+	var calls int
+	service, server := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/search/" {
+			w.Write([]byte(`{"stocks":[]}`))
+			return
+		}
+		calls++
+		if r.Header.Get("Authorization") != "fresh-token" {
+			// Another caller is already driving the login, which completes shortly.
+			internal.Auth.Invalidate("stale-token")
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				internal.Auth.Succeed("fresh-token", time.Time{})
+			}()
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var order []OrderReq
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&order))
+		require.Len(t, order, 1)
+		json.NewEncoder(w).Encode([]PlaceOrderResponse{{TradingSymbol: order[0].TradingSymbol}})
+	})
+	defer server.Close()
+
+	order := OrderReq{
+		ExchangeName:    ExchangeNSE,
+		TradingSymbol:   "IOC-EQ",
+		Quantity:        1,
+		TransactionType: TransactionBuy,
+		PriceType:       PriceTypeMarket,
+		Validity:        ValidityDay,
+	}
+	got, err := service.PlaceOrder(context.Background(), []OrderReq{order})
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	require.Len(t, got, 1)
+	assert.Equal(t, "IOC-EQ", got[0].TradingSymbol)
+}
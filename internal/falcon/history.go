@@ -0,0 +1,383 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package falcon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// IST is the time zone of the Indian exchanges.
+var IST = time.FixedZone("IST", 5*3600+30*60)
+
+// CandleInterval is the size of the bars of historical prices.
+type CandleInterval string
+
+const (
+	Interval1Minute   CandleInterval = "1m"
+	Interval5Minutes  CandleInterval = "5m"
+	Interval15Minutes CandleInterval = "15m"
+	Interval1Hour     CandleInterval = "1h"
+	Interval1Day      CandleInterval = "1d"
+)
+
+// ParseCandleInterval checks the name of an interval.
+func ParseCandleInterval(s string) (CandleInterval, error) {
+	switch i := CandleInterval(s); i {
+	case Interval1Minute, Interval5Minutes, Interval15Minutes, Interval1Hour, Interval1Day:
+		return i, nil
+	default:
+		return "", fmt.Errorf("invalid interval %q, must be 1m, 5m, 15m, 1h or 1d", s)
+	}
+}
+
+// Candle is an OHLC bar of historical prices. It decodes from the
+// [time, open, high, low, close, volume, open_interest] rows of the API,
+// with prices in paisa.
+type Candle struct {
+	Time         time.Time `json:"time"`
+	Open         Money     `json:"open"`
+	High         Money     `json:"high"`
+	Low          Money     `json:"low"`
+	Close        Money     `json:"close"`
+	Volume       int64     `json:"volume"`
+	OpenInterest int64     `json:"open_interest,omitempty"`
+	// Filled marks a bar added for a weekday without trading, such as an
+	// exchange holiday, at the previous close
+	Filled bool `json:"filled,omitempty"`
+}
+
+func (c *Candle) UnmarshalJSON(data []byte) error {
+	var row []json.RawMessage
+	if err := json.Unmarshal(data, &row); err != nil {
+		return fmt.Errorf("candle is not a row: %w", err)
+	}
+	if len(row) < 6 {
+		return fmt.Errorf("candle row has %d fields, expected at least 6", len(row))
+	}
+	var at string
+	if err := json.Unmarshal(row[0], &at); err != nil {
+		return fmt.Errorf("invalid candle time: %w", err)
+	}
+	t, err := time.Parse(time.RFC3339, at)
+	if err != nil {
+		return fmt.Errorf("invalid candle time: %w", err)
+	}
+	*c = Candle{Time: t.In(IST)}
+	for i, m := range []*Money{&c.Open, &c.High, &c.Low, &c.Close} {
+		if err := m.UnmarshalJSON(row[i+1]); err != nil {
+			return fmt.Errorf("invalid candle price: %w", err)
+		}
+	}
+	counts := []*int64{&c.Volume, &c.OpenInterest}
+	for i, raw := range row[5:min(len(row), 7)] {
+		var n json.Number
+		if err := json.Unmarshal(raw, &n); err != nil {
+			return fmt.Errorf("invalid candle volume: %w", err)
+		}
+		if *counts[i], err = n.Int64(); err != nil {
+			return fmt.Errorf("invalid candle volume: %w", err)
+		}
+	}
+	return nil
+}
+
+// row is the candle as the API sends it, which is how the cache keeps it.
+func (c Candle) row() []any {
+	return []any{c.Time.Format(time.RFC3339), c.Open.Paisa(), c.High.Paisa(), c.Low.Paisa(), c.Close.Paisa(), c.Volume, c.OpenInterest}
+}
+
+// Candles are the bars of a range, oldest first.
+type Candles []Candle
+
+func (c *Candles) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(unwrapList(data, "candles"), (*[]Candle)(c))
+}
+
+// FillHolidays adds a flat bar at the previous close for every weekday
+// without one between the first and the last daily bar, so the days of an
+// exchange holiday are not missing from the series.
+func FillHolidays(candles Candles) Candles {
+	if len(candles) == 0 {
+		return candles
+	}
+	filled := Candles{candles[0]}
+	for _, c := range candles[1:] {
+		prev := filled[len(filled)-1]
+		for day := nextDay(prev.Time); day.Before(c.Time) && !sameDay(day, c.Time); day = nextDay(day) {
+			if wd := day.In(IST).Weekday(); wd == time.Saturday || wd == time.Sunday {
+				continue
+			}
+			filled = append(filled, Candle{Time: day, Open: prev.Close, High: prev.Close, Low: prev.Close, Close: prev.Close, Filled: true})
+		}
+		filled = append(filled, c)
+	}
+	return filled
+}
+
+func nextDay(t time.Time) time.Time {
+	return t.In(IST).AddDate(0, 0, 1)
+}
+
+func sameDay(a, b time.Time) bool {
+	return a.In(IST).Format(time.DateOnly) == b.In(IST).Format(time.DateOnly)
+}
+
+// GetHistoricalCandles returns the bars of an instrument between from and to,
+// inclusive. Ranges already fetched before today are served from the local
+// cache, only the missing parts are requested. A fetched range without bars,
+// such as a holiday, is remembered as fetched too.
+func (s *falconService) GetHistoricalCandles(ctx context.Context, exchange int, token string, interval CandleInterval, from, to time.Time) (Candles, error) {
+	if _, err := ParseCandleInterval(string(interval)); err != nil {
+		return nil, err
+	}
+	if ExchangeName(exchange) == "" {
+		return nil, fmt.Errorf("invalid exchange %d", exchange)
+	}
+	if token == "" {
+		return nil, errors.New("token is required")
+	}
+	if to.Before(from) {
+		return nil, fmt.Errorf("from %s is after to %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+
+	if s.history == nil {
+		return s.fetchCandles(ctx, exchange, token, interval, from, to)
+	}
+	return s.history.get(exchange, token, interval, from, to, func(from, to time.Time) (Candles, error) {
+		return s.fetchCandles(ctx, exchange, token, interval, from, to)
+	})
+}
+
+func (s *falconService) fetchCandles(ctx context.Context, exchange int, token string, interval CandleInterval, from, to time.Time) (Candles, error) {
+	q := url.Values{}
+	q.Set("exchange", ExchangeName(exchange))
+	q.Set("token", token)
+	q.Set("interval", string(interval))
+	q.Set("from", from.Format(time.RFC3339))
+	q.Set("to", to.Format(time.RFC3339))
+	url := fmt.Sprintf("%s/v0/chart/history/?%s", s.baseURL, q.Encode())
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	var resp Candles
+	if err := callRestAPI(ctx, httpReq, &resp, s.client); err != nil {
+		return nil, fmt.Errorf("failed to get historical prices: %w", err)
+	}
+	return resp, nil
+}
+
+// DefaultHistoryDir returns the historical price cache location under the
+// user cache directory.
+func DefaultHistoryDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve cache dir: %w", err)
+	}
+	return filepath.Join(dir, "wealthy-mcp", "history"), nil
+}
+
+// timeRange is a fetched range, both ends inclusive.
+type timeRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// historyFile is the layout of the cache file of an instrument and interval.
+type historyFile struct {
+	Ranges  []timeRange `json:"ranges"`
+	Candles []any       `json:"candles"`
+}
+
+// historyCache keeps fetched bars on disk, one file per instrument and
+// interval, with the ranges they cover. Each file is locked on its own, so a
+// slow fetch only holds up requests for the same instrument and interval.
+type historyCache struct {
+	dir   string
+	mu    sync.Mutex
+	files map[string]*sync.Mutex
+	now   func() time.Time
+}
+
+func newHistoryCache(dir string) *historyCache {
+	return &historyCache{dir: dir, files: make(map[string]*sync.Mutex), now: time.Now}
+}
+
+// lock locks the cache file at path and returns its unlock.
+func (h *historyCache) lock(path string) func() {
+	h.mu.Lock()
+	mu, ok := h.files[path]
+	if !ok {
+		mu = &sync.Mutex{}
+		h.files[path] = mu
+	}
+	h.mu.Unlock()
+	mu.Lock()
+	return mu.Unlock
+}
+
+func (h *historyCache) path(exchange int, token string, interval CandleInterval) string {
+	return filepath.Join(h.dir, fmt.Sprintf("%s-%s-%s.json", ExchangeName(exchange), url.PathEscape(token), interval))
+}
+
+// get serves a range from the cache, fetching the parts it does not cover.
+// Bars from today on may still change, they are fetched every time and not
+// kept.
+func (h *historyCache) get(exchange int, token string, interval CandleInterval, from, to time.Time, fetch func(from, to time.Time) (Candles, error)) (Candles, error) {
+	path := h.path(exchange, token, interval)
+	defer h.lock(path)()
+	ranges, bars := h.load(path)
+
+	now := h.now().In(IST)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, IST)
+	changed := false
+	for _, gap := range missing(ranges, from, to) {
+		fetched, err := fetch(gap.From, gap.To)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range fetched {
+			bars[c.Time.Unix()] = c
+		}
+		if gap.From.Before(today) {
+			ranges = cover(ranges, timeRange{From: gap.From, To: minTime(gap.To, today.Add(-time.Nanosecond))})
+			changed = true
+		}
+	}
+	if changed {
+		h.save(path, ranges, bars, today)
+	}
+
+	candles := Candles{}
+	for _, c := range bars {
+		if !c.Time.Before(from) && !c.Time.After(to) {
+			candles = append(candles, c)
+		}
+	}
+	sort.Slice(candles, func(i, j int) bool {
+		return candles[i].Time.Before(candles[j].Time)
+	})
+	return candles, nil
+}
+
+// load reads a cache file, a missing or unreadable one is an empty cache.
+func (h *historyCache) load(path string) ([]timeRange, map[int64]Candle) {
+	bars := make(map[int64]Candle)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, bars
+	}
+	var f struct {
+		Ranges  []timeRange `json:"ranges"`
+		Candles []Candle    `json:"candles"`
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, bars
+	}
+	for _, c := range f.Candles {
+		bars[c.Time.Unix()] = c
+	}
+	return f.Ranges, bars
+}
+
+// save writes the bars before today, a failure only costs a refetch.
+func (h *historyCache) save(path string, ranges []timeRange, bars map[int64]Candle, today time.Time) {
+	f := historyFile{Ranges: ranges, Candles: []any{}}
+	keys := make([]int64, 0, len(bars))
+	for k, c := range bars {
+		if c.Time.Before(today) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	for _, k := range keys {
+		f.Candles = append(f.Candles, bars[k].row())
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(h.dir, 0o700); err != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+	}
+}
+
+// missing returns the parts of [from, to] the sorted, disjoint ranges do not
+// cover.
+func missing(ranges []timeRange, from, to time.Time) []timeRange {
+	var gaps []timeRange
+	start := from
+	for _, r := range ranges {
+		if r.To.Before(start) {
+			continue
+		}
+		if r.From.After(to) {
+			break
+		}
+		if r.From.After(start) {
+			gaps = append(gaps, timeRange{From: start, To: r.From.Add(-time.Nanosecond)})
+		}
+		start = r.To.Add(time.Nanosecond)
+		if start.After(to) {
+			return gaps
+		}
+	}
+	return append(gaps, timeRange{From: start, To: to})
+}
+
+// cover adds a range to sorted, disjoint ranges, merging the ones it
+// overlaps or touches.
+func cover(ranges []timeRange, add timeRange) []timeRange {
+	if add.To.Before(add.From) {
+		return ranges
+	}
+	ranges = append(ranges, add)
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].From.Before(ranges[j].From)
+	})
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if !r.From.After(last.To.Add(time.Nanosecond)) {
+			last.To = maxTime(last.To, r.To)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
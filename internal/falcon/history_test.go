@@ -0,0 +1,159 @@
+package falcon
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func day(d int) time.Time {
+	return time.Date(2025, 7, d, 0, 0, 0, 0, IST)
+}
+
+func TestGetHistoricalCandles(t *testing.T) {
+	service, server := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/v0/chart/history/", r.URL.Path)
+		q := r.URL.Query()
+		assert.Equal(t, "nse", q.Get("exchange"))
+		assert.Equal(t, "2885", q.Get("token"))
+		assert.Equal(t, "1d", q.Get("interval"))
+		assert.Equal(t, "2025-07-01T00:00:00+05:30", q.Get("from"))
+		w.Write([]byte(`{"data":{"candles":[["2025-07-01T09:15:00+05:30",245000,"246000",244000,245500,120000],["2025-07-02T09:15:00+05:30",245500,247000,245000,246500,90000,12]]}}`))
+	})
+	defer server.Close()
+
+	got, err := service.GetHistoricalCandles(context.Background(), ExchangeNSE, "2885", Interval1Day, day(1), day(3))
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, Candle{Time: day(1).Add(9*time.Hour + 15*time.Minute), Open: Paisa(245000), High: Paisa(246000), Low: Paisa(244000), Close: Paisa(245500), Volume: 120000}, got[0])
+	assert.Equal(t, int64(12), got[1].OpenInterest)
+
+	_, err = service.GetHistoricalCandles(context.Background(), ExchangeNSE, "2885", "1w", day(1), day(3))
+	assert.ErrorContains(t, err, "invalid interval")
+	_, err = service.GetHistoricalCandles(context.Background(), ExchangeNSE, "2885", Interval1Day, day(3), day(1))
+	assert.ErrorContains(t, err, "is after")
+}
+
+func TestHistoricalCandlesCache(t *testing.T) {
+	var mu sync.Mutex
+	var fetched [][2]string
+	service, server := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		mu.Lock()
+		fetched = append(fetched, [2]string{q.Get("from")[:10], q.Get("to")[:10]})
+		mu.Unlock()
+		from, _ := time.Parse(time.RFC3339, q.Get("from"))
+		to, _ := time.Parse(time.RFC3339, q.Get("to"))
+		// 5 and 6 July are a weekend, 7 July a holiday
+		body := `{"data":{"candles":[`
+		sep := ""
+		for d := 1; d <= 10; d++ {
+			at := day(d).Add(9*time.Hour + 15*time.Minute)
+			if d == 5 || d == 6 || d == 7 || at.Before(from) || at.After(to) {
+				continue
+			}
+			body += sep + `["` + at.Format(time.RFC3339) + `",100,110,90,105,1000]`
+			sep = ","
+		}
+		w.Write([]byte(body + `]}}`))
+	})
+	defer server.Close()
+	service.history = newHistoryCache(t.TempDir())
+	service.history.now = func() time.Time { return day(10).Add(12 * time.Hour) }
+	get := func(from, to time.Time) Candles {
+		t.Helper()
+		got, err := service.GetHistoricalCandles(context.Background(), ExchangeNSE, "2885", Interval1Day, from, to)
+		require.NoError(t, err)
+		return got
+	}
+	calls := func() [][2]string {
+		mu.Lock()
+		defer mu.Unlock()
+		calls := fetched
+		fetched = nil
+		return calls
+	}
+
+	assert.Len(t, get(day(1), day(8)), 4)
+	assert.Equal(t, [][2]string{{"2025-07-01", "2025-07-08"}}, calls())
+
+	assert.Len(t, get(day(2), day(7)), 3)
+	assert.Empty(t, calls(), "the holiday is served from the cache too")
+
+	assert.Len(t, get(day(1), day(9).Add(23*time.Hour)), 6)
+	assert.Equal(t, [][2]string{{"2025-07-08", "2025-07-09"}}, calls(), "only the missing part is fetched")
+
+	assert.Len(t, get(day(10), day(10).Add(23*time.Hour)), 1)
+	assert.Len(t, get(day(10), day(10).Add(23*time.Hour)), 1)
+	assert.Equal(t, [][2]string{{"2025-07-10", "2025-07-10"}, {"2025-07-10", "2025-07-10"}}, calls(), "today is fetched every time")
+
+	// the cache survives a restart
+	service.history = newHistoryCache(service.history.dir)
+	service.history.now = func() time.Time { return day(10).Add(12 * time.Hour) }
+	got := get(day(1), day(9).Add(23*time.Hour))
+	assert.Empty(t, calls())
+	require.Len(t, got, 6)
+	assert.Equal(t, Paisa(105), got[0].Close)
+}
+
+func TestHistoryCacheLocksPerFile(t *testing.T) {
+	h := newHistoryCache(t.TempDir())
+	h.now = func() time.Time { return day(10).Add(12 * time.Hour) }
+	release := make(chan struct{})
+	started := make(chan struct{})
+	slow := func(from, to time.Time) (Candles, error) {
+		close(started)
+		<-release
+		return Candles{}, nil
+	}
+	fast := func(from, to time.Time) (Candles, error) {
+		return Candles{}, nil
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := h.get(ExchangeNSE, "2885", Interval1Day, day(1), day(8), slow)
+		done <- err
+	}()
+	<-started
+
+	// Another instrument is not held up by the slow fetch
+	_, err := h.get(ExchangeNSE, "1594", Interval1Day, day(1), day(8), fast)
+	require.NoError(t, err)
+	select {
+	case <-done:
+		t.Fatal("the slow fetch returned early")
+	default:
+	}
+	close(release)
+	require.NoError(t, <-done)
+}
+
+func TestFillHolidays(t *testing.T) {
+	bar := func(d int, close int64) Candle {
+		return Candle{Time: day(d).Add(9*time.Hour + 15*time.Minute), Open: Paisa(close), High: Paisa(close), Low: Paisa(close), Close: Paisa(close), Volume: 10}
+	}
+	got := FillHolidays(Candles{bar(3, 100), bar(8, 120), bar(9, 130)})
+	require.Len(t, got, 5, "the weekend is not filled")
+	assert.Equal(t, day(4).Add(9*time.Hour+15*time.Minute), got[1].Time)
+	assert.Equal(t, day(7).Add(9*time.Hour+15*time.Minute), got[2].Time)
+	assert.Equal(t, Candle{Time: got[2].Time, Open: Paisa(100), High: Paisa(100), Low: Paisa(100), Close: Paisa(100), Filled: true}, got[2])
+	assert.Empty(t, FillHolidays(nil))
+}
+
+func TestMissingRanges(t *testing.T) {
+	covered := cover(nil, timeRange{From: day(1), To: day(3)})
+	covered = cover(covered, timeRange{From: day(6), To: day(8)})
+	assert.Equal(t, []timeRange{{From: day(3).Add(time.Nanosecond), To: day(6).Add(-time.Nanosecond)}}, missing(covered, day(2), day(7)))
+	assert.Empty(t, missing(covered, day(6), day(8)))
+	assert.Equal(t, []timeRange{{From: day(9), To: day(10)}}, missing(covered, day(9), day(10)))
+
+	covered = cover(covered, timeRange{From: day(3).Add(time.Nanosecond), To: day(6).Add(-time.Nanosecond)})
+	assert.Equal(t, []timeRange{{From: day(1), To: day(8)}}, covered, "touching ranges merge")
+}
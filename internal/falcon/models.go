@@ -11,7 +11,7 @@ import (
 )
 
 type FalconRequest struct {
-	QueryType string `json:"query_type" jsonschema:"description=Type of query (place_order/get_holdings/get_positions/get_security_info/get_order_book/get_price(prices are in paisa)/get_trade_ideas)"`
+	QueryType string `json:"query_type" jsonschema:"description=Type of query (place_order/get_holdings/get_positions/get_security_info/get_order_book/get_price(prices are in rupees)/get_trade_ideas)"`
 	OrderReq
 	SecurityInfoReq
 }
@@ -23,13 +23,14 @@ type BasketOrderReq struct {
 // OrderReq defines the schema for placing an order via MCP Falcon.
 //
 // Field rules:
-// - order_type: 1=Market, 2=Limit, 3=Stop, 4=Stop Limit
+// - order_type: the product, 1=CNC, 2=MIS, 3=NRML, 4=COVER, 5=BRACKET, 6=MTF
 // - price_type: 1=LMT (Limit), 2=MKT (Market), 3=SLLMT (Stop Loss Limit), 4=SLMKT (Stop Loss Market), 5=DS (Disclosed), 6=TWOLEG (Two Leg), 7=THREEELEG (Three Leg)
-// - price: REQUIRED for Limit (order_type=2) and Stop Limit (order_type=4) orders; OMIT or set to empty for Market orders
-// - trigger_price: REQUIRED for Stop and Stop Limit orders
+// - price: REQUIRED for LMT (price_type=1) and SLLMT (price_type=3) orders; OMIT or set to empty for Market orders
+// - trigger_price: REQUIRED for SLLMT and SLMKT orders, not allowed otherwise
 // - transaction_type: 1=Buy, 2=Sell
 //
-// See field-level comments for more details.
+// See field-level comments for more details, and Validate for the full set
+// of rules.
 type OrderReq struct {
 	// Exchange name identifier. NSE=1, NFO=2, BSE=3, BFO=4
 	ExchangeName int `json:"exchange_name" jsonschema:"description=Exchange name identifier, NSE=1, NFO=2, BSE=3, BFO=4"`
@@ -39,18 +40,18 @@ type OrderReq struct {
 	TradingSymbol string `json:"trading_symbol" jsonschema:"description=Symbol to trade"`
 	// Quantity to trade
 	Quantity int `json:"quantity" jsonschema:"description=Quantity to trade"`
-	// Price for the order. REQUIRED for Limit (order_type=2) and Stop Limit (order_type=4) orders. OMIT or set to empty for Market orders.
+	// Price for the order. REQUIRED for LMT (price_type=1) and SL LMT (price_type=3) orders. OMIT or set to empty for Market orders.
 	Price string `json:"price" jsonschema:"description=Price for the order. Required for LMT/SL LMT orders."`
-	// Trigger price for stop orders. REQUIRED for Stop (order_type=3) and Stop Limit (order_type=4) orders.
+	// Trigger price for stop orders. REQUIRED for SL LMT (price_type=3) and SL MKT (price_type=4) orders.
 	TriggerPrice string `json:"trigger_price,omitempty" jsonschema:"description=Trigger price for stop orders"`
-	// Type of order. 1=Market, 2=Limit, 3=Stop, 4=Stop Limit
-	OrderType int `json:"order_type" jsonschema:"description=Type of order, 1=Market, 2=Limit, 3=Stop, 4=Stop Limit"`
+	// Product of the order. 1=CNC, 2=MIS, 3=NRML, 4=COVER, 5=BRACKET, 6=MTF. Market, limit and stop orders are told apart by price_type.
+	OrderType int `json:"order_type" jsonschema:"description=Product of the order\\, 1=CNC (delivery)\\, 2=MIS (intraday)\\, 3=NRML (derivatives carry forward)\\, 4=COVER\\, 5=BRACKET\\, 6=MTF. Market and limit are set with price_type"`
 	// Transaction type. 1=Buy, 2=Sell
 	TransactionType int `json:"transaction_type" jsonschema:"description=Buy (1) or Sell (2)"`
 	// Price type for the order. 1=LMT (Limit), 2=MKT (Market), 3=SLLMT (Stop Loss Limit), 4=SLMKT (Stop Loss Market), 5=DS (Disclosed), 6=TWOLEG (Two Leg), 7=THREEELEG (Three Leg)
 	PriceType int `json:"price_type" jsonschema:"description=Price type for the order, 1=LMT (Limit), 2=MKT (Market), 3=SLLMT (Stop Loss Limit), 4=SLMKT (Stop Loss Market), 5=DS (Disclosed), 6=TWOLEG (Two Leg), 7=THREEELEG (Three Leg)"`
-	// Validity of the order (e.g., 1=DAY, 2=IOC, 3=EOS, 4=GTT)
-	Validity int `json:"validity" jsonschema:"description=Validity of the order, 1=DAY, 2=IOC, 3=EOS, 4=GTT"`
+	// Validity of the order (e.g., 1=DAY, 2=IOC, 3=EOS, 4=GTT), DAY when omitted
+	Validity int `json:"validity" jsonschema:"description=Validity of the order\\, 1=DAY\\, 2=IOC\\, 3=EOS\\, 4=GTT. Defaults to 1=DAY"`
 	// Disclosed quantity for the order
 	DiscQuantity int `json:"disclosed_quantity" jsonschema:"description=Disclosed quantity for the order"`
 	// Whether this is an After Market Order
@@ -134,15 +135,6 @@ type ModifyOrder struct {
 	OrderItem
 }
 
-type Margin struct {
-	Cash                 string `json:"cash,omitempty"`
-	MarginUsed           string `json:"margin_used"`
-	MarginUsedAfterTrade string `json:"margin_used_after_trade,omitempty"`
-	OrderMargin          string `json:"order_margin,omitempty"`
-	PrevMarginUsed       string `json:"margin_used_previous,omitempty"`
-	Remarks              string `json:"remarks,omitempty"`
-}
-
 type OrderItem struct {
 	ExchangeName    int       `json:"exchange_name"`
 	Token           string    `json:"token"`
@@ -197,7 +189,8 @@ func MakePriceReq(symbols []string) *PriceReq {
 	return priceReq
 }
 
-type placeOrderResponse struct {
+// PlaceOrderResponse is the acknowledgement of a placed or modified order.
+type PlaceOrderResponse struct {
 	OrderID       string `json:"order_id"`
 	TradingSymbol string `json:"trading_symbol"`
 	Quantity      int    `json:"quantity"`
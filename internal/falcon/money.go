@@ -0,0 +1,129 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package falcon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in paisa, the unit the Falcon API reports prices and
+// balances in. It decodes from paisa given as a JSON string or number and
+// encodes as a decimal rupee amount, so 15050 paisa is written as 150.50.
+// Encoding is meant for tool output, it does not round trip.
+type Money int64
+
+// Paisa creates a Money from an amount in paisa.
+func Paisa(p int64) Money {
+	return Money(p)
+}
+
+// Rupees creates a Money from a decimal rupee amount, rounded to the paisa.
+func Rupees(r float64) Money {
+	if r < 0 {
+		return Money(r*100 - 0.5)
+	}
+	return Money(r*100 + 0.5)
+}
+
+// ParsePaisa parses an amount in paisa such as "15050". A fraction of a paisa
+// is rounded half away from zero and an empty string is zero.
+func ParsePaisa(s string) (Money, error) {
+	return parseDecimal(s, 0)
+}
+
+// ParseRupees parses a decimal rupee amount such as "150.5", as used for the
+// prices of order requests. An empty string is zero.
+func ParseRupees(s string) (Money, error) {
+	return parseDecimal(s, 2)
+}
+
+// parseDecimal parses a decimal number into paisa, where scale is the number
+// of decimals of the unit of s relative to paisa. Digits beyond the paisa are
+// rounded half away from zero.
+func parseDecimal(s string, scale int) (Money, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	whole, frac, hasFrac := strings.Cut(s, ".")
+	neg := strings.HasPrefix(whole, "-")
+	digits := strings.TrimLeft(whole, "+-")
+	if (digits == "" && !hasFrac) || (hasFrac && frac == "") ||
+		len(whole)-len(digits) > 1 || !isDigits(digits) || !isDigits(frac) {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+
+	frac += strings.Repeat("0", scale)
+	p, err := strconv.ParseInt("0"+digits+frac[:scale], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if frac[scale:] != "" && frac[scale] >= '5' {
+		p++
+	}
+	if neg {
+		p = -p
+	}
+	return Money(p), nil
+}
+
+func isDigits(s string) bool {
+	return strings.Trim(s, "0123456789") == ""
+}
+
+// Paisa returns the amount in paisa.
+func (m Money) Paisa() int64 {
+	return int64(m)
+}
+
+// Rupees returns the amount in rupees.
+func (m Money) Rupees() float64 {
+	return float64(m) / 100
+}
+
+// Mul returns the amount multiplied by a quantity.
+func (m Money) Mul(qty int) Money {
+	return m * Money(qty)
+}
+
+// String formats the amount in rupees with two decimals, e.g. "-150.05".
+func (m Money) String() string {
+	sign := ""
+	p := int64(m)
+	if p < 0 {
+		sign = "-"
+		p = -p
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, p/100, p%100)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*m = 0
+		return nil
+	}
+	s := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+	v, err := ParsePaisa(s)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
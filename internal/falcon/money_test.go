@@ -0,0 +1,83 @@
+package falcon
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePaisa(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr bool
+	}{
+		{in: "15050", want: 15050},
+		{in: " -1250 ", want: -1250},
+		{in: "", want: 0},
+		{in: "100.49", want: 100},
+		{in: "100.5", want: 101},
+		{in: "-100.5", want: -101},
+		{in: ".5", want: 1},
+		{in: "1,000", wantErr: true},
+		{in: "12.", wantErr: true},
+		{in: "-", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParsePaisa(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	var v struct {
+		A Money `json:"a"`
+		B Money `json:"b"`
+		C Money `json:"c"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"a":"15005","b":-7,"c":null}`), &v))
+	assert.Equal(t, Paisa(15005), v.A)
+	assert.Equal(t, Paisa(-7), v.B)
+
+	out, err := json.Marshal(v)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"a":150.05,"b":-0.07,"c":0}`, string(out))
+	assert.Equal(t, Paisa(15005), Rupees(150.05))
+}
+
+func TestParseRupees(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr bool
+	}{
+		{in: "150", want: 15000},
+		{in: "150.5", want: 15050},
+		{in: "150.05", want: 15005},
+		{in: "150.055", want: 15006},
+		{in: "-0.5", want: -50},
+		{in: "", want: 0},
+		{in: "1e3", wantErr: true},
+		{in: "--1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseRupees(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package falcon
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// Holding is a security held in the demat account. Prices are per share.
+type Holding struct {
+	TradingSymbol string `json:"trading_symbol"`
+	ExchangeName  int    `json:"exchange_name"`
+	Token         string `json:"token"`
+	ISIN          string `json:"isin,omitempty"`
+	Quantity      int    `json:"quantity"`
+	// Quantity bought but not yet delivered
+	T1Quantity   int   `json:"t1_quantity,omitempty"`
+	AveragePrice Money `json:"average_price"`
+	LastPrice    Money `json:"ltp"`
+	ClosePrice   Money `json:"close_price,omitempty"`
+}
+
+// TotalQuantity includes shares awaiting delivery.
+func (h Holding) TotalQuantity() int {
+	return h.Quantity + h.T1Quantity
+}
+
+// Invested is the cost of the holding at its average price.
+func (h Holding) Invested() Money {
+	return h.AveragePrice.Mul(h.TotalQuantity())
+}
+
+// Value is the holding valued at the last traded price.
+func (h Holding) Value() Money {
+	return h.LastPrice.Mul(h.TotalQuantity())
+}
+
+// PnL is the unrealised profit or loss of the holding.
+func (h Holding) PnL() Money {
+	return h.Value() - h.Invested()
+}
+
+// Holdings is the holdings report of an account.
+type Holdings []Holding
+
+func (h *Holdings) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(unwrapList(data, "holdings"), (*[]Holding)(h))
+}
+
+// Position is an intraday or carried forward position of an account.
+type Position struct {
+	TradingSymbol    string `json:"trading_symbol"`
+	ExchangeName     int    `json:"exchange_name"`
+	Token            string `json:"token"`
+	OrderType        int    `json:"order_type"`
+	NetQuantity      int    `json:"net_quantity"`
+	BuyQuantity      int    `json:"buy_quantity"`
+	SellQuantity     int    `json:"sell_quantity"`
+	BuyAveragePrice  Money  `json:"buy_average_price"`
+	SellAveragePrice Money  `json:"sell_average_price"`
+	LastPrice        Money  `json:"ltp"`
+	RealisedPnL      Money  `json:"realised_pnl"`
+	UnrealisedPnL    Money  `json:"unrealised_pnl"`
+}
+
+// PnL is the realised and unrealised profit or loss of the position.
+func (p Position) PnL() Money {
+	return p.RealisedPnL + p.UnrealisedPnL
+}
+
+// Positions is the positions report of an account.
+type Positions []Position
+
+func (p *Positions) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(unwrapList(data, "positions"), (*[]Position)(p))
+}
+
+// OrderBookEntry is an order of the day as listed in the order book.
+type OrderBookEntry struct {
+	OrderID           string `json:"order_id"`
+	ExchangeOrderID   string `json:"exchange_order_id,omitempty"`
+	TradingSymbol     string `json:"trading_symbol"`
+	ExchangeName      int    `json:"exchange_name"`
+	Token             string `json:"token"`
+	TransactionType   int    `json:"transaction_type"`
+	OrderType         int    `json:"order_type"`
+	PriceType         int    `json:"price_type"`
+	Validity          int    `json:"validity"`
+	Status            int    `json:"status"`
+	Quantity          int    `json:"quantity"`
+	FilledShares      int    `json:"filled_shares"`
+	CancelledQuantity int    `json:"cancelled_quantity,omitempty"`
+	Price             Money  `json:"price"`
+	TriggerPrice      Money  `json:"trigger_price,omitempty"`
+	AveragePrice      Money  `json:"average_price"`
+	IsAMO             bool   `json:"is_amo"`
+	RejectReason      string `json:"reject_reason,omitempty"`
+	EntryTime         string `json:"entry_time,omitempty"`
+	ExchangeTime      string `json:"exchange_time,omitempty"`
+}
+
+// PendingQuantity is the quantity neither filled nor cancelled.
+func (o OrderBookEntry) PendingQuantity() int {
+	return o.Quantity - o.FilledShares - o.CancelledQuantity
+}
+
+// IsOpen reports whether the order is still waiting at the exchange for the
+// rest of its quantity.
+func (o OrderBookEntry) IsOpen() bool {
+	return o.PendingQuantity() > 0 && o.RejectReason == ""
+}
+
+// OrderBook lists the orders of the day.
+type OrderBook []OrderBookEntry
+
+func (o *OrderBook) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(unwrapList(data, "orders"), (*[]OrderBookEntry)(o))
+}
+
+// Quote is the market price of a security, field names follow the price feed.
+type Quote struct {
+	TradingSymbol     string `json:"trading_symbol,omitempty"`
+	ExchangeName      int    `json:"exchange_name,omitempty"`
+	Token             string `json:"token,omitempty"`
+	LastPrice         Money  `json:"ltp"`
+	Open              Money  `json:"open"`
+	High              Money  `json:"high"`
+	Low               Money  `json:"low"`
+	Close             Money  `json:"close"`
+	AverageTradePrice Money  `json:"average_trade_price,omitempty"`
+	UpperCircuit      Money  `json:"upper_circuit,omitempty"`
+	LowerCircuit      Money  `json:"lower_circuit,omitempty"`
+	Volume            int64  `json:"volume"`
+	OpenInterest      int64  `json:"open_interest,omitempty"`
+}
+
+// Change is the change of the last price from the previous close.
+func (q Quote) Change() Money {
+	return q.LastPrice - q.Close
+}
+
+// Quotes maps the requested symbols, exchange:trading_symbol, to their quotes.
+type Quotes map[string]Quote
+
+func (q *Quotes) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(unwrapData(data), (*map[string]Quote)(q))
+}
+
+// Lookup returns the quote of a symbol, the feed may change its case.
+func (q Quotes) Lookup(symbol string) (Quote, bool) {
+	if quote, ok := q[symbol]; ok {
+		return quote, true
+	}
+	for s, quote := range q {
+		if strings.EqualFold(s, symbol) {
+			return quote, true
+		}
+	}
+	return Quote{}, false
+}
+
+// Margin is the fund limits of an account.
+type Margin struct {
+	Cash                 Money  `json:"cash,omitempty"`
+	MarginUsed           Money  `json:"margin_used"`
+	MarginUsedAfterTrade Money  `json:"margin_used_after_trade,omitempty"`
+	OrderMargin          Money  `json:"order_margin,omitempty"`
+	PrevMarginUsed       Money  `json:"margin_used_previous,omitempty"`
+	Remarks              string `json:"remarks,omitempty"`
+}
+
+// Available is the cash left for new orders.
+func (m Margin) Available() Money {
+	return m.Cash - m.MarginUsed
+}
+
+func (m *Margin) UnmarshalJSON(data []byte) error {
+	type margin Margin
+	return json.Unmarshal(unwrapData(data), (*margin)(m))
+}
+
+// TradeIdea is a research recommendation.
+type TradeIdea struct {
+	ID              int    `json:"id"`
+	TradingSymbol   string `json:"trading_symbol"`
+	ExchangeName    int    `json:"exchange_name"`
+	Token           string `json:"token"`
+	TransactionType int    `json:"transaction_type"`
+	EntryPrice      Money  `json:"entry_price"`
+	TargetPrice     Money  `json:"target_price"`
+	StopLossPrice   Money  `json:"stop_loss_price"`
+	Status          int    `json:"status"`
+	Rationale       string `json:"rationale,omitempty"`
+	CreatedAt       string `json:"created_at,omitempty"`
+}
+
+// TradeIdeas lists the open research recommendations.
+type TradeIdeas []TradeIdea
+
+func (t *TradeIdeas) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(unwrapList(data, "ideas"), (*[]TradeIdea)(t))
+}
+
+// unwrapData returns the payload of a {"data": ...} envelope, or data itself
+// when the response is not wrapped.
+func unwrapData(data []byte) []byte {
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if isObject(data) && json.Unmarshal(data, &envelope) == nil && len(envelope.Data) > 0 {
+		return envelope.Data
+	}
+	return data
+}
+
+// unwrapList finds a list in a response that is either the bare list, a
+// {"data": [...]} envelope or a {"data": {key: [...]}} envelope.
+func unwrapList(data []byte, key string) []byte {
+	data = unwrapData(data)
+	if !isObject(data) {
+		return data
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err == nil {
+		if list, ok := fields[key]; ok {
+			return list
+		}
+	}
+	return data
+}
+
+func isObject(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) > 0 && data[0] == '{'
+}
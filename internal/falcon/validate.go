@@ -0,0 +1,223 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package falcon
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Validity values.
+const (
+	ValidityDay = 1
+	ValidityIOC = 2
+	ValidityEOS = 3
+	ValidityGTT = 4
+)
+
+// FieldError is a problem with one field of an order request, worded so the
+// caller can fix the field and retry.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError lists every problem found in an order request.
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+	}
+	return "invalid order: " + strings.Join(msgs, "; ")
+}
+
+// Field returns the error of a field, nil if the field is valid.
+func (e *ValidationError) Field(field string) *FieldError {
+	for i := range e.Errors {
+		if e.Errors[i].Field == field {
+			return &e.Errors[i]
+		}
+	}
+	return nil
+}
+
+// add records an error for field unless it already has one, the first
+// problem found is the one worth fixing.
+func (e *ValidationError) add(field, format string, args ...any) {
+	if e.Field(field) != nil {
+		return
+	}
+	e.Errors = append(e.Errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (e *ValidationError) err() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Instrument is a tradable security as returned by the security search.
+type Instrument struct {
+	ExchangeName   int    `json:"exchange_name"`
+	Token          string `json:"token"`
+	TradingSymbol  string `json:"trading_symbol"`
+	Name           string `json:"name"`
+	InstrumentType string `json:"instrument_type"`
+	ISIN           string `json:"isin_number"`
+	LotSize        int    `json:"lot_size"`
+	// TickSize is the price step, zero when the search does not know it
+	TickSize Money `json:"tick_size"`
+}
+
+// Validate checks an order request on its own, without instrument data.
+func (o OrderReq) Validate() error {
+	return validateOrder(&o, nil)
+}
+
+// Validate checks the modified order.
+func (m ModifyOrderReq) Validate() error {
+	var verr ValidationError
+	if m.OrderID == "" {
+		verr.add("order_id", "is required")
+	}
+	if err := m.OrderReq.Validate(); err != nil {
+		verr.Errors = append(verr.Errors, err.(*ValidationError).Errors...)
+	}
+	return verr.err()
+}
+
+// validateOrder checks the fields of an order and how they combine. With
+// instrument data the prices are also rounded in place to the tick size, buy
+// prices down and sell prices up so that the order is never executed at a
+// worse price than requested, and derivative quantities are checked against
+// the lot size. A missing validity defaults to DAY. It returns a
+// *ValidationError.
+func validateOrder(o *OrderReq, instr *Instrument) error {
+	var verr ValidationError
+
+	if o.Validity == 0 {
+		o.Validity = ValidityDay
+	}
+
+	if ExchangeName(o.ExchangeName) == "" {
+		verr.add("exchange_name", "must be 1=NSE, 2=NFO, 3=BSE or 4=BFO, got %d", o.ExchangeName)
+	}
+	if strings.TrimSpace(o.TradingSymbol) == "" {
+		verr.add("trading_symbol", "is required, find it with the search tool")
+	}
+	if o.TransactionType != TransactionBuy && o.TransactionType != TransactionSell {
+		verr.add("transaction_type", "must be 1=Buy or 2=Sell, got %d", o.TransactionType)
+	}
+	if _, ok := priceTypeNames[o.PriceType]; !ok {
+		verr.add("price_type", "must be 1=LMT, 2=MKT, 3=SLLMT, 4=SLMKT, 5=DS, 6=TWOLEG or 7=THREEELEG, got %d", o.PriceType)
+	}
+	if o.Validity < ValidityDay || o.Validity > ValidityGTT {
+		verr.add("validity", "must be 1=DAY, 2=IOC, 3=EOS or 4=GTT, got %d", o.Validity)
+	}
+	if o.Quantity <= 0 {
+		verr.add("quantity", "must be positive, got %d", o.Quantity)
+	}
+	if o.DiscQuantity < 0 || o.DiscQuantity > o.Quantity {
+		verr.add("disclosed_quantity", "must be between 0 and quantity %d, got %d", o.Quantity, o.DiscQuantity)
+	}
+	if o.IsAMO && o.Validity == ValidityIOC {
+		verr.add("validity", "IOC is not allowed for after market orders, use 1=DAY")
+	}
+
+	price := parsePrice(&verr, "price", o.Price)
+	trigger := parsePrice(&verr, "trigger_price", o.TriggerPrice)
+	parsePrice(&verr, "target_price", o.TargetPrice)
+	parsePrice(&verr, "stop_loss_price", o.StopLossPrice)
+	parsePrice(&verr, "trailing_price", o.TrailPrice)
+
+	name := PriceTypeName(o.PriceType)
+	switch {
+	case IsMarketPrice(o.PriceType) && price != 0:
+		verr.add("price", "must be empty for %s orders, they execute at the market price", name)
+	case o.PriceType == PriceTypeLimit || o.PriceType == PriceTypeStopLossLimit:
+		if price <= 0 {
+			verr.add("price", "is required for %s orders", name)
+		}
+	}
+	switch {
+	case IsStopLoss(o.PriceType) && trigger <= 0:
+		verr.add("trigger_price", "is required for %s orders", name)
+	case !IsStopLoss(o.PriceType) && trigger != 0:
+		verr.add("trigger_price", "is only allowed for SL-LIMIT and SL-MARKET orders, use price_type 3 or 4")
+	case o.PriceType == PriceTypeStopLossLimit && price > 0:
+		// A stop loss buy triggers as the price rises, a sell as it falls
+		if o.TransactionType == TransactionBuy && trigger > price {
+			verr.add("trigger_price", "must not be above the price %s for a buy stop loss", price)
+		}
+		if o.TransactionType == TransactionSell && trigger < price {
+			verr.add("trigger_price", "must not be below the price %s for a sell stop loss", price)
+		}
+	}
+
+	if instr != nil && len(verr.Errors) == 0 {
+		checkInstrument(&verr, o, instr, price, trigger)
+	}
+	return verr.err()
+}
+
+// checkInstrument applies the tick size and lot size of the instrument.
+func checkInstrument(verr *ValidationError, o *OrderReq, instr *Instrument, price, trigger Money) {
+	if (o.ExchangeName == ExchangeNFO || o.ExchangeName == ExchangeBFO) && instr.LotSize > 1 && o.Quantity%instr.LotSize != 0 {
+		lower := o.Quantity / instr.LotSize * instr.LotSize
+		upper := lower + instr.LotSize
+		if lower == 0 {
+			verr.add("quantity", "must be a multiple of the lot size %d of %s, e.g. %d", instr.LotSize, instr.TradingSymbol, upper)
+		} else {
+			verr.add("quantity", "must be a multiple of the lot size %d of %s, e.g. %d or %d", instr.LotSize, instr.TradingSymbol, lower, upper)
+		}
+	}
+	if instr.TickSize <= 0 {
+		return
+	}
+	if price > 0 {
+		o.Price = roundToTick(price, instr.TickSize, o.TransactionType == TransactionSell).String()
+	}
+	if trigger > 0 {
+		o.TriggerPrice = roundToTick(trigger, instr.TickSize, trigger%instr.TickSize*2 >= instr.TickSize).String()
+	}
+}
+
+// roundToTick rounds a price to a multiple of tick, up or down.
+func roundToTick(price, tick Money, up bool) Money {
+	rem := price % tick
+	if rem == 0 {
+		return price
+	}
+	if up {
+		return price - rem + tick
+	}
+	return price - rem
+}
+
+func parsePrice(verr *ValidationError, field, value string) Money {
+	if strings.TrimSpace(value) == "0" {
+		return 0
+	}
+	m, err := ParseRupees(value)
+	if err != nil {
+		verr.add(field, "must be a price in rupees such as 245.50, got %q", value)
+		return 0
+	}
+	if m < 0 {
+		verr.add(field, "must not be negative, got %s", value)
+		return 0
+	}
+	return m
+}
@@ -0,0 +1,198 @@
+package falcon
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validOrder() OrderReq {
+	return OrderReq{
+		ExchangeName:    ExchangeNSE,
+		TradingSymbol:   "RELIANCE-EQ",
+		Quantity:        10,
+		Price:           "2450.50",
+		TransactionType: TransactionBuy,
+		PriceType:       PriceTypeLimit,
+		Validity:        ValidityDay,
+	}
+}
+
+func TestValidateOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		order      func(o *OrderReq)
+		wantFields map[string]string
+	}{
+		{
+			name:  "valid limit order",
+			order: func(o *OrderReq) {},
+		},
+		{
+			name:  "validity omitted",
+			order: func(o *OrderReq) { o.Validity = 0 },
+		},
+		{
+			name: "missing enums",
+			order: func(o *OrderReq) {
+				o.ExchangeName, o.TransactionType, o.PriceType, o.Validity = 0, 3, 0, 9
+			},
+			wantFields: map[string]string{
+				"exchange_name":    "must be 1=NSE, 2=NFO, 3=BSE or 4=BFO, got 0",
+				"transaction_type": "must be 1=Buy or 2=Sell, got 3",
+				"price_type":       "must be 1=LMT, 2=MKT, 3=SLLMT, 4=SLMKT, 5=DS, 6=TWOLEG or 7=THREEELEG, got 0",
+				"validity":         "must be 1=DAY, 2=IOC, 3=EOS or 4=GTT, got 9",
+			},
+		},
+		{
+			name:       "limit without price",
+			order:      func(o *OrderReq) { o.Price = "" },
+			wantFields: map[string]string{"price": "is required for LIMIT orders"},
+		},
+		{
+			name:       "market with price",
+			order:      func(o *OrderReq) { o.PriceType = PriceTypeMarket },
+			wantFields: map[string]string{"price": "must be empty for MARKET orders, they execute at the market price"},
+		},
+		{
+			name: "market with zero price",
+			order: func(o *OrderReq) {
+				o.PriceType, o.Price = PriceTypeMarket, "0"
+			},
+		},
+		{
+			name:       "stop loss without trigger",
+			order:      func(o *OrderReq) { o.PriceType = PriceTypeStopLossLimit },
+			wantFields: map[string]string{"trigger_price": "is required for SL-LIMIT orders"},
+		},
+		{
+			name:       "trigger on limit order",
+			order:      func(o *OrderReq) { o.TriggerPrice = "2400" },
+			wantFields: map[string]string{"trigger_price": "is only allowed for SL-LIMIT and SL-MARKET orders, use price_type 3 or 4"},
+		},
+		{
+			name: "buy stop loss trigger above price",
+			order: func(o *OrderReq) {
+				o.PriceType, o.TriggerPrice = PriceTypeStopLossLimit, "2460"
+			},
+			wantFields: map[string]string{"trigger_price": "must not be above the price 2450.50 for a buy stop loss"},
+		},
+		{
+			name: "sell stop loss market",
+			order: func(o *OrderReq) {
+				o.TransactionType, o.PriceType, o.Price, o.TriggerPrice = TransactionSell, PriceTypeStopLossMarket, "", "2400"
+			},
+		},
+		{
+			name: "bad numbers",
+			order: func(o *OrderReq) {
+				o.Quantity, o.Price, o.DiscQuantity = 0, "24,50", 5
+			},
+			wantFields: map[string]string{
+				"quantity":           "must be positive, got 0",
+				"disclosed_quantity": "must be between 0 and quantity 0, got 5",
+				"price":              `must be a price in rupees such as 245.50, got "24,50"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := validOrder()
+			tt.order(&o)
+			err := o.Validate()
+			if len(tt.wantFields) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			var verr *ValidationError
+			require.ErrorAs(t, err, &verr)
+			got := make(map[string]string)
+			for _, fe := range verr.Errors {
+				got[fe.Field] = fe.Message
+			}
+			assert.Equal(t, tt.wantFields, got)
+		})
+	}
+}
+
+func TestValidateOrderInstrument(t *testing.T) {
+	future := &Instrument{ExchangeName: ExchangeNFO, TradingSymbol: "NIFTY25JULFUT", LotSize: 75, TickSize: Paisa(10)}
+
+	tests := []struct {
+		name        string
+		order       func(o *OrderReq)
+		wantPrice   string
+		wantTrigger string
+		wantErr     string
+	}{
+		{
+			name:      "buy rounds down",
+			order:     func(o *OrderReq) { o.Price = "24500.57" },
+			wantPrice: "24500.50",
+		},
+		{
+			name: "sell rounds up",
+			order: func(o *OrderReq) {
+				o.TransactionType, o.Price = TransactionSell, "24500.51"
+			},
+			wantPrice: "24500.60",
+		},
+		{
+			name: "trigger rounds to nearest",
+			order: func(o *OrderReq) {
+				o.PriceType, o.Price, o.TriggerPrice = PriceTypeStopLossLimit, "24500.60", "24500.36"
+			},
+			wantPrice:   "24500.60",
+			wantTrigger: "24500.40",
+		},
+		{
+			name:    "lot size multiple",
+			order:   func(o *OrderReq) { o.Quantity = 100 },
+			wantErr: "quantity: must be a multiple of the lot size 75 of NIFTY25JULFUT, e.g. 75 or 150",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := validOrder()
+			o.ExchangeName, o.TradingSymbol, o.Quantity = ExchangeNFO, "NIFTY25JULFUT", 75
+			tt.order(&o)
+			err := validateOrder(&o, future)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPrice, o.Price)
+			assert.Equal(t, tt.wantTrigger, o.TriggerPrice)
+		})
+	}
+}
+
+func TestValidateOrderDefaultsValidity(t *testing.T) {
+	o := validOrder()
+	o.Validity = 0
+	require.NoError(t, validateOrder(&o, nil))
+	assert.Equal(t, ValidityDay, o.Validity)
+}
+
+func TestPlaceOrderRejectsInvalidOrder(t *testing.T) {
+	service, server := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/search/" {
+			w.Write([]byte(`{"stocks":[{"exchange_name":2,"trading_symbol":"NIFTY25JULFUT","lot_size":75,"tick_size":"10"}]}`))
+			return
+		}
+		t.Errorf("unexpected request to %s", r.URL.Path)
+	})
+	defer server.Close()
+
+	o := validOrder()
+	o.ExchangeName, o.TradingSymbol, o.Quantity = ExchangeNFO, "NIFTY25JULFUT", 50
+	_, err := service.PlaceOrder(context.Background(), []OrderReq{o})
+
+	var verr *ValidationError
+	require.ErrorAs(t, err, &verr)
+	assert.NotNil(t, verr.Field("quantity"))
+}
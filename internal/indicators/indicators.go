@@ -0,0 +1,317 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package indicators computes technical indicators over candle series.
+//
+// Every series function returns a slice aligned with its input, holding NaN
+// for the leading values an indicator cannot be computed for yet.
+package indicators
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+	"github.com/wealthy/wealthy-mcp/internal/websocket"
+)
+
+// Bar is a candle with prices in rupees.
+type Bar struct {
+	Time   time.Time
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume int64
+}
+
+// FromHistory converts historical candles to bars.
+func FromHistory(candles falcon.Candles) []Bar {
+	bars := make([]Bar, len(candles))
+	for i, c := range candles {
+		bars[i] = Bar{Time: c.Time, Open: c.Open.Rupees(), High: c.High.Rupees(), Low: c.Low.Rupees(), Close: c.Close.Rupees(), Volume: c.Volume}
+	}
+	return bars
+}
+
+// FromLive converts candles of the live aggregator to bars.
+func FromLive(candles []websocket.Candle) []Bar {
+	bars := make([]Bar, len(candles))
+	for i, c := range candles {
+		bars[i] = Bar{Time: c.Start, Open: c.Open.Rupees(), High: c.High.Rupees(), Low: c.Low.Rupees(), Close: c.Close.Rupees(), Volume: c.Volume}
+	}
+	return bars
+}
+
+// History fetches historical candles, FalconService does so for the server
+// login.
+type History interface {
+	GetHistoricalCandles(ctx context.Context, exchange int, token string, interval falcon.CandleInterval, from, to time.Time) (falcon.Candles, error)
+}
+
+// lookback is how far back Load fetches candles of each interval, enough for
+// a few hundred bars to warm the slower indicators up.
+var lookback = map[falcon.CandleInterval]time.Duration{
+	falcon.Interval1Minute:   5 * 24 * time.Hour,
+	falcon.Interval5Minutes:  10 * 24 * time.Hour,
+	falcon.Interval15Minutes: 20 * 24 * time.Hour,
+	falcon.Interval1Hour:     90 * 24 * time.Hour,
+	falcon.Interval1Day:      365 * 24 * time.Hour,
+}
+
+// Load returns the bars of an instrument up to to: the historical candles,
+// followed for intraday intervals by the newer bars live built, if it
+// streams the instrument. live may be nil.
+func Load(ctx context.Context, history History, live *websocket.CandleBuilder, exchange int, token string, interval falcon.CandleInterval, to time.Time) ([]Bar, error) {
+	candles, err := history.GetHistoricalCandles(ctx, exchange, token, interval, to.Add(-lookback[interval]), to)
+	if err != nil {
+		return nil, err
+	}
+	bars := FromHistory(candles)
+	size, err := websocket.ParseInterval(string(interval))
+	if live == nil || err != nil {
+		return bars, nil
+	}
+	for _, b := range FromLive(live.Get(exchange, token, size, 0)) {
+		if b.Time.After(to) {
+			break
+		}
+		if len(bars) == 0 || b.Time.After(bars[len(bars)-1].Time) {
+			bars = append(bars, b)
+		}
+	}
+	return bars, nil
+}
+
+// Closes returns the closing prices of bars.
+func Closes(bars []Bar) []float64 {
+	closes := make([]float64, len(bars))
+	for i, b := range bars {
+		closes[i] = b.Close
+	}
+	return closes
+}
+
+func nans(n int) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = math.NaN()
+	}
+	return out
+}
+
+// SMA is the simple moving average of the last period values.
+func SMA(values []float64, period int) []float64 {
+	out := nans(len(values))
+	if period <= 0 {
+		return out
+	}
+	sum := 0.0
+	for i, v := range values {
+		sum += v
+		if i >= period {
+			sum -= values[i-period]
+		}
+		if i >= period-1 {
+			out[i] = sum / float64(period)
+		}
+	}
+	return out
+}
+
+// EMA is the exponential moving average with a smoothing of 2/(period+1),
+// seeded with the simple average of the first period values. Leading NaN
+// values, such as those of another indicator, are skipped.
+func EMA(values []float64, period int) []float64 {
+	out := nans(len(values))
+	start := 0
+	for start < len(values) && math.IsNaN(values[start]) {
+		start++
+	}
+	if period <= 0 || len(values)-start < period {
+		return out
+	}
+	k := 2 / float64(period+1)
+	seed := 0.0
+	for _, v := range values[start : start+period] {
+		seed += v
+	}
+	prev := seed / float64(period)
+	out[start+period-1] = prev
+	for i := start + period; i < len(values); i++ {
+		prev = values[i]*k + prev*(1-k)
+		out[i] = prev
+	}
+	return out
+}
+
+// RSI is the relative strength index with Wilder's smoothing, from 0 to 100.
+func RSI(values []float64, period int) []float64 {
+	out := nans(len(values))
+	if period <= 0 || len(values) <= period {
+		return out
+	}
+	var gain, loss float64
+	for i := 1; i <= period; i++ {
+		change := values[i] - values[i-1]
+		gain += math.Max(change, 0)
+		loss += math.Max(-change, 0)
+	}
+	gain /= float64(period)
+	loss /= float64(period)
+	out[period] = rsi(gain, loss)
+	for i := period + 1; i < len(values); i++ {
+		change := values[i] - values[i-1]
+		gain = (gain*float64(period-1) + math.Max(change, 0)) / float64(period)
+		loss = (loss*float64(period-1) + math.Max(-change, 0)) / float64(period)
+		out[i] = rsi(gain, loss)
+	}
+	return out
+}
+
+func rsi(gain, loss float64) float64 {
+	if loss == 0 {
+		if gain == 0 {
+			return 50
+		}
+		return 100
+	}
+	return 100 - 100/(1+gain/loss)
+}
+
+// MACD returns the difference of the fast and slow EMA, its signal EMA and
+// the histogram between the two.
+func MACD(values []float64, fast, slow, signal int) (macd, sig, hist []float64) {
+	fastEMA, slowEMA := EMA(values, fast), EMA(values, slow)
+	macd = make([]float64, len(values))
+	for i := range values {
+		macd[i] = fastEMA[i] - slowEMA[i]
+	}
+	sig = EMA(macd, signal)
+	hist = make([]float64, len(values))
+	for i := range values {
+		hist[i] = macd[i] - sig[i]
+	}
+	return macd, sig, hist
+}
+
+// Bollinger returns the SMA of period values and the bands k population
+// standard deviations above and below it.
+func Bollinger(values []float64, period int, k float64) (middle, upper, lower []float64) {
+	middle = SMA(values, period)
+	upper, lower = nans(len(values)), nans(len(values))
+	for i := period - 1; i >= 0 && i < len(values); i++ {
+		variance := 0.0
+		for _, v := range values[i-period+1 : i+1] {
+			variance += (v - middle[i]) * (v - middle[i])
+		}
+		sd := math.Sqrt(variance / float64(period))
+		upper[i], lower[i] = middle[i]+k*sd, middle[i]-k*sd
+	}
+	return middle, upper, lower
+}
+
+// TrueRange is the range of each bar, extended to the previous close when it
+// gapped.
+func TrueRange(bars []Bar) []float64 {
+	out := make([]float64, len(bars))
+	for i, b := range bars {
+		out[i] = b.High - b.Low
+		if i > 0 {
+			prev := bars[i-1].Close
+			out[i] = math.Max(out[i], math.Max(math.Abs(b.High-prev), math.Abs(b.Low-prev)))
+		}
+	}
+	return out
+}
+
+// ATR is the average true range with Wilder's smoothing.
+func ATR(bars []Bar, period int) []float64 {
+	out := nans(len(bars))
+	if period <= 0 || len(bars) < period {
+		return out
+	}
+	tr := TrueRange(bars)
+	atr := 0.0
+	for _, v := range tr[:period] {
+		atr += v
+	}
+	atr /= float64(period)
+	out[period-1] = atr
+	for i := period; i < len(bars); i++ {
+		atr = (atr*float64(period-1) + tr[i]) / float64(period)
+		out[i] = atr
+	}
+	return out
+}
+
+// VWAP is the volume weighted average of the typical price, (high + low +
+// close) / 3, restarting every trading day. Bars without volume weigh
+// equally.
+func VWAP(bars []Bar) []float64 {
+	out := make([]float64, len(bars))
+	var day string
+	var value, volume, typical float64
+	var count int
+	for i, b := range bars {
+		if d := b.Time.In(falcon.IST).Format(time.DateOnly); d != day {
+			day, value, volume, typical, count = d, 0, 0, 0, 0
+		}
+		price := (b.High + b.Low + b.Close) / 3
+		value += price * float64(b.Volume)
+		volume += float64(b.Volume)
+		typical += price
+		count++
+		if volume > 0 {
+			out[i] = value / volume
+		} else {
+			out[i] = typical / float64(count)
+		}
+	}
+	return out
+}
+
+// Supertrend returns the trailing stop line of the supertrend, multiplier
+// ATRs from the middle of the bar, and whether the trend is up. The line is
+// below the price in an uptrend and above it in a downtrend.
+func Supertrend(bars []Bar, period int, multiplier float64) (line []float64, up []bool) {
+	line, up = nans(len(bars)), make([]bool, len(bars))
+	atr := ATR(bars, period)
+	var upper, lower float64
+	for i, b := range bars {
+		if math.IsNaN(atr[i]) {
+			continue
+		}
+		mid := (b.High + b.Low) / 2
+		basicUpper, basicLower := mid+multiplier*atr[i], mid-multiplier*atr[i]
+		if i == period-1 {
+			upper, lower = basicUpper, basicLower
+			up[i] = b.Close >= mid
+		} else {
+			prevClose := bars[i-1].Close
+			if basicUpper < upper || prevClose > upper {
+				upper = basicUpper
+			}
+			if basicLower > lower || prevClose < lower {
+				lower = basicLower
+			}
+			switch {
+			case up[i-1] && b.Close < lower:
+				up[i] = false
+			case !up[i-1] && b.Close > upper:
+				up[i] = true
+			default:
+				up[i] = up[i-1]
+			}
+		}
+		if up[i] {
+			line[i] = lower
+		} else {
+			line[i] = upper
+		}
+	}
+	return line, up
+}
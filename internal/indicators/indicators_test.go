@@ -0,0 +1,184 @@
+package indicators
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+)
+
+func assertSeries(t *testing.T, want, got []float64) {
+	t.Helper()
+	require.Len(t, got, len(want))
+	for i := range want {
+		if math.IsNaN(want[i]) {
+			assert.True(t, math.IsNaN(got[i]), "value %d: got %v, want NaN", i, got[i])
+			continue
+		}
+		assert.InDelta(t, want[i], got[i], 1e-3, "value %d", i)
+	}
+}
+
+// bars makes daily bars from closes, each spanning one rupee either side.
+func bars(closes ...float64) []Bar {
+	start := time.Date(2025, 7, 1, 9, 15, 0, 0, falcon.IST)
+	out := make([]Bar, len(closes))
+	for i, c := range closes {
+		out[i] = Bar{Time: start.AddDate(0, 0, i), Open: c, High: c + 1, Low: c - 1, Close: c, Volume: 100}
+	}
+	return out
+}
+
+func TestMovingAverages(t *testing.T) {
+	nan := math.NaN()
+	values := []float64{1, 2, 3, 4, 5}
+	assertSeries(t, []float64{nan, nan, 2, 3, 4}, SMA(values, 3))
+	assertSeries(t, []float64{nan, nan, 2, 3, 4}, EMA(values, 3))
+	assertSeries(t, []float64{nan, nan, nan, 3, 4.5}, EMA([]float64{nan, 2, 3, 4, 6}, 3))
+	assertSeries(t, []float64{nan, nan}, SMA([]float64{1, 2}, 3))
+
+	middle, upper, lower := Bollinger([]float64{1, 2, 3}, 3, 2)
+	assertSeries(t, []float64{nan, nan, 2}, middle)
+	assertSeries(t, []float64{nan, nan, 2 + 2*math.Sqrt(2.0/3)}, upper)
+	assertSeries(t, []float64{nan, nan, 2 - 2*math.Sqrt(2.0/3)}, lower)
+}
+
+func TestRSI(t *testing.T) {
+	nan := math.NaN()
+	assertSeries(t, []float64{nan, nan, 50, 75, 37.5}, RSI([]float64{1, 2, 1, 2, 1}, 2))
+	assertSeries(t, []float64{nan, 100, 100}, RSI([]float64{1, 2, 3}, 1))
+	assertSeries(t, []float64{nan, 50}, RSI([]float64{1, 1}, 1))
+}
+
+func TestMACD(t *testing.T) {
+	values := make([]float64, 40)
+	for i := range values {
+		values[i] = 100
+	}
+	macd, sig, hist := MACD(values, 12, 26, 9)
+	assert.True(t, math.IsNaN(macd[24]))
+	assert.InDelta(t, 0, macd[25], 1e-9)
+	assert.True(t, math.IsNaN(sig[32]))
+	assert.InDelta(t, 0, sig[33], 1e-9)
+	assert.InDelta(t, 0, hist[39], 1e-9)
+
+	for i := range values {
+		values[i] = float64(100 + i)
+	}
+	macd, sig, hist = MACD(values, 12, 26, 9)
+	assert.Greater(t, macd[39], 0.0, "the fast average leads in a rise")
+	assert.Greater(t, sig[39], 0.0)
+	assert.False(t, math.IsNaN(hist[39]))
+}
+
+func TestATR(t *testing.T) {
+	nan := math.NaN()
+	b := []Bar{
+		{High: 11, Low: 9, Close: 10},
+		{High: 14, Low: 12, Close: 13}, // gapped up, the range reaches back to 10
+		{High: 13, Low: 12, Close: 12},
+	}
+	assertSeries(t, []float64{2, 4, 1}, TrueRange(b))
+	assertSeries(t, []float64{nan, 3, 2}, ATR(b, 2))
+}
+
+func TestVWAP(t *testing.T) {
+	day := time.Date(2025, 7, 1, 9, 15, 0, 0, falcon.IST)
+	b := []Bar{
+		{Time: day, High: 12, Low: 9, Close: 9, Volume: 100},
+		{Time: day.Add(time.Minute), High: 21, Low: 19, Close: 20, Volume: 300},
+		{Time: day.AddDate(0, 0, 1), High: 31, Low: 29, Close: 30, Volume: 0},
+		{Time: day.AddDate(0, 0, 1).Add(time.Minute), High: 41, Low: 39, Close: 40, Volume: 0},
+	}
+	assertSeries(t, []float64{10, 17.5, 30, 35}, VWAP(b))
+}
+
+func TestSupertrend(t *testing.T) {
+	b := bars(100, 101, 102, 103, 104, 105, 106, 90, 85, 84)
+	line, up := Supertrend(b, 3, 1)
+	assert.True(t, math.IsNaN(line[1]))
+	assert.True(t, up[6])
+	assert.Less(t, line[6], b[6].Close, "the line trails below in an uptrend")
+	assert.False(t, up[7], "a close below the line turns the trend")
+	assert.Greater(t, line[9], b[9].Close)
+	for i := 3; i < 7; i++ {
+		assert.GreaterOrEqual(t, line[i], line[i-1], "the line only rises in an uptrend")
+	}
+}
+
+func TestParseSpec(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  string
+	}{
+		{in: "sma", want: "sma(20)"},
+		{in: " SMA(50) ", want: "sma(50)"},
+		{in: "macd(8, 21)", want: "macd(8,21,9)"},
+		{in: "bollinger(20,2.5)", want: "bollinger(20,2.5)"},
+		{in: "vwap", want: "vwap"},
+		{in: "supertrend()", want: "supertrend(10,3)"},
+		{in: "stoch", err: "unknown indicator"},
+		{in: "rsi(7.5)", err: "whole number"},
+		{in: "rsi(-3)", err: "positive number"},
+		{in: "ema(9,21)", err: "at most 1"},
+		{in: "ema(9", err: "missing )"},
+		{in: "vwap(5)", err: "at most 0"},
+	}
+	for _, tt := range tests {
+		spec, err := ParseSpec(tt.in)
+		if tt.err != "" {
+			assert.ErrorContains(t, err, tt.err, tt.in)
+			continue
+		}
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, spec.String())
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	rising := make([]float64, 60)
+	for i := range rising {
+		rising[i] = 100 + float64(i)
+	}
+	b := bars(rising...)
+	for _, spec := range Defaults() {
+		r, err := Evaluate(b, spec)
+		require.NoError(t, err, spec.String())
+		assert.Equal(t, spec.String(), r.Indicator)
+		assert.NotEmpty(t, r.Summary)
+		assert.NotEmpty(t, r.Values)
+	}
+
+	spec, _ := ParseSpec("rsi")
+	r, err := Evaluate(b, spec)
+	require.NoError(t, err)
+	assert.Equal(t, Overbought, r.Signal)
+	assert.Equal(t, 100.0, r.Values["rsi"])
+
+	spec, _ = ParseSpec("sma(5)")
+	r, err = Evaluate(b, spec)
+	require.NoError(t, err)
+	assert.Equal(t, Bullish, r.Signal)
+	assert.Equal(t, 157.0, r.Values["sma"])
+	assert.Equal(t, "close 159.00 is 1.27% above the SMA(5) of 157.00", r.Summary)
+
+	b = append(b, bars(100)...)
+	r, err = Evaluate(b, spec)
+	require.NoError(t, err)
+	assert.Equal(t, Bearish, r.Signal)
+	assert.Contains(t, r.Summary, "it crossed below on the last candle")
+
+	spec, _ = ParseSpec("bollinger")
+	r, err = Evaluate(b, spec)
+	require.NoError(t, err)
+	assert.Equal(t, Oversold, r.Signal)
+	assert.Less(t, r.Values["percent_b"], 0.0)
+
+	spec, _ = ParseSpec("sma(50)")
+	_, err = Evaluate(b[:10], spec)
+	assert.ErrorContains(t, err, "sma(50) needs more candles than the 10 available")
+}
@@ -0,0 +1,285 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package indicators
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Signals of a reading.
+const (
+	Bullish    = "bullish"
+	Bearish    = "bearish"
+	Neutral    = "neutral"
+	Overbought = "overbought"
+	Oversold   = "oversold"
+)
+
+// defaults are the indicators by name with their default parameters, in the
+// order they are reported.
+var defaults = []Spec{
+	{Name: "sma", Params: []float64{20}},
+	{Name: "ema", Params: []float64{20}},
+	{Name: "rsi", Params: []float64{14}},
+	{Name: "macd", Params: []float64{12, 26, 9}},
+	{Name: "bollinger", Params: []float64{20, 2}},
+	{Name: "atr", Params: []float64{14}},
+	{Name: "vwap"},
+	{Name: "supertrend", Params: []float64{10, 3}},
+}
+
+// Names are the indicators Evaluate knows.
+func Names() []string {
+	names := make([]string, len(defaults))
+	for i, d := range defaults {
+		names[i] = d.Name
+	}
+	return names
+}
+
+// Defaults returns every indicator with its default parameters.
+func Defaults() []Spec {
+	specs := make([]Spec, len(defaults))
+	for i, d := range defaults {
+		specs[i] = Spec{Name: d.Name, Params: append([]float64(nil), d.Params...)}
+	}
+	return specs
+}
+
+// Spec is an indicator with its parameters, written like rsi(14) or
+// macd(12,26,9).
+type Spec struct {
+	Name   string
+	Params []float64
+}
+
+func (s Spec) String() string {
+	if len(s.Params) == 0 {
+		return s.Name
+	}
+	params := make([]string, len(s.Params))
+	for i, p := range s.Params {
+		params[i] = strconv.FormatFloat(p, 'f', -1, 64)
+	}
+	return s.Name + "(" + strings.Join(params, ",") + ")"
+}
+
+// ParseSpec reads an indicator such as "sma", "sma(50)" or
+// "bollinger(20, 2.5)". Parameters left out take their defaults.
+func ParseSpec(s string) (Spec, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	name, args, hasArgs := strings.Cut(s, "(")
+	name = strings.TrimSpace(name)
+	var params []float64
+	if hasArgs {
+		args, ok := strings.CutSuffix(strings.TrimSpace(args), ")")
+		if !ok {
+			return Spec{}, fmt.Errorf("invalid indicator %q, missing )", s)
+		}
+		if strings.TrimSpace(args) != "" {
+			for _, a := range strings.Split(args, ",") {
+				p, err := strconv.ParseFloat(strings.TrimSpace(a), 64)
+				if err != nil || p <= 0 {
+					return Spec{}, fmt.Errorf("invalid parameter %q of %s, must be a positive number", strings.TrimSpace(a), name)
+				}
+				params = append(params, p)
+			}
+		}
+	}
+	return NewSpec(name, params...)
+}
+
+// NewSpec checks an indicator name and its parameters, filling in the
+// defaults of the ones left out.
+func NewSpec(name string, params ...float64) (Spec, error) {
+	for _, d := range defaults {
+		if d.Name != name {
+			continue
+		}
+		if len(params) > len(d.Params) {
+			return Spec{}, fmt.Errorf("%s takes at most %d parameters, got %d", name, len(d.Params), len(params))
+		}
+		spec := Spec{Name: name, Params: append(params[:len(params):len(params)], d.Params[len(params):]...)}
+		for i, p := range spec.Params {
+			// periods are counts of candles, the multipliers of bollinger and
+			// supertrend may be fractional
+			if (name != "bollinger" && name != "supertrend" || i == 0) && p != math.Trunc(p) {
+				return Spec{}, fmt.Errorf("period %v of %s must be a whole number", p, name)
+			}
+		}
+		return spec, nil
+	}
+	return Spec{}, fmt.Errorf("unknown indicator %q, must be one of %s", name, strings.Join(Names(), ", "))
+}
+
+func (s Spec) period(i int) int {
+	return int(s.Params[i])
+}
+
+// Series returns the values of the indicator over bars, by output name. The
+// first output is the main line, e.g. "macd" before "signal".
+func (s Spec) Series(bars []Bar) (names []string, series [][]float64) {
+	closes := Closes(bars)
+	switch s.Name {
+	case "sma":
+		return []string{"sma"}, [][]float64{SMA(closes, s.period(0))}
+	case "ema":
+		return []string{"ema"}, [][]float64{EMA(closes, s.period(0))}
+	case "rsi":
+		return []string{"rsi"}, [][]float64{RSI(closes, s.period(0))}
+	case "macd":
+		macd, sig, hist := MACD(closes, s.period(0), s.period(1), s.period(2))
+		return []string{"macd", "signal", "histogram"}, [][]float64{macd, sig, hist}
+	case "bollinger":
+		middle, upper, lower := Bollinger(closes, s.period(0), s.Params[1])
+		return []string{"middle", "upper", "lower"}, [][]float64{middle, upper, lower}
+	case "atr":
+		return []string{"atr"}, [][]float64{ATR(bars, s.period(0))}
+	case "vwap":
+		return []string{"vwap"}, [][]float64{VWAP(bars)}
+	case "supertrend":
+		line, up := Supertrend(bars, s.period(0), s.Params[1])
+		trend := make([]float64, len(up))
+		for i := range up {
+			switch {
+			case math.IsNaN(line[i]):
+				trend[i] = math.NaN()
+			case up[i]:
+				trend[i] = 1
+			default:
+				trend[i] = -1
+			}
+		}
+		return []string{"supertrend", "trend"}, [][]float64{line, trend}
+	}
+	return nil, nil
+}
+
+// Reading is the latest value of an indicator with a plain interpretation.
+type Reading struct {
+	Indicator string             `json:"indicator"`
+	Values    map[string]float64 `json:"values"`
+	Signal    string             `json:"signal"`
+	Summary   string             `json:"summary"`
+}
+
+// Evaluate reads the indicator at the last bar. It fails when there are too
+// few bars for its period.
+func Evaluate(bars []Bar, spec Spec) (Reading, error) {
+	names, series := spec.Series(bars)
+	if names == nil {
+		return Reading{}, fmt.Errorf("unknown indicator %q", spec.Name)
+	}
+	n := len(bars)
+	if n == 0 || math.IsNaN(series[len(series)-1][n-1]) {
+		return Reading{}, fmt.Errorf("%s needs more candles than the %d available, use a longer range or a smaller interval", spec, n)
+	}
+	last := make(map[string]float64, len(names))
+	prev := make(map[string]float64, len(names))
+	for i, name := range names {
+		last[name] = series[i][n-1]
+		prev[name] = math.NaN()
+		if n > 1 {
+			prev[name] = series[i][n-2]
+		}
+	}
+	close := bars[n-1].Close
+	r := Reading{Indicator: spec.String(), Values: map[string]float64{}}
+	for name, v := range last {
+		r.Values[name] = round(v)
+	}
+
+	switch spec.Name {
+	case "sma", "ema", "vwap":
+		v := last[names[0]]
+		label := strings.ToUpper(spec.String())
+		r.Signal, r.Summary = position(close, v, label)
+		if crossed(bars, prev[names[0]], v) {
+			r.Summary += fmt.Sprintf(", it crossed %s on the last candle", direction(close, v))
+		}
+	case "rsi":
+		v := last["rsi"]
+		r.Signal = Neutral
+		switch {
+		case v >= 70:
+			r.Signal = Overbought
+		case v <= 30:
+			r.Signal = Oversold
+		}
+		r.Summary = fmt.Sprintf("RSI(%d) is %.2f, %s", spec.period(0), v, r.Signal)
+	case "macd":
+		r.Signal, r.Summary = Bullish, "MACD is above its signal line"
+		if last["histogram"] < 0 {
+			r.Signal, r.Summary = Bearish, "MACD is below its signal line"
+		}
+		if !math.IsNaN(prev["histogram"]) && (prev["histogram"] < 0) != (last["histogram"] < 0) {
+			r.Summary += ", it crossed on the last candle"
+		}
+	case "bollinger":
+		upper, lower := last["upper"], last["lower"]
+		percentB := 0.5
+		if upper > lower {
+			percentB = (close - lower) / (upper - lower)
+		}
+		r.Values["percent_b"] = round(percentB)
+		r.Values["bandwidth"] = round((upper - lower) / last["middle"] * 100)
+		switch {
+		case close > upper:
+			r.Signal, r.Summary = Overbought, fmt.Sprintf("close %.2f is above the upper band %.2f", close, upper)
+		case close < lower:
+			r.Signal, r.Summary = Oversold, fmt.Sprintf("close %.2f is below the lower band %.2f", close, lower)
+		default:
+			r.Signal, r.Summary = Neutral, fmt.Sprintf("close %.2f is inside the bands, at %.0f%% of their width", close, percentB*100)
+		}
+	case "atr":
+		v := last["atr"]
+		r.Signal = Neutral
+		r.Values["atr_percent"] = round(v / close * 100)
+		r.Summary = fmt.Sprintf("ATR(%d) is %.2f, %.2f%% of the close, the typical range of a candle", spec.period(0), v, v/close*100)
+	case "supertrend":
+		r.Signal, r.Summary = Bullish, fmt.Sprintf("uptrend, the supertrend %.2f is below the price", last["supertrend"])
+		if last["trend"] < 0 {
+			r.Signal, r.Summary = Bearish, fmt.Sprintf("downtrend, the supertrend %.2f is above the price", last["supertrend"])
+		}
+		if !math.IsNaN(prev["trend"]) && prev["trend"] != last["trend"] {
+			r.Summary += ", the trend turned on the last candle"
+		}
+	}
+	return r, nil
+}
+
+// position compares the close with a moving line.
+func position(close, line float64, label string) (string, string) {
+	pct := (close - line) / line * 100
+	if close >= line {
+		return Bullish, fmt.Sprintf("close %.2f is %.2f%% above the %s of %.2f", close, pct, label, line)
+	}
+	return Bearish, fmt.Sprintf("close %.2f is %.2f%% below the %s of %.2f", close, -pct, label, line)
+}
+
+// crossed tells whether the close moved to the other side of a line between
+// the last two bars.
+func crossed(bars []Bar, prevLine, line float64) bool {
+	n := len(bars)
+	if n < 2 || math.IsNaN(prevLine) {
+		return false
+	}
+	return (bars[n-2].Close >= prevLine) != (bars[n-1].Close >= line)
+}
+
+func direction(close, line float64) string {
+	if close >= line {
+		return "above"
+	}
+	return "below"
+}
+
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package internal
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	LoginModeBrowser  = "browser"
+	LoginModeHeadless = "headless"
+
+	loginCodeParam    = "login_code"
+	loginCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+var (
+	// LoginMode selects how a login is presented to the user, set from main.
+	LoginMode = LoginModeBrowser
+
+	// LoginWaitTimeout bounds how long API calls wait for a pending login.
+	LoginWaitTimeout = 2 * time.Minute
+)
+
+// LoginLink is the login page URL of a pending login and the short code the
+// user can match against the callback page.
+type LoginLink struct {
+	URL  string `json:"login_url"`
+	Code string `json:"code"`
+}
+
+// LoginHint describes how to finish the pending login of the caller's
+// session, empty if there is none.
+func LoginHint(ctx context.Context) string {
+	link := AuthFromContext(ctx).PendingLoginLink()
+	if link == nil {
+		return ""
+	}
+	return fmt.Sprintf("Open %s to log in to Wealthy (code %s). If the page cannot redirect back, "+
+		"copy the authorization_token from the final URL and submit it with the submit_authorization_token tool.",
+		link.URL, link.Code)
+}
+
+// PromptForToken reads pasted authorization tokens, or redirect URLs, from r
+// and completes the pending login with them. It returns when r is exhausted.
+func PromptForToken(ctx context.Context, r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		input := strings.TrimSpace(scanner.Text())
+		if input == "" {
+			continue
+		}
+		if Auth.State() != AUTH_STARTED {
+			fmt.Fprintln(os.Stderr, "No login is pending, ignoring input.")
+			continue
+		}
+		if err := completeLogin(ctx, Auth, input); err != nil {
+			fmt.Fprintln(os.Stderr, "Login failed:", err)
+			continue
+		}
+		fmt.Fprintln(os.Stderr, "Login successful.")
+	}
+}
+
+// newLoginLink issues a login link for the login m has just started.
+func newLoginLink(m *AuthManager) *LoginLink {
+	code := newLoginCode()
+	callback := m.CallbackURL()
+	if u, err := url.Parse(callback); err == nil {
+		q := u.Query()
+		q.Set(loginCodeParam, code)
+		u.RawQuery = q.Encode()
+		callback = u.String()
+	}
+	link := &LoginLink{
+		URL:  fmt.Sprintf(loginURL+"?redirect_url=%s", url.QueryEscape(callback)),
+		Code: code,
+	}
+
+	m.setLoginLink(link)
+	return link
+}
+
+func matchesLoginCode(m *AuthManager, code string) bool {
+	link := m.PendingLoginLink()
+	return link != nil && strings.EqualFold(link.Code, code)
+}
+
+func printLoginInstructions(link *LoginLink) {
+	fmt.Fprintf(os.Stderr, `
+To log in to Wealthy, open this link on any device:
+
+    %s
+
+Login code: %s
+
+If the page cannot redirect back to this server, copy the authorization_token
+from the final URL (or the whole URL) and paste it here or submit it with the
+submit_authorization_token tool.
+
+`, link.URL, link.Code)
+}
+
+// newLoginCode returns a short human friendly code such as "K7PQ-3XWA".
+func newLoginCode() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		slog.Warn("failed to generate login code", "error", err)
+	}
+	code := make([]byte, 0, 9)
+	for i, c := range b {
+		if i == 4 {
+			code = append(code, '-')
+		}
+		code = append(code, loginCodeAlphabet[int(c)%len(loginCodeAlphabet)])
+	}
+	return string(code)
+}
+
+// parseAuthorizationToken accepts either a bare authorization token or a
+// redirect URL carrying it in the authorization_token query parameter.
+func parseAuthorizationToken(input string) string {
+	input = strings.TrimSpace(input)
+	if u, err := url.Parse(input); err == nil && u.Scheme != "" {
+		return u.Query().Get("authorization_token")
+	}
+	return input
+}
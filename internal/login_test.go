@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// stubTokenExchange answers the token exchange with an access token derived
+// from the authorization token, and records the authorization tokens sent.
+func stubTokenExchange(t *testing.T) *[]string {
+	var exchanged []string
+	saved := httpClient
+	httpClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return nil, err
+		}
+		exchanged = append(exchanged, body["authorization_token"])
+		resp := `{"access_token":"access-` + body["authorization_token"] + `"}`
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(resp)), Header: http.Header{}}, nil
+	})}
+	t.Cleanup(func() { httpClient = saved })
+	return &exchanged
+}
+
+// pendingLogin replaces the server login with one that has just started.
+func pendingLogin(t *testing.T) *LoginLink {
+	saved := Auth
+	Auth = NewAuthManager()
+	t.Cleanup(func() { Auth = saved })
+	Auth.SetCallbackURL("http://localhost:8080/callback")
+	Auth.Start()
+	return newLoginLink(Auth)
+}
+
+func TestParseAuthorizationToken(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "bare token", input: "abc123", want: "abc123"},
+		{name: "bare token with spaces", input: "  abc123\n", want: "abc123"},
+		{name: "redirect URL", input: "http://localhost:8080/callback?login_code=K7PQ-3XWA&authorization_token=abc123", want: "abc123"},
+		{name: "escaped token", input: "https://example.com/cb?authorization_token=a%2Bb", want: "a+b"},
+		{name: "URL without token", input: "http://localhost:8080/callback?login_code=K7PQ-3XWA", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseAuthorizationToken(tt.input))
+		})
+	}
+}
+
+func TestNewLoginCode(t *testing.T) {
+	format := regexp.MustCompile(`^[` + loginCodeAlphabet + `]{4}-[` + loginCodeAlphabet + `]{4}$`)
+	first := newLoginCode()
+	assert.Regexp(t, format, first)
+	assert.NotEqual(t, first, newLoginCode())
+}
+
+func TestCompleteLoginRejectsURLWithoutToken(t *testing.T) {
+	exchanged := stubTokenExchange(t)
+	pendingLogin(t)
+
+	err := completeLogin(context.Background(), Auth, "http://localhost:8080/callback?login_code=K7PQ-3XWA")
+	assert.EqualError(t, err, "authorization_token is empty")
+	assert.Empty(t, *exchanged)
+	assert.Equal(t, AUTH_STARTED, Auth.State())
+}
+
+func TestAuthHandlerLoginCode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	exchanged := stubTokenExchange(t)
+	link := pendingLogin(t)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "stale code", query: "authorization_token=old&login_code=AAAA-AAAA", wantStatus: http.StatusBadRequest, wantBody: "This login link has expired"},
+		{name: "unknown session", query: "authorization_token=old&login_code=" + link.Code + "&" + sessionParam + "=missing", wantStatus: http.StatusBadRequest, wantBody: "Unknown login session"},
+		{name: "matching code", query: "authorization_token=new&login_code=" + strings.ToLower(link.Code), wantStatus: http.StatusOK, wantBody: "Authentication Successful"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/callback?"+tt.query, nil)
+			AuthHandler(c)
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.wantBody)
+		})
+	}
+	assert.Equal(t, []string{"new"}, *exchanged, "only the matching code is exchanged")
+	assert.Equal(t, AUTH_SUCCESS, Auth.State())
+	assert.Equal(t, "access-new", Auth.Token())
+}
+
+func TestPromptForToken(t *testing.T) {
+	exchanged := stubTokenExchange(t)
+	pendingLogin(t)
+
+	input := "\n  \nhttp://localhost:8080/callback?authorization_token=pasted\nignored-after-login\n"
+	PromptForToken(context.Background(), strings.NewReader(input))
+
+	require.Equal(t, AUTH_SUCCESS, Auth.State())
+	assert.Equal(t, "access-pasted", Auth.Token())
+	assert.Equal(t, []string{"pasted"}, *exchanged, "input is ignored once no login is pending")
+}
@@ -0,0 +1,24 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package notify
+
+import (
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/wealthy/wealthy-mcp/internal/alerts"
+)
+
+// ForwardAlerts notifies the sessions of h of every alert engine fires with a
+// logging message the assistant can relay. It returns a function that stops
+// forwarding.
+func ForwardAlerts(h *Hub, engine *alerts.Engine) func() {
+	events, cancel := engine.Watch()
+	go func() {
+		for e := range events {
+			h.Log(mcp.LoggingLevelNotice, "alerts", e)
+		}
+	}()
+	return cancel
+}
@@ -0,0 +1,245 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package notify pushes server initiated MCP notifications to the connected
+// client sessions.
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/wealthy/wealthy-mcp/internal/transport"
+)
+
+const (
+	methodLogMessage      = "notifications/message"
+	methodResourceUpdated = "notifications/resources/updated"
+)
+
+// closer is implemented by sessions that report when they end, such as the
+// Streamable HTTP sessions.
+type closer interface {
+	Done() <-chan struct{}
+}
+
+// Watcher starts following the changes of a resource when the first session
+// subscribes to it, and returns the function that stops it once the last one
+// has unsubscribed. ctx is the context of the subscribing request, it ends
+// with the request.
+type Watcher func(ctx context.Context, uri string) (stop func(), err error)
+
+type watcher struct {
+	prefix string
+	watch  Watcher
+}
+
+// Hub tracks the client sessions of an MCPServer, which does not expose a
+// way to notify all of them, and their resource subscriptions.
+type Hub struct {
+	mu       sync.Mutex
+	sessions map[string]server.ClientSession
+	watchers []watcher
+	// subs holds the subscribed session IDs by resource URI
+	subs  map[string]map[string]bool
+	stops map[string]func()
+}
+
+// Clients is the hub of the server's sessions.
+var Clients = NewHub()
+
+func NewHub() *Hub {
+	return &Hub{
+		sessions: make(map[string]server.ClientSession),
+		subs:     make(map[string]map[string]bool),
+		stops:    make(map[string]func()),
+	}
+}
+
+// WatchResources lets sessions subscribe to the resources whose URI starts
+// with prefix, following their changes with w.
+func (h *Hub) WatchResources(prefix string, w Watcher) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.watchers = append(h.watchers, watcher{prefix: prefix, watch: w})
+}
+
+// Attach adds the hook that registers new sessions with the hub.
+func (h *Hub) Attach(hooks *server.Hooks) {
+	hooks.AddOnRegisterSession(func(ctx context.Context, session server.ClientSession) {
+		h.add(session)
+	})
+}
+
+func (h *Hub) add(session server.ClientSession) {
+	h.mu.Lock()
+	h.sessions[session.SessionID()] = session
+	h.mu.Unlock()
+	if c, ok := session.(closer); ok {
+		go func() {
+			<-c.Done()
+			h.remove(session)
+		}()
+	}
+}
+
+func (h *Hub) remove(session server.ClientSession) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.sessions[session.SessionID()] == session {
+		h.dropLocked(session.SessionID())
+	}
+}
+
+// dropLocked forgets a session and its subscriptions. Callers must hold h.mu.
+func (h *Hub) dropLocked(sessionID string) {
+	delete(h.sessions, sessionID)
+	for uri := range h.subs {
+		h.unsubscribeLocked(sessionID, uri)
+	}
+}
+
+// Subscribe subscribes a session to the updates of a resource.
+func (h *Hub) Subscribe(ctx context.Context, sessionID, uri string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs[uri][sessionID] {
+		return nil
+	}
+	if len(h.subs[uri]) == 0 {
+		w, ok := h.watcherFor(uri)
+		if !ok {
+			return fmt.Errorf("resource %s does not support subscriptions", uri)
+		}
+		stop, err := w(ctx, uri)
+		if err != nil {
+			return err
+		}
+		h.stops[uri] = stop
+		h.subs[uri] = make(map[string]bool)
+	}
+	h.subs[uri][sessionID] = true
+	return nil
+}
+
+// Unsubscribe ends a session's subscription to a resource.
+func (h *Hub) Unsubscribe(sessionID, uri string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.unsubscribeLocked(sessionID, uri)
+}
+
+func (h *Hub) unsubscribeLocked(sessionID, uri string) {
+	subs, ok := h.subs[uri]
+	if !ok || !subs[sessionID] {
+		return
+	}
+	delete(subs, sessionID)
+	if len(subs) == 0 {
+		delete(h.subs, uri)
+		if stop := h.stops[uri]; stop != nil {
+			stop()
+		}
+		delete(h.stops, uri)
+	}
+}
+
+func (h *Hub) watcherFor(uri string) (Watcher, bool) {
+	for _, w := range h.watchers {
+		if strings.HasPrefix(uri, w.prefix) {
+			return w.watch, true
+		}
+	}
+	return nil, false
+}
+
+// Methods serves resources/subscribe and resources/unsubscribe, which the
+// MCPServer does not implement, for the transports that can intercept them.
+func (h *Hub) Methods() transport.Methods {
+	uri := func(params json.RawMessage) (string, error) {
+		var p struct {
+			URI string `json:"uri"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil || p.URI == "" {
+			return "", fmt.Errorf("uri is required")
+		}
+		return p.URI, nil
+	}
+	return transport.Methods{
+		"resources/subscribe": func(ctx context.Context, sessionID string, params json.RawMessage) (any, error) {
+			u, err := uri(params)
+			if err != nil {
+				return nil, err
+			}
+			return nil, h.Subscribe(ctx, sessionID, u)
+		},
+		"resources/unsubscribe": func(ctx context.Context, sessionID string, params json.RawMessage) (any, error) {
+			u, err := uri(params)
+			if err != nil {
+				return nil, err
+			}
+			h.Unsubscribe(sessionID, u)
+			return nil, nil
+		},
+	}
+}
+
+// Log sends a logging message notification to every session.
+func (h *Hub) Log(level mcp.LoggingLevel, logger string, data any) {
+	h.Broadcast(methodLogMessage, map[string]any{"level": level, "logger": logger, "data": data})
+}
+
+// ResourceUpdated tells the sessions subscribed to a resource that it
+// changed.
+func (h *Hub) ResourceUpdated(uri string) {
+	n := notification(methodResourceUpdated, map[string]any{"uri": uri})
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for id := range h.subs[uri] {
+		if session, ok := h.sessions[id]; ok {
+			h.sendLocked(id, session, n)
+		}
+	}
+}
+
+// Broadcast sends a notification to every initialized session. A session
+// whose notification channel is full has most likely gone away without
+// telling, it is dropped rather than blocking the sender.
+func (h *Hub) Broadcast(method string, params map[string]any) {
+	n := notification(method, params)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for id, session := range h.sessions {
+		h.sendLocked(id, session, n)
+	}
+}
+
+// sendLocked sends a notification to an initialized session. Callers must
+// hold h.mu.
+func (h *Hub) sendLocked(id string, session server.ClientSession, n mcp.JSONRPCNotification) {
+	if !session.Initialized() {
+		return
+	}
+	select {
+	case session.NotificationChannel() <- n:
+	default:
+		h.dropLocked(id)
+	}
+}
+
+func notification(method string, params map[string]any) mcp.JSONRPCNotification {
+	return mcp.JSONRPCNotification{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		Notification: mcp.Notification{
+			Method: method,
+			Params: mcp.NotificationParams{AdditionalFields: params},
+		},
+	}
+}
@@ -0,0 +1,148 @@
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealthy/wealthy-mcp/internal/websocket"
+)
+
+type fakeSession struct {
+	id            string
+	initialized   bool
+	notifications chan mcp.JSONRPCNotification
+}
+
+func (s *fakeSession) SessionID() string                                   { return s.id }
+func (s *fakeSession) Initialize()                                         { s.initialized = true }
+func (s *fakeSession) Initialized() bool                                   { return s.initialized }
+func (s *fakeSession) NotificationChannel() chan<- mcp.JSONRPCNotification { return s.notifications }
+
+// closingSession reports when it ends.
+type closingSession struct {
+	fakeSession
+	done chan struct{}
+}
+
+func (s *closingSession) Done() <-chan struct{} { return s.done }
+
+func newSession(id string, buffer int) *fakeSession {
+	return &fakeSession{id: id, initialized: true, notifications: make(chan mcp.JSONRPCNotification, buffer)}
+}
+
+func TestHubBroadcast(t *testing.T) {
+	h := NewHub()
+	hooks := &server.Hooks{}
+	h.Attach(hooks)
+
+	live := newSession("live", 4)
+	full := newSession("full", 0)
+	uninitialized := newSession("new", 4)
+	uninitialized.initialized = false
+	for _, s := range []*fakeSession{live, full, uninitialized} {
+		hooks.RegisterSession(context.Background(), s)
+	}
+
+	h.Log(mcp.LoggingLevelInfo, "orders", "filled")
+	require.Len(t, live.notifications, 1)
+	n := <-live.notifications
+	assert.Equal(t, "notifications/message", n.Method)
+	assert.Equal(t, map[string]any{"level": mcp.LoggingLevelInfo, "logger": "orders", "data": "filled"}, n.Params.AdditionalFields)
+	assert.Empty(t, uninitialized.notifications)
+
+	h.mu.Lock()
+	assert.NotContains(t, h.sessions, "full", "a session that does not drain its channel is dropped")
+	assert.Contains(t, h.sessions, "new")
+	h.mu.Unlock()
+}
+
+func TestHubResourceSubscriptions(t *testing.T) {
+	h := NewHub()
+	var started, stopped []string
+	h.WatchResources("wealthy://quote/", func(ctx context.Context, uri string) (func(), error) {
+		started = append(started, uri)
+		return func() { stopped = append(stopped, uri) }, nil
+	})
+	a, b := newSession("a", 4), newSession("b", 4)
+	h.add(a)
+	h.add(b)
+
+	const uri = "wealthy://quote/nse/2885"
+	ctx := context.Background()
+	require.NoError(t, h.Subscribe(ctx, "a", uri))
+	require.NoError(t, h.Subscribe(ctx, "a", uri))
+	require.NoError(t, h.Subscribe(ctx, "b", uri))
+	assert.Equal(t, []string{uri}, started, "the watcher starts with the first subscriber")
+	assert.ErrorContains(t, h.Subscribe(ctx, "a", "wealthy://unknown"), "does not support subscriptions")
+
+	h.ResourceUpdated(uri)
+	h.ResourceUpdated("wealthy://quote/nse/1594")
+	assert.Len(t, a.notifications, 1)
+	assert.Len(t, b.notifications, 1)
+	n := <-a.notifications
+	assert.Equal(t, "notifications/resources/updated", n.Method)
+	assert.Equal(t, map[string]any{"uri": uri}, n.Params.AdditionalFields)
+
+	h.Unsubscribe("a", uri)
+	assert.Empty(t, stopped)
+	h.remove(b)
+	assert.Equal(t, []string{uri}, stopped, "the watcher stops with the last subscriber")
+	h.ResourceUpdated(uri)
+	assert.Empty(t, a.notifications)
+}
+
+func TestHubMethods(t *testing.T) {
+	h := NewHub()
+	h.WatchResources("wealthy://orders", func(ctx context.Context, uri string) (func(), error) {
+		return func() {}, nil
+	})
+	methods := h.Methods()
+
+	_, err := methods["resources/subscribe"](context.Background(), "s", []byte(`{"uri":"wealthy://orders"}`))
+	require.NoError(t, err)
+	assert.True(t, h.subs["wealthy://orders"]["s"])
+	_, err = methods["resources/subscribe"](context.Background(), "s", []byte(`{}`))
+	assert.ErrorContains(t, err, "uri is required")
+	_, err = methods["resources/unsubscribe"](context.Background(), "s", []byte(`{"uri":"wealthy://orders"}`))
+	require.NoError(t, err)
+	assert.Empty(t, h.subs)
+}
+
+func TestHubForgetsClosedSessions(t *testing.T) {
+	h := NewHub()
+	s := &closingSession{fakeSession: *newSession("s", 1), done: make(chan struct{})}
+	h.add(s)
+	close(s.done)
+	assert.Eventually(t, func() bool {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return len(h.sessions) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestForwardOrders(t *testing.T) {
+	h := NewHub()
+	s := newSession("s", 4)
+	h.add(s)
+	tracker := websocket.NewOrderTracker()
+	stop := ForwardOrders(h, tracker)
+	defer stop()
+
+	tracker.Apply(&websocket.OrderUpdate{OrderId: "1", TradingSymbol: "RELIANCE-EQ", TransactionType: 1, Quantity: 5, RejectReason: "insufficient margin"}, time.Now())
+
+	var log mcp.JSONRPCNotification
+	select {
+	case log = <-s.notifications:
+	case <-time.After(time.Second):
+		t.Fatal("no notification sent")
+	}
+	assert.Equal(t, "notifications/message", log.Method)
+	assert.Equal(t, mcp.LoggingLevelWarning, log.Params.AdditionalFields["level"])
+	event := log.Params.AdditionalFields["data"].(websocket.OrderEvent)
+	assert.Equal(t, "Your RELIANCE-EQ BUY order 1 was rejected: insufficient margin", event.Message)
+}
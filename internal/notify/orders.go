@@ -0,0 +1,28 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package notify
+
+import (
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/wealthy/wealthy-mcp/internal/websocket"
+)
+
+// ForwardOrders notifies the sessions of h of every order event of tracker
+// with a logging message the assistant can relay. It returns a function that
+// stops forwarding.
+func ForwardOrders(h *Hub, tracker *websocket.OrderTracker) func() {
+	events, cancel := tracker.Watch()
+	go func() {
+		for e := range events {
+			level := mcp.LoggingLevelInfo
+			if e.Order.State == websocket.OrderRejected {
+				level = mcp.LoggingLevelWarning
+			}
+			h.Log(level, "orders", e)
+		}
+	}()
+	return cancel
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package orders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+)
+
+// LegResult is the outcome of one order of a basket.
+type LegResult struct {
+	Leg             int    `json:"leg"`
+	TradingSymbol   string `json:"trading_symbol"`
+	TransactionType string `json:"transaction_type"`
+	Quantity        int    `json:"quantity"`
+	OrderID         string `json:"order_id,omitempty"`
+	Status          string `json:"status,omitempty"`
+	Placed          bool   `json:"placed"`
+	RolledBack      bool   `json:"rolled_back,omitempty"`
+	Error           string `json:"error,omitempty"`
+}
+
+// BasketResult is the outcome of a basket order.
+type BasketResult struct {
+	// Placed is true when every leg was placed
+	Placed     bool        `json:"placed"`
+	RolledBack bool        `json:"rolled_back,omitempty"`
+	Legs       []LegResult `json:"legs"`
+}
+
+// ValidateBasket validates every leg of a basket in place and reports the
+// problems of all legs at once.
+func ValidateBasket(ctx context.Context, svc falcon.FalconService, legs []falcon.OrderReq) error {
+	if len(legs) == 0 {
+		return errors.New("orders: a basket needs at least one order")
+	}
+	var msgs []string
+	for i := range legs {
+		if err := svc.ValidateOrder(ctx, &legs[i]); err != nil {
+			msgs = append(msgs, fmt.Sprintf("leg %d: %v", i+1, err))
+		}
+	}
+	if len(msgs) > 0 {
+		return fmt.Errorf("invalid basket: %s", strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// PlaceBasket places the legs of a basket in one call. With rollback, the legs
+// that were placed are cancelled again when any leg fails, so the basket is
+// either placed in full or not at all. Legs that fill at once cannot be
+// cancelled and are reported as not rolled back.
+func PlaceBasket(ctx context.Context, svc falcon.FalconService, legs []falcon.OrderReq, rollback bool) (*BasketResult, error) {
+	if err := ValidateBasket(ctx, svc, legs); err != nil {
+		return nil, err
+	}
+	resp, err := svc.PlaceOrder(ctx, legs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BasketResult{Placed: true, Legs: make([]LegResult, len(legs))}
+	for i, leg := range legs {
+		r := LegResult{
+			Leg:             i + 1,
+			TradingSymbol:   leg.TradingSymbol,
+			TransactionType: falcon.TransactionName(leg.TransactionType),
+			Quantity:        leg.Quantity,
+		}
+		if i < len(resp) {
+			r.OrderID = resp[i].OrderID
+			r.Status = resp[i].Status
+			r.Placed = legPlaced(resp[i])
+		}
+		if !r.Placed {
+			r.Error = "the leg was not placed"
+			if r.Status != "" {
+				r.Error = fmt.Sprintf("the leg was not placed, status %s", r.Status)
+			}
+			result.Placed = false
+		}
+		result.Legs[i] = r
+	}
+
+	if !result.Placed && rollback {
+		result.RolledBack = true
+		for i := range result.Legs {
+			r := &result.Legs[i]
+			if !r.Placed {
+				continue
+			}
+			if _, err := svc.CancelOrder(ctx, falcon.CancelOrderReq{OrderID: r.OrderID, OrderType: legs[i].OrderType}); err != nil {
+				r.Error = fmt.Sprintf("could not roll back, the order may already be filled: %v", err)
+				result.RolledBack = false
+				continue
+			}
+			r.RolledBack = true
+		}
+	}
+	return result, nil
+}
+
+// legPlaced reports whether the basket endpoint accepted a leg. A leg without
+// an order ID, or with a rejected or failed status, was not placed.
+func legPlaced(resp falcon.PlaceOrderResponse) bool {
+	if resp.OrderID == "" {
+		return false
+	}
+	status := strings.ToLower(resp.Status)
+	return !strings.Contains(status, "reject") && !strings.Contains(status, "fail") && !strings.Contains(status, "error")
+}
+
+// PreviewBasket validates and prices every leg of a basket and checks the
+// margin of the buy legs against the available funds.
+func PreviewBasket(ctx context.Context, svc falcon.FalconService, req falcon.BasketOrderReq) (*Preview, error) {
+	legs := make([]falcon.OrderReq, len(req.Orders))
+	copy(legs, req.Orders)
+	if err := ValidateBasket(ctx, svc, legs); err != nil {
+		return nil, err
+	}
+
+	p := &Preview{Action: ActionBasket, Order: legs}
+	descriptions := make([]string, len(legs))
+	for i, leg := range legs {
+		lp := &Preview{}
+		lp.noteRounding(req.Orders[i], leg)
+		if err := lp.price(ctx, svc, leg); err != nil {
+			return nil, fmt.Errorf("leg %d: %w", i+1, err)
+		}
+		for _, w := range lp.Warnings {
+			p.Warnings = append(p.Warnings, fmt.Sprintf("leg %d: %s", i+1, w))
+		}
+		p.EstimatedValue += lp.EstimatedValue
+		if leg.TransactionType == falcon.TransactionBuy {
+			p.RequiredMargin += lp.EstimatedValue
+		}
+		descriptions[i] = describe(leg)
+	}
+	p.compareMargin(ctx, svc)
+	p.Summary = fmt.Sprintf("Basket of %d orders: %s. Estimated value %s", len(legs),
+		strings.Join(descriptions, "; "), rupees(p.EstimatedValue))
+	if err := p.issue(ctx, req); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
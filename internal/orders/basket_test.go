@@ -0,0 +1,111 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+)
+
+func basketLegs() []falcon.OrderReq {
+	leg := falcon.OrderReq{
+		ExchangeName:    falcon.ExchangeNSE,
+		TradingSymbol:   "RELIANCE-EQ",
+		Quantity:        10,
+		Price:           "2450",
+		TransactionType: falcon.TransactionBuy,
+		PriceType:       falcon.PriceTypeLimit,
+		Validity:        falcon.ValidityDay,
+	}
+	sell := leg
+	sell.TransactionType, sell.Price = falcon.TransactionSell, "2550"
+	return []falcon.OrderReq{leg, sell}
+}
+
+func TestPlaceBasket(t *testing.T) {
+	tests := []struct {
+		name          string
+		placed        []falcon.PlaceOrderResponse
+		cancelErr     error
+		rollback      bool
+		wantPlaced    bool
+		wantRollback  bool
+		wantCancelled []string
+		wantErrors    []string
+	}{
+		{
+			name:       "all legs placed",
+			placed:     []falcon.PlaceOrderResponse{{OrderID: "1", Status: "open"}, {OrderID: "2", Status: "open"}},
+			rollback:   true,
+			wantPlaced: true,
+			wantErrors: []string{"", ""},
+		},
+		{
+			name:          "failed leg rolls back the others",
+			placed:        []falcon.PlaceOrderResponse{{OrderID: "1", Status: "open"}, {Status: "rejected"}},
+			rollback:      true,
+			wantRollback:  true,
+			wantCancelled: []string{"1"},
+			wantErrors:    []string{"", "the leg was not placed, status rejected"},
+		},
+		{
+			name:       "failed leg without rollback",
+			placed:     []falcon.PlaceOrderResponse{{OrderID: "1", Status: "open"}, {OrderID: "2", Status: "Failed"}},
+			wantErrors: []string{"", "the leg was not placed, status Failed"},
+		},
+		{
+			name:      "leg that cannot be rolled back",
+			placed:    []falcon.PlaceOrderResponse{{OrderID: "1", Status: "complete"}},
+			cancelErr: errors.New("order already complete"),
+			rollback:  true,
+			wantErrors: []string{
+				"could not roll back, the order may already be filled: order already complete",
+				"the leg was not placed",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newStubService()
+			svc.placed, svc.cancelErr = tt.placed, tt.cancelErr
+
+			got, err := PlaceBasket(context.Background(), svc, basketLegs(), tt.rollback)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPlaced, got.Placed)
+			assert.Equal(t, tt.wantRollback, got.RolledBack)
+			assert.Equal(t, tt.wantCancelled, svc.cancelled)
+			require.Len(t, got.Legs, 2)
+			for i, leg := range got.Legs {
+				assert.Equal(t, i+1, leg.Leg)
+				assert.Equal(t, tt.wantErrors[i], leg.Error, "leg %d", i+1)
+			}
+		})
+	}
+}
+
+func TestPlaceBasketValidatesEveryLeg(t *testing.T) {
+	legs := basketLegs()
+	legs[0].Quantity = 0
+	legs[1].Price = ""
+
+	_, err := PlaceBasket(context.Background(), newStubService(), legs, true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "leg 1: invalid order: quantity: must be positive, got 0")
+	assert.Contains(t, err.Error(), "leg 2: invalid order: price: is required for LIMIT orders")
+}
+
+func TestPreviewBasket(t *testing.T) {
+	svc := newStubService()
+	req := falcon.BasketOrderReq{Orders: basketLegs()}
+
+	p, err := PreviewBasket(context.Background(), svc, req)
+	require.NoError(t, err)
+	assert.Equal(t, ActionBasket, p.Action)
+	assert.Equal(t, "Basket of 2 orders: BUY 10 RELIANCE-EQ on NSE at LIMIT ₹2450; SELL 10 RELIANCE-EQ on NSE at LIMIT ₹2550. Estimated value ₹50000.00", p.Summary)
+	assert.Equal(t, falcon.Paisa(2450000), p.RequiredMargin)
+	assert.Empty(t, p.Warnings)
+	assert.NoError(t, Confirm(context.Background(), ActionBasket, req, p.ConfirmationToken))
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package orders previews orders before they are sent to the exchange and
+// issues the confirmation tokens that release them.
+package orders
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wealthy/wealthy-mcp/internal"
+)
+
+// Actions a confirmation token can be issued for.
+const (
+	ActionPlace  = "place_order"
+	ActionModify = "modify_order"
+	ActionCancel = "cancel_order"
+	ActionBasket = "place_basket_order"
+)
+
+// confirmationTTL is how long a previewed order can be confirmed.
+const confirmationTTL = 5 * time.Minute
+
+var (
+	// DryRun makes every order call return a preview first, the order is only
+	// sent when the call is repeated with the preview's confirmation token.
+	// Set from main.
+	DryRun bool
+
+	ErrConfirmationRequired = errors.New("confirmation required: preview the order with dry_run and repeat the call with its confirmation_token")
+	ErrInvalidConfirmation  = errors.New("confirmation token is unknown, expired or was issued for a different order, preview the order again")
+
+	confirmations = newConfirmationStore(confirmationTTL)
+)
+
+// confirmationStore holds the tokens of previewed orders. A token is bound to
+// the action, the exact request and the session it was previewed in, and can
+// be used once.
+type confirmationStore struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	tokens map[string]confirmation
+}
+
+type confirmation struct {
+	fingerprint string
+	expiresAt   time.Time
+}
+
+func newConfirmationStore(ttl time.Duration) *confirmationStore {
+	return &confirmationStore{ttl: ttl, tokens: make(map[string]confirmation)}
+}
+
+// issue returns a new token for the request and when it expires.
+func (s *confirmationStore) issue(ctx context.Context, action string, req any) (string, time.Time, error) {
+	fp, err := fingerprint(ctx, action, req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate confirmation token: %w", err)
+	}
+	token := hex.EncodeToString(b)
+	now := time.Now()
+	expiresAt := now.Add(s.ttl)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for t, c := range s.tokens {
+		if now.After(c.expiresAt) {
+			delete(s.tokens, t)
+		}
+	}
+	s.tokens[token] = confirmation{fingerprint: fp, expiresAt: expiresAt}
+	return token, expiresAt, nil
+}
+
+// consume checks that token was issued for the request and invalidates it.
+func (s *confirmationStore) consume(ctx context.Context, action string, req any, token string) error {
+	fp, err := fingerprint(ctx, action, req)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.tokens[token]
+	if !ok || time.Now().After(c.expiresAt) || c.fingerprint != fp {
+		return ErrInvalidConfirmation
+	}
+	delete(s.tokens, token)
+	return nil
+}
+
+// Confirm checks the confirmation token of an order call. Without a token the
+// call may only proceed when the server is not in dry run mode.
+func Confirm(ctx context.Context, action string, req any, token string) error {
+	if token == "" {
+		if DryRun {
+			return ErrConfirmationRequired
+		}
+		return nil
+	}
+	return confirmations.consume(ctx, action, req, token)
+}
+
+func fingerprint(ctx context.Context, action string, req any) (string, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize order: %w", err)
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", action, internal.AuthFromContext(ctx).SessionID())
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
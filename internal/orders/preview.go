@@ -0,0 +1,251 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package orders
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+)
+
+// priceDeviationWarning is the distance from the last price, in percent,
+// beyond which a limit price is flagged in the preview.
+const priceDeviationWarning = 5
+
+// Preview describes what an order call would send to the exchange. The call
+// is carried out by repeating it with ConfirmationToken.
+type Preview struct {
+	Action            string                 `json:"action"`
+	Summary           string                 `json:"summary"`
+	Order             any                    `json:"order"`
+	Instrument        string                 `json:"instrument,omitempty"`
+	LastPrice         falcon.Money           `json:"ltp,omitempty"`
+	EstimatedValue    falcon.Money           `json:"estimated_value,omitempty"`
+	RequiredMargin    falcon.Money           `json:"required_margin,omitempty"`
+	AvailableMargin   falcon.Money           `json:"available_margin,omitempty"`
+	Existing          *falcon.OrderBookEntry `json:"existing_order,omitempty"`
+	Warnings          []string               `json:"warnings,omitempty"`
+	ConfirmationToken string                 `json:"confirmation_token"`
+	ExpiresAt         time.Time              `json:"confirmation_expires_at"`
+}
+
+// PreviewPlace validates a new order, prices it at the current market and
+// checks it against the available margin.
+func PreviewPlace(ctx context.Context, svc falcon.FalconService, req falcon.OrderReq) (*Preview, error) {
+	order := req
+	if err := svc.ValidateOrder(ctx, &order); err != nil {
+		return nil, err
+	}
+	p := &Preview{Action: ActionPlace, Order: order}
+	p.noteRounding(req, order)
+	if err := p.price(ctx, svc, order); err != nil {
+		return nil, err
+	}
+	p.checkMargin(ctx, svc, order.TransactionType)
+	p.Summary = fmt.Sprintf("%s. Estimated value %s", describe(order), rupees(p.EstimatedValue))
+	if err := p.issue(ctx, req); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// PreviewModify shows the changes a modification makes to an open order.
+func PreviewModify(ctx context.Context, svc falcon.FalconService, req falcon.ModifyOrderReq) (*Preview, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	order := req
+	if err := svc.ValidateOrder(ctx, &order.OrderReq); err != nil {
+		return nil, err
+	}
+	existing, err := findOrder(ctx, svc, req.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	p := &Preview{Action: ActionModify, Order: order, Existing: existing}
+	p.noteRounding(req.OrderReq, order.OrderReq)
+	if err := p.price(ctx, svc, order.OrderReq); err != nil {
+		return nil, err
+	}
+	p.checkMargin(ctx, svc, order.TransactionType)
+
+	changes := orderChanges(*existing, order.OrderReq)
+	if len(changes) == 0 {
+		p.Warnings = append(p.Warnings, "the modification does not change the order")
+		changes = []string{"no changes"}
+	}
+	p.Summary = fmt.Sprintf("Modify order %s (%s %d %s): %s. Estimated value %s", req.OrderID,
+		falcon.TransactionName(existing.TransactionType), existing.Quantity, existing.TradingSymbol,
+		strings.Join(changes, ", "), rupees(p.EstimatedValue))
+	if err := p.issue(ctx, req); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// PreviewCancel shows the open order a cancellation would remove.
+func PreviewCancel(ctx context.Context, svc falcon.FalconService, req falcon.CancelOrderReq) (*Preview, error) {
+	if req.OrderID == "" {
+		return nil, fmt.Errorf("order_id is required")
+	}
+	existing, err := findOrder(ctx, svc, req.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	p := &Preview{Action: ActionCancel, Order: req, Existing: existing}
+	p.Summary = fmt.Sprintf("Cancel order %s: %s %d %s %s, %d filled, %d pending", req.OrderID,
+		falcon.TransactionName(existing.TransactionType), existing.Quantity, existing.TradingSymbol,
+		falcon.PriceTypeName(existing.PriceType), existing.FilledShares, existing.PendingQuantity())
+	if existing.PendingQuantity() <= 0 {
+		p.Warnings = append(p.Warnings, "the order has nothing left to cancel")
+	}
+	if err := p.issue(ctx, req); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// price resolves the instrument through its quote and estimates the value of
+// the order at its limit price, or at the last price for market orders.
+func (p *Preview) price(ctx context.Context, svc falcon.FalconService, req falcon.OrderReq) error {
+	p.Instrument = falcon.QuoteSymbol(req.ExchangeName, req.TradingSymbol)
+	quotes, err := svc.GetPrice(ctx, &falcon.PriceReq{Symbols: []string{p.Instrument}})
+	if err != nil {
+		p.Warnings = append(p.Warnings, fmt.Sprintf("could not fetch the last price: %v", err))
+	} else {
+		quote, ok := quotes.Lookup(p.Instrument)
+		if !ok {
+			return fmt.Errorf("instrument %s not found, check trading_symbol and exchange_name with the search tool", p.Instrument)
+		}
+		p.LastPrice = quote.LastPrice
+		if quote.Token != "" && req.Token != "" && quote.Token != req.Token {
+			p.Warnings = append(p.Warnings, fmt.Sprintf("token %s does not match the token %s of %s", req.Token, quote.Token, p.Instrument))
+		}
+	}
+
+	price := p.LastPrice
+	if !falcon.IsMarketPrice(req.PriceType) {
+		limit, err := falcon.ParseRupees(req.Price)
+		if err != nil {
+			return fmt.Errorf("price: %w", err)
+		}
+		price = limit
+		if p.LastPrice > 0 {
+			deviation := float64(limit-p.LastPrice) / float64(p.LastPrice) * 100
+			if deviation > priceDeviationWarning || deviation < -priceDeviationWarning {
+				p.Warnings = append(p.Warnings, fmt.Sprintf("limit price %s is %.1f%% away from the last price %s",
+					rupees(limit), deviation, rupees(p.LastPrice)))
+			}
+		}
+	}
+	p.EstimatedValue = price.Mul(req.Quantity)
+	return nil
+}
+
+// checkMargin estimates the margin a buy blocks against the available funds.
+// Sells are assumed to close existing holdings or positions.
+func (p *Preview) checkMargin(ctx context.Context, svc falcon.FalconService, transactionType int) {
+	if transactionType == falcon.TransactionBuy {
+		p.RequiredMargin = p.EstimatedValue
+	}
+	p.compareMargin(ctx, svc)
+}
+
+// compareMargin warns when RequiredMargin exceeds the available margin.
+func (p *Preview) compareMargin(ctx context.Context, svc falcon.FalconService) {
+	margin, err := svc.GetUserMargin(ctx)
+	if err != nil {
+		p.Warnings = append(p.Warnings, fmt.Sprintf("could not fetch the available margin: %v", err))
+		return
+	}
+	p.AvailableMargin = margin.Available()
+	if p.RequiredMargin > p.AvailableMargin {
+		p.Warnings = append(p.Warnings, fmt.Sprintf("estimated margin %s exceeds the available margin %s",
+			rupees(p.RequiredMargin), rupees(p.AvailableMargin)))
+	}
+}
+
+// noteRounding warns about prices validation rounded to the tick size.
+func (p *Preview) noteRounding(req, order falcon.OrderReq) {
+	for _, f := range []struct{ name, from, to string }{
+		{"price", req.Price, order.Price},
+		{"trigger price", req.TriggerPrice, order.TriggerPrice},
+	} {
+		from, _ := falcon.ParseRupees(f.from)
+		to, _ := falcon.ParseRupees(f.to)
+		if from != to {
+			p.Warnings = append(p.Warnings, fmt.Sprintf("%s %s is rounded to %s, the tick size of the instrument",
+				f.name, rupees(from), rupees(to)))
+		}
+	}
+}
+
+func (p *Preview) issue(ctx context.Context, req any) error {
+	token, expiresAt, err := confirmations.issue(ctx, p.Action, req)
+	if err != nil {
+		return err
+	}
+	p.ConfirmationToken = token
+	p.ExpiresAt = expiresAt
+	return nil
+}
+
+func findOrder(ctx context.Context, svc falcon.FalconService, orderID string) (*falcon.OrderBookEntry, error) {
+	book, err := svc.GetOrderBook(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range book {
+		if book[i].OrderID == orderID {
+			return &book[i], nil
+		}
+	}
+	return nil, fmt.Errorf("order %s not found in the order book", orderID)
+}
+
+// orderChanges lists the fields a modification changes.
+func orderChanges(existing falcon.OrderBookEntry, req falcon.OrderReq) []string {
+	var changes []string
+	if req.Quantity != existing.Quantity {
+		changes = append(changes, fmt.Sprintf("quantity %d -> %d", existing.Quantity, req.Quantity))
+	}
+	if req.PriceType != existing.PriceType {
+		changes = append(changes, fmt.Sprintf("price type %s -> %s",
+			falcon.PriceTypeName(existing.PriceType), falcon.PriceTypeName(req.PriceType)))
+	}
+	if price, err := falcon.ParseRupees(req.Price); err == nil && price != existing.Price {
+		changes = append(changes, fmt.Sprintf("price %s -> %s", rupees(existing.Price), rupees(price)))
+	}
+	if trigger, err := falcon.ParseRupees(req.TriggerPrice); err == nil && trigger != existing.TriggerPrice {
+		changes = append(changes, fmt.Sprintf("trigger price %s -> %s", rupees(existing.TriggerPrice), rupees(trigger)))
+	}
+	return changes
+}
+
+// describe renders an order the way a trader would read it out, e.g.
+// "BUY 10 RELIANCE-EQ on NSE at LIMIT ₹2450.00".
+func describe(req falcon.OrderReq) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %d %s on %s at %s", falcon.TransactionName(req.TransactionType), req.Quantity,
+		req.TradingSymbol, strings.ToUpper(falcon.ExchangeName(req.ExchangeName)), falcon.PriceTypeName(req.PriceType))
+	if !falcon.IsMarketPrice(req.PriceType) && req.Price != "" {
+		fmt.Fprintf(&b, " ₹%s", req.Price)
+	}
+	if req.TriggerPrice != "" {
+		fmt.Fprintf(&b, ", trigger ₹%s", req.TriggerPrice)
+	}
+	if req.IsAMO {
+		b.WriteString(", after market order")
+	}
+	return b.String()
+}
+
+func rupees(m falcon.Money) string {
+	return "₹" + m.String()
+}
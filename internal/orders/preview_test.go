@@ -0,0 +1,173 @@
+package orders
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+)
+
+// stubService answers the read calls a preview makes and records the basket
+// legs it places and cancels.
+type stubService struct {
+	falcon.FalconService
+	quotes    falcon.Quotes
+	margin    *falcon.Margin
+	book      falcon.OrderBook
+	placed    []falcon.PlaceOrderResponse
+	cancelled []string
+	cancelErr error
+}
+
+func (s *stubService) PlaceOrder(ctx context.Context, req []falcon.OrderReq) ([]falcon.PlaceOrderResponse, error) {
+	return s.placed, nil
+}
+
+func (s *stubService) CancelOrder(ctx context.Context, req falcon.CancelOrderReq) (any, error) {
+	if s.cancelErr != nil {
+		return nil, s.cancelErr
+	}
+	s.cancelled = append(s.cancelled, req.OrderID)
+	return nil, nil
+}
+
+func (s *stubService) ValidateOrder(ctx context.Context, req *falcon.OrderReq) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	if req.Price == "2300.03" {
+		// Rounded to a tick of 5 paisa
+		req.Price = "2300.00"
+	}
+	return nil
+}
+
+func (s *stubService) GetPrice(ctx context.Context, req *falcon.PriceReq) (falcon.Quotes, error) {
+	return s.quotes, nil
+}
+
+func (s *stubService) GetUserMargin(ctx context.Context) (*falcon.Margin, error) {
+	return s.margin, nil
+}
+
+func (s *stubService) GetOrderBook(ctx context.Context) (falcon.OrderBook, error) {
+	return s.book, nil
+}
+
+func newStubService() *stubService {
+	return &stubService{
+		quotes: falcon.Quotes{"nse:RELIANCE-EQ": {Token: "2885", LastPrice: falcon.Paisa(250000)}},
+		margin: &falcon.Margin{Cash: falcon.Paisa(3000000)},
+		book: falcon.OrderBook{
+			{OrderID: "42", TradingSymbol: "RELIANCE-EQ", ExchangeName: falcon.ExchangeNSE, TransactionType: falcon.TransactionBuy,
+				PriceType: falcon.PriceTypeLimit, Quantity: 10, FilledShares: 4, Price: falcon.Paisa(245000)},
+		},
+	}
+}
+
+func TestPreviewPlace(t *testing.T) {
+	order := falcon.OrderReq{
+		ExchangeName:    falcon.ExchangeNSE,
+		Token:           "2885",
+		TradingSymbol:   "RELIANCE-EQ",
+		Quantity:        10,
+		Price:           "2300",
+		TransactionType: falcon.TransactionBuy,
+		PriceType:       falcon.PriceTypeLimit,
+		Validity:        falcon.ValidityDay,
+	}
+
+	tests := []struct {
+		name         string
+		order        func(o falcon.OrderReq) falcon.OrderReq
+		wantValue    falcon.Money
+		wantWarnings []string
+		wantErr      string
+	}{
+		{
+			name:         "limit buy",
+			order:        func(o falcon.OrderReq) falcon.OrderReq { return o },
+			wantValue:    falcon.Paisa(2300000),
+			wantWarnings: []string{"limit price ₹2300.00 is -8.0% away from the last price ₹2500.00"},
+		},
+		{
+			name: "rounded to tick",
+			order: func(o falcon.OrderReq) falcon.OrderReq {
+				o.Price = "2300.03"
+				return o
+			},
+			wantValue: falcon.Paisa(2300000),
+			wantWarnings: []string{
+				"price ₹2300.03 is rounded to ₹2300.00, the tick size of the instrument",
+				"limit price ₹2300.00 is -8.0% away from the last price ₹2500.00",
+			},
+		},
+		{
+			name: "market buy above margin",
+			order: func(o falcon.OrderReq) falcon.OrderReq {
+				o.PriceType, o.Price, o.Quantity = falcon.PriceTypeMarket, "", 20
+				return o
+			},
+			wantValue:    falcon.Paisa(5000000),
+			wantWarnings: []string{"estimated margin ₹50000.00 exceeds the available margin ₹30000.00"},
+		},
+		{
+			name: "unknown instrument",
+			order: func(o falcon.OrderReq) falcon.OrderReq {
+				o.TradingSymbol = "RELIANC-EQ"
+				return o
+			},
+			wantErr: "instrument nse:RELIANC-EQ not found",
+		},
+		{
+			name: "invalid order",
+			order: func(o falcon.OrderReq) falcon.OrderReq {
+				o.Quantity = 0
+				return o
+			},
+			wantErr: "quantity",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := PreviewPlace(context.Background(), newStubService(), tt.order(order))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantValue, p.EstimatedValue)
+			assert.Equal(t, tt.wantWarnings, p.Warnings)
+			assert.NotEmpty(t, p.ConfirmationToken)
+		})
+	}
+}
+
+func TestPreviewCancel(t *testing.T) {
+	p, err := PreviewCancel(context.Background(), newStubService(), falcon.CancelOrderReq{OrderID: "42"})
+	require.NoError(t, err)
+	assert.Equal(t, "Cancel order 42: BUY 10 RELIANCE-EQ LIMIT, 4 filled, 6 pending", p.Summary)
+
+	_, err = PreviewCancel(context.Background(), newStubService(), falcon.CancelOrderReq{OrderID: "7"})
+	assert.ErrorContains(t, err, "not found")
+}
+
+func TestConfirm(t *testing.T) {
+	ctx := context.Background()
+	order := falcon.CancelOrderReq{OrderID: "42"}
+	p, err := PreviewCancel(ctx, newStubService(), order)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, Confirm(ctx, ActionCancel, falcon.CancelOrderReq{OrderID: "43"}, p.ConfirmationToken), ErrInvalidConfirmation)
+	assert.ErrorIs(t, Confirm(ctx, ActionPlace, order, p.ConfirmationToken), ErrInvalidConfirmation)
+	require.NoError(t, Confirm(ctx, ActionCancel, order, p.ConfirmationToken))
+	// Tokens are single use
+	assert.ErrorIs(t, Confirm(ctx, ActionCancel, order, p.ConfirmationToken), ErrInvalidConfirmation)
+
+	assert.NoError(t, Confirm(ctx, ActionCancel, order, ""))
+	DryRun = true
+	defer func() { DryRun = false }()
+	assert.ErrorIs(t, Confirm(ctx, ActionCancel, order, ""), ErrConfirmationRequired)
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package resources exposes live quotes and the portfolio as MCP resources
+// that clients can read and subscribe to.
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+	"github.com/wealthy/wealthy-mcp/internal/notify"
+	"github.com/wealthy/wealthy-mcp/internal/utils"
+	"github.com/wealthy/wealthy-mcp/internal/websocket"
+)
+
+const (
+	HoldingsURI  = "wealthy://holdings"
+	PositionsURI = "wealthy://positions"
+	OrdersURI    = "wealthy://orders"
+
+	quotePrefix     = "wealthy://quote/"
+	watchlistPrefix = "wealthy://watchlist/"
+
+	// quoteWait is how long reading a quote waits for the first tick
+	quoteWait = 5 * time.Second
+	// quoteInterval is the shortest time between two updates of a quote
+	quoteInterval = time.Second
+)
+
+// QuoteURI is the resource of the live quote of an instrument.
+func QuoteURI(exchange int, token string) string {
+	return quotePrefix + falcon.ExchangeName(exchange) + "/" + token
+}
+
+// WatchlistURI is the resource of a watchlist.
+func WatchlistURI(name string) string {
+	return watchlistPrefix + name
+}
+
+// Add registers the resources with s and their subscriptions with hub.
+func Add(s *server.MCPServer, hub *notify.Hub) {
+	s.AddResource(mcp.NewResource(HoldingsURI, "Holdings",
+		mcp.WithResourceDescription("Your portfolio holdings, updated when an order fills"),
+		mcp.WithMIMEType("application/json"),
+	), readJSON(func(ctx context.Context, _ string) (any, error) {
+		return utils.FalconService.GetHoldings(ctx)
+	}))
+	s.AddResource(mcp.NewResource(PositionsURI, "Positions",
+		mcp.WithResourceDescription("Your open positions, updated when an order fills"),
+		mcp.WithMIMEType("application/json"),
+	), readJSON(func(ctx context.Context, _ string) (any, error) {
+		return utils.FalconService.GetPositions(ctx)
+	}))
+	s.AddResource(mcp.NewResource(OrdersURI, "Orders",
+		mcp.WithResourceDescription("The orders of the day, updated when an order fills, is cancelled or rejected"),
+		mcp.WithMIMEType("application/json"),
+	), readJSON(func(ctx context.Context, _ string) (any, error) {
+		return utils.FalconService.GetOrderBook(ctx)
+	}))
+	s.AddResourceTemplate(mcp.NewResourceTemplate(quotePrefix+"{exchange}/{token}", "Live quote",
+		mcp.WithTemplateDescription("The live quote of an instrument from the streaming feed, exchange is nse, nfo, bse or bfo and token comes from the search tool"),
+		mcp.WithTemplateMIMEType("application/json"),
+	), readJSONTemplate(readQuote))
+	s.AddResourceTemplate(mcp.NewResourceTemplate(watchlistPrefix+"{name}", "Watchlist",
+		mcp.WithTemplateDescription("The securities of one of your watchlists"),
+		mcp.WithTemplateMIMEType("application/json"),
+	), readJSONTemplate(func(ctx context.Context, uri string) (any, error) {
+		return utils.FalconService.GetWatchlist(ctx, strings.TrimPrefix(uri, watchlistPrefix))
+	}))
+
+	hub.WatchResources(OrdersURI, watchOrders(hub, websocket.Orders, utils.FollowOrders, anyOrder))
+	hub.WatchResources(HoldingsURI, watchOrders(hub, websocket.Orders, utils.FollowOrders, filled))
+	hub.WatchResources(PositionsURI, watchOrders(hub, websocket.Orders, utils.FollowOrders, filled))
+	hub.WatchResources(quotePrefix, watchQuote(hub, utils.Stream, utils.StartStream))
+	// Watchlists change through the watchlist tools, which notify the hub
+	hub.WatchResources(watchlistPrefix, func(ctx context.Context, uri string) (func(), error) {
+		return func() {}, nil
+	})
+}
+
+type reader func(ctx context.Context, uri string) (any, error)
+
+func readJSON(read reader) server.ResourceHandlerFunc {
+	return func(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		return contents(ctx, req.Params.URI, read)
+	}
+}
+
+func readJSONTemplate(read reader) server.ResourceTemplateHandlerFunc {
+	return func(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		return contents(ctx, req.Params.URI, read)
+	}
+}
+
+func contents(ctx context.Context, uri string, read reader) ([]mcp.ResourceContents, error) {
+	v, err := read(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize resource: %w", err)
+	}
+	return []mcp.ResourceContents{mcp.TextResourceContents{URI: uri, MIMEType: "application/json", Text: string(data)}}, nil
+}
+
+// parseQuoteURI splits a quote URI into the exchange and token. The exchange
+// is a name such as nse or its identifier.
+func parseQuoteURI(uri string) (websocket.Instrument, error) {
+	rest, ok := strings.CutPrefix(uri, quotePrefix)
+	exchange, token, found := strings.Cut(rest, "/")
+	if !ok || !found || token == "" || strings.Contains(token, "/") {
+		return websocket.Instrument{}, fmt.Errorf("invalid quote URI %s, expected %s{exchange}/{token}", uri, quotePrefix)
+	}
+	if id, err := strconv.Atoi(exchange); err == nil && falcon.ExchangeName(id) != "" {
+		return websocket.Instrument{Exchange: id, Token: token}, nil
+	}
+	for id := falcon.ExchangeNSE; id <= falcon.ExchangeBFO; id++ {
+		if strings.EqualFold(falcon.ExchangeName(id), exchange) {
+			return websocket.Instrument{Exchange: id, Token: token}, nil
+		}
+	}
+	return websocket.Instrument{}, fmt.Errorf("unknown exchange %q in %s", exchange, uri)
+}
+
+// readQuote returns the streamed tick of an instrument. An instrument nobody
+// subscribed to is streamed just long enough for its first tick.
+func readQuote(ctx context.Context, uri string) (any, error) {
+	instr, err := parseQuoteURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.StartStream(ctx); err != nil {
+		return nil, err
+	}
+	sub, err := utils.Stream.Subscribe(websocket.ModeFull, instr)
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Close()
+	ctx, cancel := context.WithTimeout(ctx, quoteWait)
+	defer cancel()
+	tick, err := utils.Stream.GetLTP(ctx, instr.Exchange, instr.Token)
+	if err != nil {
+		return nil, fmt.Errorf("%w, the market may be closed", err)
+	}
+	return tick, nil
+}
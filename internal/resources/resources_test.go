@@ -0,0 +1,145 @@
+package resources
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+	"github.com/wealthy/wealthy-mcp/internal/notify"
+	ws "github.com/wealthy/wealthy-mcp/internal/websocket"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestParseQuoteURI(t *testing.T) {
+	tests := []struct {
+		uri     string
+		want    ws.Instrument
+		wantErr string
+	}{
+		{uri: "wealthy://quote/nse/2885", want: ws.Instrument{Exchange: falcon.ExchangeNSE, Token: "2885"}},
+		{uri: "wealthy://quote/BSE/500325", want: ws.Instrument{Exchange: falcon.ExchangeBSE, Token: "500325"}},
+		{uri: "wealthy://quote/2/35001", want: ws.Instrument{Exchange: falcon.ExchangeNFO, Token: "35001"}},
+		{uri: "wealthy://quote/mcx/1", wantErr: "unknown exchange"},
+		{uri: "wealthy://quote/nse", wantErr: "invalid quote URI"},
+		{uri: "wealthy://quote/nse/1/2", wantErr: "invalid quote URI"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			got, err := parseQuoteURI(tt.uri)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+	assert.Equal(t, "wealthy://quote/nse/2885", QuoteURI(falcon.ExchangeNSE, "2885"))
+}
+
+// session is a client session that records its notifications.
+type session struct {
+	id            string
+	notifications chan mcp.JSONRPCNotification
+}
+
+func (s *session) SessionID() string                                   { return s.id }
+func (s *session) Initialize()                                         {}
+func (s *session) Initialized() bool                                   { return true }
+func (s *session) NotificationChannel() chan<- mcp.JSONRPCNotification { return s.notifications }
+
+func subscribed(t *testing.T, hub *notify.Hub, uri string) *session {
+	s := &session{id: uri, notifications: make(chan mcp.JSONRPCNotification, 8)}
+	hooks := &server.Hooks{}
+	hub.Attach(hooks)
+	hooks.RegisterSession(context.Background(), s)
+	require.NoError(t, hub.Subscribe(context.Background(), s.SessionID(), uri))
+	return s
+}
+
+func nextUpdate(t *testing.T, s *session) string {
+	t.Helper()
+	select {
+	case n := <-s.notifications:
+		return n.Params.AdditionalFields["uri"].(string)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no resource update")
+		return ""
+	}
+}
+
+func TestWatchOrders(t *testing.T) {
+	hub := notify.NewHub()
+	tracker := ws.NewOrderTracker()
+	followed := false
+	hub.WatchResources(OrdersURI, watchOrders(hub, tracker, func(context.Context) { followed = true }, anyOrder))
+	hub.WatchResources(PositionsURI, watchOrders(hub, tracker, func(context.Context) {}, filled))
+	orders := subscribed(t, hub, OrdersURI)
+	positions := subscribed(t, hub, PositionsURI)
+	assert.True(t, followed, "subscribing follows the order feed")
+
+	tracker.Apply(&ws.OrderUpdate{OrderId: "1", Quantity: 10}, time.Now())
+	assert.Equal(t, OrdersURI, nextUpdate(t, orders))
+	tracker.Apply(&ws.OrderUpdate{OrderId: "1", Quantity: 10, FilledShares: 10}, time.Now())
+	assert.Equal(t, OrdersURI, nextUpdate(t, orders))
+	assert.Equal(t, PositionsURI, nextUpdate(t, positions), "only fills change the positions")
+	assert.Empty(t, positions.notifications)
+}
+
+func TestWatchQuote(t *testing.T) {
+	feed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer c.Close()
+		var req ws.PriceSubscriptionReq
+		require.NoError(t, c.ReadJSON(&req))
+		data, err := proto.Marshal(&ws.Message{Data: &ws.Message_Feed{Feed: &ws.Feed{Exchange: 1, Token: 2885, Ltpc: &ws.LTPC{Ltp: 245050}}}})
+		require.NoError(t, err)
+		require.NoError(t, c.WriteMessage(websocket.BinaryMessage, data))
+		c.ReadMessage()
+	}))
+	defer feed.Close()
+	stream := ws.NewStreamManager(urlSource("ws"+strings.TrimPrefix(feed.URL, "http")), ws.WithPriceStore(ws.NewPriceStore()))
+	defer stream.Stop()
+
+	hub := notify.NewHub()
+	hub.WatchResources(quotePrefix, watchQuote(hub, stream, func(context.Context) error {
+		stream.Start()
+		return nil
+	}))
+	uri := QuoteURI(falcon.ExchangeNSE, "2885")
+	s := subscribed(t, hub, uri)
+	assert.Equal(t, uri, nextUpdate(t, s))
+	assert.Equal(t, map[int][]ws.Instrument{ws.ModeFull: {{Exchange: falcon.ExchangeNSE, Token: "2885"}}}, stream.Subscriptions())
+
+	hub.Unsubscribe(s.SessionID(), uri)
+	assert.Empty(t, stream.Subscriptions(), "the last unsubscribe releases the instrument")
+}
+
+func TestWatchQuoteRefusedForSession(t *testing.T) {
+	stream := ws.NewStreamManager(urlSource("ws://127.0.0.1:1"))
+	defer stream.Stop()
+	refused := errors.New("not for this session")
+
+	hub := notify.NewHub()
+	hub.WatchResources(quotePrefix, watchQuote(hub, stream, func(context.Context) error { return refused }))
+	err := hub.Subscribe(context.Background(), "s1", QuoteURI(falcon.ExchangeNSE, "2885"))
+	assert.ErrorIs(t, err, refused)
+	assert.Empty(t, stream.Subscriptions(), "a refused session subscribes nothing")
+}
+
+type urlSource string
+
+func (u urlSource) GetWebsocketURL(ctx context.Context) (string, error) {
+	return string(u), nil
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package resources
+
+import (
+	"context"
+	"time"
+
+	"github.com/wealthy/wealthy-mcp/internal/notify"
+	"github.com/wealthy/wealthy-mcp/internal/websocket"
+)
+
+func anyOrder(websocket.OrderEvent) bool {
+	return true
+}
+
+// filled reports whether an order event changes the holdings and positions.
+func filled(e websocket.OrderEvent) bool {
+	return e.Order.State == websocket.OrderFilled || e.Order.State == websocket.OrderPartiallyFilled
+}
+
+// watchOrders updates a resource on the order events that match. follow
+// starts the feed carrying the order updates.
+func watchOrders(hub *notify.Hub, tracker *websocket.OrderTracker, follow func(context.Context), match func(websocket.OrderEvent) bool) notify.Watcher {
+	return func(ctx context.Context, uri string) (func(), error) {
+		follow(ctx)
+		events, cancel := tracker.Watch()
+		go func() {
+			for e := range events {
+				if match(e) {
+					hub.ResourceUpdated(uri)
+				}
+			}
+		}()
+		return cancel, nil
+	}
+}
+
+// watchQuote streams the instrument of a quote resource and updates the
+// resource on its ticks, at most once per quoteInterval. start starts the
+// stream, or refuses to for the subscribing session.
+func watchQuote(hub *notify.Hub, stream *websocket.StreamManager, start func(context.Context) error) notify.Watcher {
+	return func(ctx context.Context, uri string) (func(), error) {
+		instr, err := parseQuoteURI(uri)
+		if err != nil {
+			return nil, err
+		}
+		if err := start(ctx); err != nil {
+			return nil, err
+		}
+		sub, err := stream.Subscribe(websocket.ModeFull, instr)
+		if err != nil {
+			return nil, err
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			var last time.Time
+			for {
+				tick, err := stream.Prices().Next(ctx, instr.Exchange, instr.Token, last)
+				if err != nil {
+					return
+				}
+				last = tick.UpdatedAt
+				hub.ResourceUpdated(uri)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(quoteInterval):
+				}
+			}
+		}()
+		return func() {
+			cancel()
+			sub.Close()
+		}, nil
+	}
+}
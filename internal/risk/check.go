@@ -0,0 +1,425 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package risk
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+)
+
+// Violation is a limit an order breaches.
+type Violation struct {
+	Limit   string `json:"limit"`
+	Message string `json:"message"`
+}
+
+// Error rejects an order that breaches one or more limits.
+type Error struct {
+	Violations []Violation `json:"violations"`
+}
+
+func (e *Error) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.Limit + ": " + v.Message
+	}
+	return "order rejected by risk limits: " + strings.Join(msgs, "; ")
+}
+
+func (e *Error) add(limit, format string, args ...any) {
+	e.Violations = append(e.Violations, Violation{Limit: limit, Message: fmt.Sprintf(format, args...)})
+}
+
+func (e *Error) err() error {
+	if len(e.Violations) == 0 {
+		return nil
+	}
+	return e
+}
+
+// CheckPlace checks a new order against the kill switch and the current
+// limits.
+func CheckPlace(ctx context.Context, svc falcon.FalconService, req falcon.OrderReq) error {
+	if err := Switch.Check(); err != nil {
+		return err
+	}
+	return Current.check(ctx, svc, req, "")
+}
+
+// CheckModify checks an order modification against the kill switch and the
+// current limits, the modified order replaces the open one in the daily totals.
+func CheckModify(ctx context.Context, svc falcon.FalconService, req falcon.ModifyOrderReq) error {
+	if err := Switch.Check(); err != nil {
+		return err
+	}
+	return Current.check(ctx, svc, req.OrderReq, req.OrderID)
+}
+
+// CheckBasket checks the legs of a basket order against the kill switch and
+// the current limits. Every leg counts as an open order for the legs after it.
+func CheckBasket(ctx context.Context, svc falcon.FalconService, legs []falcon.OrderReq) error {
+	if err := Switch.Check(); err != nil {
+		return err
+	}
+	l := Current
+	if !l.Enabled() {
+		return nil
+	}
+	snap, err := l.snapshot(ctx, svc)
+	if err != nil {
+		return err
+	}
+	var rerr Error
+	for i, leg := range legs {
+		violations, price, err := l.evaluate(ctx, svc, snap, leg, "")
+		if err != nil {
+			return fmt.Errorf("leg %d: %w", i+1, err)
+		}
+		for _, v := range violations {
+			rerr.add(v.Limit, "leg %d: %s", i+1, v.Message)
+		}
+		snap.book = append(snap.book, falcon.OrderBookEntry{ExchangeName: leg.ExchangeName, TradingSymbol: leg.TradingSymbol,
+			TransactionType: leg.TransactionType, Quantity: leg.Quantity, Price: price})
+	}
+	return rerr.err()
+}
+
+// check returns an *Error listing the limits req breaches. replaces is the ID
+// of the order req modifies, if any.
+func (l Limits) check(ctx context.Context, svc falcon.FalconService, req falcon.OrderReq, replaces string) error {
+	if !l.Enabled() {
+		return nil
+	}
+	snap, err := l.snapshot(ctx, svc)
+	if err != nil {
+		return err
+	}
+	violations, _, err := l.evaluate(ctx, svc, snap, req, replaces)
+	if err != nil {
+		return err
+	}
+	return (&Error{Violations: violations}).err()
+}
+
+// snapshot is the state of the account the limits are measured against.
+type snapshot struct {
+	book      falcon.OrderBook
+	positions falcon.Positions
+	// quotes prices the open orders of book that have no price of their own
+	quotes falcon.Quotes
+}
+
+// snapshot fetches the parts of the account state the limits need.
+func (l Limits) snapshot(ctx context.Context, svc falcon.FalconService) (*snapshot, error) {
+	snap := &snapshot{}
+	if l.MaxDailyTurnover > 0 || l.MaxOpenOrders > 0 || l.MaxQuantityPerSymbol > 0 {
+		var err error
+		if snap.book, err = svc.GetOrderBook(ctx); err != nil {
+			return nil, fmt.Errorf("failed to check risk limits: %w", err)
+		}
+	}
+	if l.MaxDailyTurnover > 0 {
+		var err error
+		if snap.quotes, err = OpenOrderQuotes(ctx, svc, snap.book); err != nil {
+			return nil, fmt.Errorf("failed to check risk limits: %w", err)
+		}
+	}
+	if l.MaxQuantityPerSymbol > 0 || l.MaxLossPerDay > 0 {
+		var err error
+		if snap.positions, err = svc.GetPositions(ctx); err != nil {
+			return nil, fmt.Errorf("failed to check risk limits: %w", err)
+		}
+	}
+	return snap, nil
+}
+
+// evaluate lists the limits req breaches and returns the price it was valued
+// at, zero when no limit needs its value.
+func (l Limits) evaluate(ctx context.Context, svc falcon.FalconService, snap *snapshot, req falcon.OrderReq, replaces string) ([]Violation, falcon.Money, error) {
+	var rerr Error
+
+	exchange := strings.ToUpper(falcon.ExchangeName(req.ExchangeName))
+	if len(l.AllowedExchanges) > 0 && !slices.Contains(l.AllowedExchanges, exchange) {
+		rerr.add("allowed_exchanges", "exchange %s is not allowed, use %s", nameOr(exchange, "exchange_name", req.ExchangeName),
+			strings.Join(l.AllowedExchanges, ", "))
+	}
+	product := falcon.ProductName(req.OrderType)
+	if len(l.AllowedProductTypes) > 0 && !slices.Contains(l.AllowedProductTypes, product) {
+		rerr.add("allowed_product_types", "product %s is not allowed, use %s", nameOr(product, "order_type", req.OrderType),
+			strings.Join(l.AllowedProductTypes, ", "))
+	}
+	if l.blocked(req.TradingSymbol) {
+		rerr.add("blocked_symbols", "%s is blocked from trading", req.TradingSymbol)
+	}
+
+	var price falcon.Money
+	if l.MaxOrderValue > 0 || l.MaxDailyTurnover > 0 {
+		var err error
+		if price, err = orderPrice(ctx, svc, req); err != nil {
+			return nil, 0, fmt.Errorf("failed to check risk limits: %w", err)
+		}
+		value := price.Mul(req.Quantity)
+		if limit := falcon.Rupees(l.MaxOrderValue); l.MaxOrderValue > 0 && value > limit {
+			rerr.add("max_order_value", "order value %s exceeds the limit of %s", rupees(value), rupees(limit))
+		}
+		if limit := falcon.Rupees(l.MaxDailyTurnover); l.MaxDailyTurnover > 0 {
+			quantity := req.Quantity
+			if existing := findOrder(snap.book, replaces); existing != nil {
+				// The filled part of a modified order is already in the turnover
+				quantity -= existing.FilledShares
+			}
+			turnover := Turnover(snap.book, replaces, snap.quotes)
+			if after := turnover + price.Mul(quantity); after > limit {
+				rerr.add("max_daily_turnover", "the order takes the turnover of the day from %s to %s, above the limit of %s",
+					rupees(turnover), rupees(after), rupees(limit))
+			}
+		}
+	}
+
+	if l.MaxOpenOrders > 0 && replaces == "" {
+		if open := OpenOrders(snap.book); open >= l.MaxOpenOrders {
+			rerr.add("max_open_orders", "%d orders are already open, the limit is %d", open, l.MaxOpenOrders)
+		}
+	}
+
+	if l.MaxQuantityPerSymbol > 0 {
+		held := netQuantity(snap.positions, req) + pendingQuantity(snap.book, req, replaces)
+		after := held + signed(req)
+		if abs(after) > l.MaxQuantityPerSymbol && abs(after) > abs(held) {
+			rerr.add("max_quantity_per_symbol", "the order takes the net quantity of %s from %d to %d, above the limit of %d",
+				req.TradingSymbol, held, after, l.MaxQuantityPerSymbol)
+		}
+	}
+
+	if l.MaxLossPerDay > 0 {
+		pnl := DayPnL(snap.positions)
+		if limit := falcon.Rupees(l.MaxLossPerDay); pnl <= -limit && !reduces(netQuantity(snap.positions, req), req) {
+			rerr.add("max_loss_per_day", "the loss of the day %s has reached the limit of %s, only orders that reduce a position are allowed",
+				rupees(-pnl), rupees(limit))
+		}
+	}
+	return rerr.Violations, price, nil
+}
+
+// Status is the limits in force together with the usage of the day they are
+// measured against.
+type Status struct {
+	Enabled    bool         `json:"enabled"`
+	Limits     Limits       `json:"limits"`
+	KillSwitch KillState    `json:"kill_switch"`
+	OpenOrders int          `json:"open_orders"`
+	Turnover   falcon.Money `json:"turnover"`
+	DayPnL     falcon.Money `json:"day_pnl"`
+	Warnings   []string     `json:"warnings,omitempty"`
+}
+
+// CurrentStatus reports the current limits and usage. Usage that cannot be
+// fetched is left at zero with a warning.
+func CurrentStatus(ctx context.Context, svc falcon.FalconService) *Status {
+	st := &Status{Enabled: Current.Enabled(), Limits: Current, KillSwitch: Switch.State()}
+	if book, err := svc.GetOrderBook(ctx); err != nil {
+		st.Warnings = append(st.Warnings, fmt.Sprintf("could not fetch the order book: %v", err))
+	} else {
+		st.OpenOrders = OpenOrders(book)
+		quotes, err := OpenOrderQuotes(ctx, svc, book)
+		if err != nil {
+			st.Warnings = append(st.Warnings, fmt.Sprintf("could not price the open market orders, they are left out of the turnover: %v", err))
+		}
+		st.Turnover = Turnover(book, "", quotes)
+	}
+	if positions, err := svc.GetPositions(ctx); err != nil {
+		st.Warnings = append(st.Warnings, fmt.Sprintf("could not fetch the positions: %v", err))
+	} else {
+		st.DayPnL = DayPnL(positions)
+	}
+	return st
+}
+
+// blocked reports whether a trading symbol is blocked, RELIANCE blocks
+// RELIANCE-EQ as well.
+func (l Limits) blocked(symbol string) bool {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	base, _, _ := strings.Cut(symbol, "-")
+	return slices.Contains(l.BlockedSymbols, symbol) || slices.Contains(l.BlockedSymbols, base)
+}
+
+// orderPrice is the price an order is valued at: its limit price, the trigger
+// price of a stop loss market order, or else the last traded price.
+func orderPrice(ctx context.Context, svc falcon.FalconService, req falcon.OrderReq) (falcon.Money, error) {
+	if price, err := falcon.ParseRupees(req.Price); err == nil && price > 0 && !falcon.IsMarketPrice(req.PriceType) {
+		return price, nil
+	}
+	if trigger, err := falcon.ParseRupees(req.TriggerPrice); err == nil && trigger > 0 {
+		return trigger, nil
+	}
+	symbol := falcon.QuoteSymbol(req.ExchangeName, req.TradingSymbol)
+	quotes, err := svc.GetPrice(ctx, &falcon.PriceReq{Symbols: []string{symbol}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to price %s: %w", symbol, err)
+	}
+	quote, ok := quotes.Lookup(symbol)
+	if !ok || quote.LastPrice <= 0 {
+		return 0, fmt.Errorf("no last price for %s to value the order at", symbol)
+	}
+	return quote.LastPrice, nil
+}
+
+// Turnover is the value of the orders of the day, filled quantities at their
+// average price and open quantities at openOrderPrice. The open part of the
+// order with ID except is left out.
+func Turnover(book falcon.OrderBook, except string, quotes falcon.Quotes) falcon.Money {
+	var total falcon.Money
+	for _, o := range book {
+		total += o.AveragePrice.Mul(o.FilledShares)
+		if o.IsOpen() && (except == "" || o.OrderID != except) {
+			total += openOrderPrice(o, quotes).Mul(o.PendingQuantity())
+		}
+	}
+	return total
+}
+
+// openOrderPrice is the price an open order is valued at: its limit price,
+// the trigger price of a stop loss market order, or else the last traded
+// price in quotes.
+func openOrderPrice(o falcon.OrderBookEntry, quotes falcon.Quotes) falcon.Money {
+	if price, ok := ownPrice(o); ok {
+		return price
+	}
+	quote, _ := quotes.Lookup(falcon.QuoteSymbol(o.ExchangeName, o.TradingSymbol))
+	return quote.LastPrice
+}
+
+// ownPrice is the limit or trigger price of an order, false if it has
+// neither.
+func ownPrice(o falcon.OrderBookEntry) (falcon.Money, bool) {
+	if o.Price > 0 && !falcon.IsMarketPrice(o.PriceType) {
+		return o.Price, true
+	}
+	if o.TriggerPrice > 0 {
+		return o.TriggerPrice, true
+	}
+	return 0, false
+}
+
+// OpenOrderQuotes fetches the last price of the symbols of open orders that
+// have neither a limit nor a trigger price, such as market orders, for
+// Turnover. It returns nil when there are none.
+func OpenOrderQuotes(ctx context.Context, svc falcon.FalconService, book falcon.OrderBook) (falcon.Quotes, error) {
+	var symbols []string
+	for _, o := range book {
+		if _, ok := ownPrice(o); ok || !o.IsOpen() {
+			continue
+		}
+		if symbol := falcon.QuoteSymbol(o.ExchangeName, o.TradingSymbol); !slices.Contains(symbols, symbol) {
+			symbols = append(symbols, symbol)
+		}
+	}
+	if len(symbols) == 0 {
+		return nil, nil
+	}
+	quotes, err := svc.GetPrice(ctx, &falcon.PriceReq{Symbols: symbols})
+	if err != nil {
+		return nil, fmt.Errorf("failed to price the open orders: %w", err)
+	}
+	return quotes, nil
+}
+
+// OpenOrders counts the orders waiting at the exchange.
+func OpenOrders(book falcon.OrderBook) int {
+	var n int
+	for _, o := range book {
+		if o.IsOpen() {
+			n++
+		}
+	}
+	return n
+}
+
+// DayPnL is the profit or loss of the day's positions.
+func DayPnL(positions falcon.Positions) falcon.Money {
+	var total falcon.Money
+	for _, p := range positions {
+		total += p.PnL()
+	}
+	return total
+}
+
+func findOrder(book falcon.OrderBook, orderID string) *falcon.OrderBookEntry {
+	if orderID == "" {
+		return nil
+	}
+	for i := range book {
+		if book[i].OrderID == orderID {
+			return &book[i]
+		}
+	}
+	return nil
+}
+
+// netQuantity is the net position in the symbol of req across products, long
+// positive and short negative.
+func netQuantity(positions falcon.Positions, req falcon.OrderReq) int {
+	var net int
+	for _, p := range positions {
+		if p.ExchangeName == req.ExchangeName && strings.EqualFold(p.TradingSymbol, req.TradingSymbol) {
+			net += p.NetQuantity
+		}
+	}
+	return net
+}
+
+// pendingQuantity is the signed open quantity in the symbol of req, leaving
+// out the order with ID except.
+func pendingQuantity(book falcon.OrderBook, req falcon.OrderReq, except string) int {
+	var pending int
+	for _, o := range book {
+		if !o.IsOpen() || (except != "" && o.OrderID == except) || o.ExchangeName != req.ExchangeName || !strings.EqualFold(o.TradingSymbol, req.TradingSymbol) {
+			continue
+		}
+		if o.TransactionType == falcon.TransactionSell {
+			pending -= o.PendingQuantity()
+		} else {
+			pending += o.PendingQuantity()
+		}
+	}
+	return pending
+}
+
+// reduces reports whether req closes part or all of a position of net
+// quantity without reversing it.
+func reduces(net int, req falcon.OrderReq) bool {
+	qty := signed(req)
+	return net != 0 && (net > 0) != (qty > 0) && abs(qty) <= abs(net)
+}
+
+func signed(req falcon.OrderReq) int {
+	if req.TransactionType == falcon.TransactionSell {
+		return -req.Quantity
+	}
+	return req.Quantity
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func nameOr(name, field string, value int) string {
+	if name != "" {
+		return name
+	}
+	return fmt.Sprintf("%s %d", field, value)
+}
+
+func rupees(m falcon.Money) string {
+	return "₹" + m.String()
+}
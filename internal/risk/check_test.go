@@ -0,0 +1,282 @@
+package risk
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+)
+
+// stubService answers the read calls the risk checks make and records the
+// orders the kill switch cancels and places.
+type stubService struct {
+	falcon.FalconService
+	quotes    falcon.Quotes
+	book      falcon.OrderBook
+	positions falcon.Positions
+	cancelled []string
+	placed    []falcon.OrderReq
+}
+
+func (s *stubService) CancelOrder(ctx context.Context, req falcon.CancelOrderReq) (any, error) {
+	s.cancelled = append(s.cancelled, req.OrderID)
+	return nil, nil
+}
+
+func (s *stubService) PlaceOrder(ctx context.Context, req []falcon.OrderReq) ([]falcon.PlaceOrderResponse, error) {
+	s.placed = append(s.placed, req...)
+	return nil, nil
+}
+
+func (s *stubService) GetPrice(ctx context.Context, req *falcon.PriceReq) (falcon.Quotes, error) {
+	return s.quotes, nil
+}
+
+func (s *stubService) GetOrderBook(ctx context.Context) (falcon.OrderBook, error) {
+	return s.book, nil
+}
+
+func (s *stubService) GetPositions(ctx context.Context) (falcon.Positions, error) {
+	return s.positions, nil
+}
+
+func newStubService() *stubService {
+	return &stubService{
+		quotes: falcon.Quotes{"nse:RELIANCE-EQ": {LastPrice: falcon.Rupees(2500)}},
+		book: falcon.OrderBook{
+			// Filled, 10 x 2400
+			{OrderID: "1", TradingSymbol: "RELIANCE-EQ", ExchangeName: falcon.ExchangeNSE, TransactionType: falcon.TransactionBuy,
+				Quantity: 10, FilledShares: 10, AveragePrice: falcon.Rupees(2400)},
+			// Open, 5 x 2300
+			{OrderID: "2", TradingSymbol: "RELIANCE-EQ", ExchangeName: falcon.ExchangeNSE, TransactionType: falcon.TransactionBuy,
+				Quantity: 5, Price: falcon.Rupees(2300)},
+			// Rejected
+			{OrderID: "3", TradingSymbol: "INFY-EQ", ExchangeName: falcon.ExchangeNSE, TransactionType: falcon.TransactionBuy,
+				Quantity: 5, Price: falcon.Rupees(1500), RejectReason: "insufficient funds"},
+		},
+		positions: falcon.Positions{
+			{TradingSymbol: "RELIANCE-EQ", ExchangeName: falcon.ExchangeNSE, NetQuantity: 10, UnrealisedPnL: falcon.Rupees(-1200)},
+		},
+	}
+}
+
+func TestCheck(t *testing.T) {
+	order := falcon.OrderReq{
+		ExchangeName:    falcon.ExchangeNSE,
+		TradingSymbol:   "RELIANCE-EQ",
+		Quantity:        10,
+		Price:           "2450",
+		OrderType:       falcon.ProductCNC,
+		TransactionType: falcon.TransactionBuy,
+		PriceType:       falcon.PriceTypeLimit,
+		Validity:        falcon.ValidityDay,
+	}
+
+	tests := []struct {
+		name     string
+		limits   Limits
+		order    func(o *falcon.OrderReq)
+		replaces string
+		want     []string
+	}{
+		{
+			name:   "no limits",
+			limits: Limits{},
+			order:  func(o *falcon.OrderReq) {},
+		},
+		{
+			name:   "within limits",
+			limits: Limits{MaxOrderValue: 25000, MaxDailyTurnover: 60000, MaxOpenOrders: 2, MaxQuantityPerSymbol: 30, MaxLossPerDay: 2000},
+			order:  func(o *falcon.OrderReq) {},
+		},
+		{
+			name:   "order value",
+			limits: Limits{MaxOrderValue: 20000},
+			order:  func(o *falcon.OrderReq) {},
+			want:   []string{"max_order_value: order value ₹24500.00 exceeds the limit of ₹20000.00"},
+		},
+		{
+			name:   "market order valued at last price",
+			limits: Limits{MaxOrderValue: 24000},
+			order: func(o *falcon.OrderReq) {
+				o.PriceType, o.Price = falcon.PriceTypeMarket, ""
+			},
+			want: []string{"max_order_value: order value ₹25000.00 exceeds the limit of ₹24000.00"},
+		},
+		{
+			name:   "daily turnover",
+			limits: Limits{MaxDailyTurnover: 50000},
+			order:  func(o *falcon.OrderReq) {},
+			want:   []string{"max_daily_turnover: the order takes the turnover of the day from ₹35500.00 to ₹60000.00, above the limit of ₹50000.00"},
+		},
+		{
+			name:     "modify replaces the open order in the turnover",
+			limits:   Limits{MaxDailyTurnover: 50000},
+			order:    func(o *falcon.OrderReq) { o.Quantity = 5 },
+			replaces: "2",
+		},
+		{
+			name:   "open orders",
+			limits: Limits{MaxOpenOrders: 1},
+			order:  func(o *falcon.OrderReq) {},
+			want:   []string{"max_open_orders: 1 orders are already open, the limit is 1"},
+		},
+		{
+			name:     "open orders do not limit modifications",
+			limits:   Limits{MaxOpenOrders: 1},
+			order:    func(o *falcon.OrderReq) {},
+			replaces: "2",
+		},
+		{
+			name:   "quantity per symbol",
+			limits: Limits{MaxQuantityPerSymbol: 20},
+			order:  func(o *falcon.OrderReq) {},
+			want:   []string{"max_quantity_per_symbol: the order takes the net quantity of RELIANCE-EQ from 15 to 25, above the limit of 20"},
+		},
+		{
+			name:   "reducing the quantity is allowed",
+			limits: Limits{MaxQuantityPerSymbol: 10},
+			order:  func(o *falcon.OrderReq) { o.TransactionType, o.Quantity = falcon.TransactionSell, 2 },
+		},
+		{
+			name:   "loss of the day",
+			limits: Limits{MaxLossPerDay: 1000},
+			order:  func(o *falcon.OrderReq) {},
+			want:   []string{"max_loss_per_day: the loss of the day ₹1200.00 has reached the limit of ₹1000.00, only orders that reduce a position are allowed"},
+		},
+		{
+			name:   "closing a position after the loss limit",
+			limits: Limits{MaxLossPerDay: 1000},
+			order:  func(o *falcon.OrderReq) { o.TransactionType = falcon.TransactionSell },
+		},
+		{
+			name:   "the product is order_type, not the price type",
+			limits: Limits{AllowedProductTypes: []string{"CNC"}},
+			order:  func(o *falcon.OrderReq) { o.PriceType, o.Price = falcon.PriceTypeMarket, "" },
+		},
+		{
+			name:   "exchange, product and symbol",
+			limits: Limits{AllowedExchanges: []string{"BSE"}, AllowedProductTypes: []string{"MIS"}, BlockedSymbols: []string{"RELIANCE"}},
+			order:  func(o *falcon.OrderReq) {},
+			want: []string{
+				"allowed_exchanges: exchange NSE is not allowed, use BSE",
+				"allowed_product_types: product CNC is not allowed, use MIS",
+				"blocked_symbols: RELIANCE-EQ is blocked from trading",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := order
+			tt.order(&o)
+			err := tt.limits.check(context.Background(), newStubService(), o, tt.replaces)
+			if len(tt.want) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			var rerr *Error
+			require.ErrorAs(t, err, &rerr)
+			var got []string
+			for _, v := range rerr.Violations {
+				got = append(got, v.Limit+": "+v.Message)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    Limits
+		wantErr string
+	}{
+		{
+			name:    "limits",
+			content: `{"max_order_value": 50000, "allowed_exchanges": ["nse", " bse"], "allowed_product_types": ["cnc"], "blocked_symbols": ["yesbank"]}`,
+			want: Limits{MaxOrderValue: 50000, AllowedExchanges: []string{"NSE", "BSE"}, AllowedProductTypes: []string{"CNC"},
+				BlockedSymbols: []string{"YESBANK"}},
+		},
+		{
+			name:    "unknown field",
+			content: `{"max_order_val": 50000}`,
+			wantErr: `unknown field "max_order_val"`,
+		},
+		{
+			name:    "unknown exchange",
+			content: `{"allowed_exchanges": ["MCX"]}`,
+			wantErr: `unknown exchange "MCX" in allowed_exchanges`,
+		},
+		{
+			name:    "negative",
+			content: `{"max_loss_per_day": -1}`,
+			wantErr: "limits must not be negative",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "risk.json")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o600))
+			got, err := Load(path)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTurnoverOpenMarketOrders(t *testing.T) {
+	svc := newStubService()
+	svc.book = append(svc.book,
+		// Open market order, 4 x the last price 2500
+		falcon.OrderBookEntry{OrderID: "4", TradingSymbol: "RELIANCE-EQ", ExchangeName: falcon.ExchangeNSE, TransactionType: falcon.TransactionBuy,
+			PriceType: falcon.PriceTypeMarket, Quantity: 4},
+		// Open stop loss market order, 2 x the trigger 2350
+		falcon.OrderBookEntry{OrderID: "5", TradingSymbol: "RELIANCE-EQ", ExchangeName: falcon.ExchangeNSE, TransactionType: falcon.TransactionSell,
+			PriceType: falcon.PriceTypeStopLossMarket, Quantity: 2, TriggerPrice: falcon.Rupees(2350)},
+	)
+	quotes, err := OpenOrderQuotes(context.Background(), svc, svc.book)
+	require.NoError(t, err)
+
+	// 10 x 2400 filled, 5 x 2300 open, then the two orders above
+	assert.Equal(t, falcon.Rupees(24000+11500+10000+4700), Turnover(svc.book, "", quotes))
+	assert.Equal(t, falcon.Rupees(24000+11500+4700), Turnover(svc.book, "4", quotes))
+
+	assert.Equal(t, falcon.Rupees(24000+11500+10000+4700), CurrentStatus(context.Background(), svc).Turnover)
+
+	order := falcon.OrderReq{ExchangeName: falcon.ExchangeNSE, TradingSymbol: "RELIANCE-EQ", Quantity: 1, Price: "2450",
+		TransactionType: falcon.TransactionBuy, PriceType: falcon.PriceTypeLimit}
+	err = Limits{MaxDailyTurnover: 50000}.check(context.Background(), svc, order, "")
+	assert.ErrorContains(t, err, "from ₹50200.00 to ₹52650.00")
+}
+
+func TestCheckBasket(t *testing.T) {
+	saved := Current
+	defer func() { Current = saved }()
+	Current = Limits{MaxOpenOrders: 2, MaxDailyTurnover: 70000}
+
+	leg := falcon.OrderReq{
+		ExchangeName:    falcon.ExchangeNSE,
+		TradingSymbol:   "RELIANCE-EQ",
+		Quantity:        10,
+		Price:           "2450",
+		TransactionType: falcon.TransactionBuy,
+		PriceType:       falcon.PriceTypeLimit,
+	}
+	err := CheckBasket(context.Background(), newStubService(), []falcon.OrderReq{leg, leg})
+
+	var rerr *Error
+	require.ErrorAs(t, err, &rerr)
+	assert.Equal(t, []Violation{
+		{Limit: "max_daily_turnover", Message: "leg 2: the order takes the turnover of the day from ₹60000.00 to ₹84500.00, above the limit of ₹70000.00"},
+		{Limit: "max_open_orders", Message: "leg 2: 2 orders are already open, the limit is 2"},
+	}, rerr.Violations)
+}
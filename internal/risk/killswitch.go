@@ -0,0 +1,252 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package risk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+)
+
+const killSwitchFileName = "killswitch.json"
+
+// ErrTradingHalted rejects order calls while the kill switch is engaged.
+var ErrTradingHalted = errors.New("trading is halted by the kill switch, the server is read-only until it is re-armed")
+
+// Switch is the kill switch of the server. It is kept in memory only until
+// main opens the persisted one.
+var Switch = &KillSwitch{}
+
+// KillState is the persisted state of the kill switch.
+type KillState struct {
+	Engaged   bool      `json:"engaged"`
+	Reason    string    `json:"reason,omitempty"`
+	EngagedAt time.Time `json:"engaged_at,omitempty"`
+}
+
+// KillReport is the outcome of engaging the kill switch.
+type KillReport struct {
+	KillState
+	Cancelled  []string `json:"cancelled_orders"`
+	SquaredOff []string `json:"squared_off,omitempty"`
+	Errors     []string `json:"errors,omitempty"`
+}
+
+// KillSwitch halts trading. While it is engaged every order call is refused,
+// across restarts, until it is re-armed. Changes another process makes to
+// the state file, such as the rearm command, are picked up on the next check.
+type KillSwitch struct {
+	mu    sync.Mutex
+	path  string
+	state KillState
+	// file is the state file as last read or written
+	file os.FileInfo
+}
+
+// DefaultKillSwitchPath returns the state file location under the user config
+// directory.
+func DefaultKillSwitchPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve config dir: %w", err)
+	}
+	return filepath.Join(dir, "wealthy-mcp", killSwitchFileName), nil
+}
+
+// OpenKillSwitch restores the kill switch persisted at path. An empty path
+// selects DefaultKillSwitchPath.
+func OpenKillSwitch(path string) (*KillSwitch, error) {
+	if path == "" {
+		p, err := DefaultKillSwitchPath()
+		if err != nil {
+			return nil, err
+		}
+		path = p
+	}
+	k := &KillSwitch{path: path}
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return k, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kill switch state: %w", err)
+	}
+	if err := k.load(info); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// load reads the state file described by info.
+func (k *KillSwitch) load(info os.FileInfo) error {
+	data, err := os.ReadFile(k.path)
+	if err != nil {
+		return fmt.Errorf("failed to read kill switch state: %w", err)
+	}
+	var state KillState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to parse kill switch state %s: %w", k.path, err)
+	}
+	k.state, k.file = state, info
+	return nil
+}
+
+// reloadLocked reads the state file again when it changed since it was last
+// read or written. A file that cannot be read leaves the state as it is.
+// Callers must hold k.mu.
+func (k *KillSwitch) reloadLocked() {
+	if k.path == "" {
+		return
+	}
+	info, err := os.Stat(k.path)
+	if err != nil || (k.file != nil && info.ModTime().Equal(k.file.ModTime()) && info.Size() == k.file.Size()) {
+		return
+	}
+	engaged := k.state.Engaged
+	if err := k.load(info); err != nil {
+		slog.Error("failed to reload kill switch state", "error", err)
+		return
+	}
+	if engaged != k.state.Engaged {
+		slog.Info("kill switch state changed on disk", "engaged", k.state.Engaged)
+	}
+}
+
+// State returns the current state.
+func (k *KillSwitch) State() KillState {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.reloadLocked()
+	return k.state
+}
+
+// Check returns ErrTradingHalted while the kill switch is engaged.
+func (k *KillSwitch) Check() error {
+	if k.State().Engaged {
+		return ErrTradingHalted
+	}
+	return nil
+}
+
+// Engage halts trading, then cancels every open order and, with squareOff,
+// closes the intraday positions at market. Trading stays halted when some of
+// the orders cannot be cancelled; they are listed in the report.
+func (k *KillSwitch) Engage(ctx context.Context, svc falcon.FalconService, reason string, squareOff bool) *KillReport {
+	k.mu.Lock()
+	k.reloadLocked()
+	if !k.state.Engaged {
+		k.state = KillState{Engaged: true, Reason: reason, EngagedAt: time.Now().UTC()}
+	}
+	err := k.save()
+	state := k.state
+	k.mu.Unlock()
+	if err != nil {
+		// Halted in memory, but a restart would resume trading
+		slog.Error("failed to persist kill switch state", "error", err)
+	}
+	slog.Warn("kill switch engaged", "reason", state.Reason)
+
+	report := &KillReport{KillState: state, Cancelled: []string{}}
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	}
+
+	book, err := svc.GetOrderBook(ctx)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("could not fetch the order book, open orders were not cancelled: %v", err))
+	}
+	for _, o := range book {
+		if !o.IsOpen() {
+			continue
+		}
+		if _, err := svc.CancelOrder(ctx, falcon.CancelOrderReq{OrderID: o.OrderID, OrderType: o.OrderType}); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("could not cancel order %s: %v", o.OrderID, err))
+			continue
+		}
+		report.Cancelled = append(report.Cancelled, o.OrderID)
+	}
+
+	if squareOff {
+		squareOffIntraday(ctx, svc, report)
+	}
+	return report
+}
+
+// squareOffIntraday closes the intraday positions, those whose order_type is
+// the MIS product, with market orders of the same product.
+func squareOffIntraday(ctx context.Context, svc falcon.FalconService, report *KillReport) {
+	positions, err := svc.GetPositions(ctx)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("could not fetch the positions, nothing was squared off: %v", err))
+		return
+	}
+	for _, p := range positions {
+		if p.OrderType != falcon.ProductMIS || p.NetQuantity == 0 {
+			continue
+		}
+		order := falcon.OrderReq{
+			ExchangeName:    p.ExchangeName,
+			Token:           p.Token,
+			TradingSymbol:   p.TradingSymbol,
+			Quantity:        abs(p.NetQuantity),
+			OrderType:       falcon.ProductMIS,
+			TransactionType: falcon.TransactionSell,
+			PriceType:       falcon.PriceTypeMarket,
+			Validity:        falcon.ValidityDay,
+		}
+		if p.NetQuantity < 0 {
+			order.TransactionType = falcon.TransactionBuy
+		}
+		if _, err := svc.PlaceOrder(ctx, []falcon.OrderReq{order}); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("could not square off %s: %v", p.TradingSymbol, err))
+			continue
+		}
+		report.SquaredOff = append(report.SquaredOff, fmt.Sprintf("%s %d %s", falcon.TransactionName(order.TransactionType),
+			order.Quantity, p.TradingSymbol))
+	}
+}
+
+// Rearm lets trading resume.
+func (k *KillSwitch) Rearm() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.state = KillState{}
+	slog.Info("kill switch re-armed")
+	return k.save()
+}
+
+func (k *KillSwitch) save() error {
+	if k.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(k.state)
+	if err != nil {
+		return fmt.Errorf("failed to serialize kill switch state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(k.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create kill switch dir: %w", err)
+	}
+	tmp := k.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write kill switch state: %w", err)
+	}
+	if err := os.Rename(tmp, k.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace kill switch state: %w", err)
+	}
+	if info, err := os.Stat(k.path); err == nil {
+		k.file = info
+	}
+	return nil
+}
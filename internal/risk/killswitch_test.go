@@ -0,0 +1,85 @@
+package risk
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+)
+
+func TestKillSwitch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "killswitch.json")
+	ks, err := OpenKillSwitch(path)
+	require.NoError(t, err)
+	require.NoError(t, ks.Check())
+
+	svc := newStubService()
+	svc.positions = append(svc.positions,
+		falcon.Position{TradingSymbol: "INFY-EQ", ExchangeName: falcon.ExchangeNSE, OrderType: falcon.ProductMIS, NetQuantity: -20},
+		falcon.Position{TradingSymbol: "TCS-EQ", ExchangeName: falcon.ExchangeNSE, OrderType: falcon.ProductMIS},
+	)
+	report := ks.Engage(context.Background(), svc, "runaway orders", true)
+
+	assert.True(t, report.Engaged)
+	assert.Equal(t, "runaway orders", report.Reason)
+	assert.Equal(t, []string{"2"}, report.Cancelled)
+	assert.Equal(t, []string{"2"}, svc.cancelled)
+	assert.Equal(t, []string{"BUY 20 INFY-EQ"}, report.SquaredOff)
+	require.Len(t, svc.placed, 1)
+	assert.Equal(t, falcon.PriceTypeMarket, svc.placed[0].PriceType)
+	assert.Empty(t, report.Errors)
+	assert.ErrorIs(t, ks.Check(), ErrTradingHalted)
+
+	// The halt survives a restart
+	reopened, err := OpenKillSwitch(path)
+	require.NoError(t, err)
+	assert.ErrorIs(t, reopened.Check(), ErrTradingHalted)
+	assert.Equal(t, "runaway orders", reopened.State().Reason)
+
+	require.NoError(t, reopened.Rearm())
+	assert.NoError(t, reopened.Check())
+	assert.NoError(t, ks.Check(), "a running server picks up the rearm of another process")
+	reopened, err = OpenKillSwitch(path)
+	require.NoError(t, err)
+	assert.False(t, reopened.State().Engaged)
+}
+
+func TestCheckPlaceWhileHalted(t *testing.T) {
+	saved := Switch
+	defer func() { Switch = saved }()
+	Switch = &KillSwitch{state: KillState{Engaged: true}}
+
+	err := CheckPlace(context.Background(), newStubService(), falcon.OrderReq{})
+	assert.ErrorIs(t, err, ErrTradingHalted)
+	err = CheckModify(context.Background(), newStubService(), falcon.ModifyOrderReq{OrderID: "2"})
+	assert.ErrorIs(t, err, ErrTradingHalted)
+}
+
+func TestSquareOffIntradayOnly(t *testing.T) {
+	// The positions report as the API returns it, order_type is the product
+	var positions falcon.Positions
+	require.NoError(t, json.Unmarshal([]byte(`{"data":{"positions":[
+		{"trading_symbol":"INFY-EQ","exchange_name":1,"token":"1594","order_type":2,"net_quantity":15,"ltp":"152000"},
+		{"trading_symbol":"TCS-EQ","exchange_name":1,"token":"11536","order_type":1,"net_quantity":4,"ltp":"345000"},
+		{"trading_symbol":"SBIN-EQ","exchange_name":1,"token":"3045","order_type":2,"net_quantity":-30,"ltp":"81000"}
+	]}}`), &positions))
+
+	svc := newStubService()
+	svc.positions = positions
+	report := &KillReport{}
+	squareOffIntraday(context.Background(), svc, report)
+
+	assert.Empty(t, report.Errors)
+	assert.Equal(t, []string{"SELL 15 INFY-EQ", "BUY 30 SBIN-EQ"}, report.SquaredOff, "the CNC holding is kept")
+	require.Len(t, svc.placed, 2)
+	for _, o := range svc.placed {
+		assert.Equal(t, falcon.ProductMIS, o.OrderType)
+		assert.Equal(t, falcon.PriceTypeMarket, o.PriceType)
+		assert.Empty(t, o.Price)
+		assert.NoError(t, o.Validate())
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package risk enforces the pre-trade risk limits of the server. Orders that
+// breach a limit are rejected before they reach the trading API.
+package risk
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+)
+
+// Limits are the risk limits read from the config file. Amounts are in rupees
+// and a zero or empty limit is not enforced.
+type Limits struct {
+	// MaxOrderValue caps the value of a single order
+	MaxOrderValue float64 `json:"max_order_value,omitempty"`
+	// MaxQuantityPerSymbol caps the net quantity held in a symbol once the
+	// order, the open orders and the position of the day are combined
+	MaxQuantityPerSymbol int `json:"max_quantity_per_symbol,omitempty"`
+	// MaxDailyTurnover caps the value of all orders of the day
+	MaxDailyTurnover float64 `json:"max_daily_turnover,omitempty"`
+	// MaxOpenOrders caps the number of orders waiting at the exchange
+	MaxOpenOrders int `json:"max_open_orders,omitempty"`
+	// AllowedExchanges lists the exchanges orders may go to, e.g. NSE
+	AllowedExchanges []string `json:"allowed_exchanges,omitempty"`
+	// AllowedProductTypes lists the products orders may use, e.g. CNC
+	AllowedProductTypes []string `json:"allowed_product_types,omitempty"`
+	// BlockedSymbols lists trading symbols that may not be traded
+	BlockedSymbols []string `json:"blocked_symbols,omitempty"`
+	// MaxLossPerDay stops new orders once the profit and loss of the day's
+	// positions falls to minus this amount. Orders that reduce a position are
+	// still allowed.
+	MaxLossPerDay float64 `json:"max_loss_per_day,omitempty"`
+}
+
+// Current holds the limits in force. Set from main.
+var Current Limits
+
+// Load reads limits from a JSON config file.
+func Load(path string) (Limits, error) {
+	var l Limits
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return l, fmt.Errorf("failed to read risk limits: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&l); err != nil {
+		return l, fmt.Errorf("failed to parse risk limits %s: %w", path, err)
+	}
+	if err := l.normalize(); err != nil {
+		return l, fmt.Errorf("invalid risk limits %s: %w", path, err)
+	}
+	return l, nil
+}
+
+// Enabled reports whether any limit is set.
+func (l Limits) Enabled() bool {
+	return l.MaxOrderValue > 0 || l.MaxQuantityPerSymbol > 0 || l.MaxDailyTurnover > 0 || l.MaxOpenOrders > 0 ||
+		len(l.AllowedExchanges) > 0 || len(l.AllowedProductTypes) > 0 || len(l.BlockedSymbols) > 0 || l.MaxLossPerDay > 0
+}
+
+// normalize upper cases the names of the limits and checks them.
+func (l *Limits) normalize() error {
+	var errs []error
+	if l.MaxOrderValue < 0 || l.MaxDailyTurnover < 0 || l.MaxLossPerDay < 0 || l.MaxQuantityPerSymbol < 0 || l.MaxOpenOrders < 0 {
+		errs = append(errs, errors.New("limits must not be negative"))
+	}
+	for i, e := range l.AllowedExchanges {
+		l.AllowedExchanges[i] = strings.ToUpper(strings.TrimSpace(e))
+		if exchangeID(l.AllowedExchanges[i]) == 0 {
+			errs = append(errs, fmt.Errorf("unknown exchange %q in allowed_exchanges, use NSE, NFO, BSE or BFO", e))
+		}
+	}
+	for i, p := range l.AllowedProductTypes {
+		l.AllowedProductTypes[i] = strings.ToUpper(strings.TrimSpace(p))
+		if productID(l.AllowedProductTypes[i]) == 0 {
+			errs = append(errs, fmt.Errorf("unknown product %q in allowed_product_types, use CNC, MIS, NRML, COVER, BRACKET or MTF", p))
+		}
+	}
+	for i, s := range l.BlockedSymbols {
+		l.BlockedSymbols[i] = strings.ToUpper(strings.TrimSpace(s))
+	}
+	return errors.Join(errs...)
+}
+
+func exchangeID(name string) int {
+	for id := falcon.ExchangeNSE; id <= falcon.ExchangeBFO; id++ {
+		if strings.EqualFold(falcon.ExchangeName(id), name) {
+			return id
+		}
+	}
+	return 0
+}
+
+func productID(name string) int {
+	for id := falcon.ProductCNC; id <= falcon.ProductMTF; id++ {
+		if falcon.ProductName(id) == name {
+			return id
+		}
+	}
+	return 0
+}
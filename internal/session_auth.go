@@ -0,0 +1,175 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	sessionParam = "session"
+
+	// sessionIdleTTL is how long the credentials of an idle session are kept.
+	sessionIdleTTL = 24 * time.Hour
+)
+
+var (
+	ErrLoginRequired = errors.New("login required")
+
+	// Sessions holds the credentials of MCP client sessions when each client
+	// logs in with its own Wealthy account.
+	Sessions = NewSessionAuth(sessionIdleTTL)
+)
+
+type authManagerKey struct{}
+
+// WithAuthManager returns a context whose API calls authenticate through m.
+func WithAuthManager(ctx context.Context, m *AuthManager) context.Context {
+	return context.WithValue(ctx, authManagerKey{}, m)
+}
+
+// AuthFromContext returns the AuthManager of the client session in ctx,
+// falling back to the server wide Auth.
+func AuthFromContext(ctx context.Context) *AuthManager {
+	if m, ok := ctx.Value(authManagerKey{}).(*AuthManager); ok && m != nil {
+		return m
+	}
+	return Auth
+}
+
+// SessionAuth keeps a separate AuthManager per MCP session ID so that the
+// clients of a shared server each trade with their own credentials.
+type SessionAuth struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	sessions map[string]*sessionEntry
+}
+
+type sessionEntry struct {
+	auth     *AuthManager
+	lastSeen time.Time
+}
+
+// NewSessionAuth creates a SessionAuth that forgets sessions idle for ttl.
+func NewSessionAuth(ttl time.Duration) *SessionAuth {
+	return &SessionAuth{
+		ttl:      ttl,
+		sessions: make(map[string]*sessionEntry),
+	}
+}
+
+// Get returns the AuthManager of the session, creating it on first use.
+func (s *SessionAuth) Get(sessionID string) *AuthManager {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.pruneLocked(now)
+
+	entry, ok := s.sessions[sessionID]
+	if !ok {
+		m := NewAuthManager()
+		m.sessionID = sessionID
+		m.SetCallbackURL(sessionCallbackURL(sessionID))
+		entry = &sessionEntry{auth: m}
+		s.sessions[sessionID] = entry
+	}
+	entry.lastSeen = now
+	return entry.auth
+}
+
+// Lookup returns the AuthManager of a known session, nil otherwise.
+func (s *SessionAuth) Lookup(sessionID string) *AuthManager {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.sessions[sessionID]; ok {
+		return entry.auth
+	}
+	return nil
+}
+
+// Remove forgets the credentials of a session.
+func (s *SessionAuth) Remove(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, sessionID)
+}
+
+// Attach adds the hook that forgets the credentials of a session once it
+// ends. Sessions that report their end, like the Streamable HTTP ones, end
+// with Done, the others, like SSE sessions, with the request that registered
+// them.
+func (s *SessionAuth) Attach(hooks *server.Hooks) {
+	hooks.AddOnRegisterSession(func(ctx context.Context, session server.ClientSession) {
+		done := ctx.Done()
+		if c, ok := session.(interface{ Done() <-chan struct{} }); ok {
+			done = c.Done()
+		}
+		if done == nil {
+			return
+		}
+		go func() {
+			<-done
+			s.Remove(session.SessionID())
+		}()
+	})
+}
+
+func (s *SessionAuth) pruneLocked(now time.Time) {
+	for id, entry := range s.sessions {
+		if now.Sub(entry.lastSeen) > s.ttl {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// Authorize returns the token to send with an API request made on behalf of
+// ctx. The server wide login may still be in progress, in which case it waits
+// for it. A client session that is not logged in gets a login link instead,
+// returned through ErrLoginRequired, because only the client can complete it.
+func Authorize(ctx context.Context) (string, error) {
+	m := AuthFromContext(ctx)
+	if m.SessionID() == "" {
+		return m.WaitForToken(ctx, LoginWaitTimeout)
+	}
+	switch m.State() {
+	case AUTH_SUCCESS:
+		return m.Token(), nil
+	case AUTH_STARTED:
+	default:
+		m.Start()
+		newLoginLink(m)
+	}
+	return "", ErrLoginRequired
+}
+
+// LoginLinkFor returns the pending login link of the caller's session,
+// starting a login for a client session that is not logged in.
+func LoginLinkFor(ctx context.Context) *LoginLink {
+	m := AuthFromContext(ctx)
+	if m.SessionID() != "" && m.LoginRequired() {
+		m.Start()
+		return newLoginLink(m)
+	}
+	return m.PendingLoginLink()
+}
+
+func sessionCallbackURL(sessionID string) string {
+	callback := Auth.CallbackURL()
+	u, err := url.Parse(callback)
+	if err != nil {
+		return callback
+	}
+	q := u.Query()
+	q.Set(sessionParam, sessionID)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
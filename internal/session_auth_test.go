@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorizeSession(t *testing.T) {
+	Auth.SetCallbackURL("http://localhost:8004/auth/callback")
+	sessions := NewSessionAuth(time.Hour)
+	m := sessions.Get("session-1")
+	ctx := WithAuthManager(context.Background(), m)
+
+	_, err := Authorize(ctx)
+	assert.ErrorIs(t, err, ErrLoginRequired)
+	assert.Equal(t, AUTH_STARTED, m.State())
+
+	link := m.PendingLoginLink()
+	require.NotNil(t, link)
+	loginURL, err := url.Parse(link.URL)
+	require.NoError(t, err)
+	callback, err := url.Parse(loginURL.Query().Get("redirect_url"))
+	require.NoError(t, err)
+	assert.Equal(t, "session-1", callback.Query().Get(sessionParam))
+	assert.Equal(t, link.Code, callback.Query().Get(loginCodeParam))
+	assert.Contains(t, LoginHint(ctx), link.Code)
+
+	// The session's login does not touch the server wide one
+	assert.Nil(t, Auth.PendingLoginLink())
+
+	m.Succeed("session-token", time.Time{})
+	token, err := Authorize(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "session-token", token)
+	assert.Empty(t, Auth.Token())
+}
+
+func TestSessionAuthPrunesIdleSessions(t *testing.T) {
+	sessions := NewSessionAuth(time.Millisecond)
+	first := sessions.Get("session-1")
+	time.Sleep(5 * time.Millisecond)
+	sessions.Get("session-2")
+
+	assert.Nil(t, sessions.Lookup("session-1"))
+	assert.NotSame(t, first, sessions.Get("session-1"))
+}
+
+type clientSession struct {
+	id   string
+	done chan struct{}
+}
+
+func (s *clientSession) SessionID() string                                   { return s.id }
+func (s *clientSession) Initialize()                                         {}
+func (s *clientSession) Initialized() bool                                   { return true }
+func (s *clientSession) NotificationChannel() chan<- mcp.JSONRPCNotification { return nil }
+
+// doneSession reports its end like a Streamable HTTP session.
+type doneSession struct{ clientSession }
+
+func (s *doneSession) Done() <-chan struct{} { return s.done }
+
+func TestSessionAuthForgetsEndedSessions(t *testing.T) {
+	sessions := NewSessionAuth(time.Hour)
+	hooks := &server.Hooks{}
+	sessions.Attach(hooks)
+
+	// An SSE session ends with its request
+	sse := &clientSession{id: "sse-1"}
+	sessions.Get(sse.id)
+	ctx, cancel := context.WithCancel(context.Background())
+	hooks.RegisterSession(ctx, sse)
+
+	// A Streamable HTTP session outlives the request that created it
+	streamable := &doneSession{clientSession{id: "http-1", done: make(chan struct{})}}
+	sessions.Get(streamable.id)
+	reqCtx, reqCancel := context.WithCancel(context.Background())
+	hooks.RegisterSession(reqCtx, streamable)
+	reqCancel()
+
+	cancel()
+	assert.Eventually(t, func() bool { return sessions.Lookup(sse.id) == nil }, time.Second, 5*time.Millisecond)
+	assert.NotNil(t, sessions.Lookup(streamable.id), "the session is still open")
+
+	close(streamable.done)
+	assert.Eventually(t, func() bool { return sessions.Lookup(streamable.id) == nil }, time.Second, 5*time.Millisecond)
+}
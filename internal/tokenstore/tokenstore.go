@@ -0,0 +1,280 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package tokenstore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	appDir        = "wealthy-mcp"
+	tokenFileName = "token.enc"
+	fileVersion   = 1
+
+	kdfPassphrase = "scrypt-passphrase"
+	kdfMachine    = "scrypt-machine"
+
+	// defaultTTL is used when the access token does not carry its own expiry.
+	defaultTTL = 12 * time.Hour
+)
+
+var (
+	ErrNotFound = errors.New("no stored auth token")
+	ErrExpired  = errors.New("stored auth token has expired")
+
+	machineIDFiles = []string{"/etc/machine-id", "/var/lib/dbus/machine-id"}
+)
+
+// Token is an access token together with its expiry metadata.
+type Token struct {
+	AccessToken string    `json:"access_token"`
+	IssuedAt    time.Time `json:"issued_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// NewToken wraps an access token issued at the given time. The expiry is taken
+// from the token's JWT "exp" claim when present, otherwise defaultTTL is used.
+func NewToken(accessToken string, issuedAt time.Time) *Token {
+	expiresAt := issuedAt.Add(defaultTTL)
+	if exp, ok := jwtExpiry(accessToken); ok {
+		expiresAt = exp
+	}
+	return &Token{
+		AccessToken: accessToken,
+		IssuedAt:    issuedAt,
+		ExpiresAt:   expiresAt,
+	}
+}
+
+// Expired reports whether the token is expired at the given time.
+func (t *Token) Expired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
+
+// Store persists a single Token to an AES-GCM encrypted file. The encryption
+// key is derived with scrypt from a user supplied passphrase or, when none is
+// given, from machine specific identifiers.
+type Store struct {
+	path       string
+	passphrase string
+}
+
+// envelope is the on-disk representation of an encrypted token.
+type envelope struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Salt       string `json:"salt"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// DefaultPath returns the token file location under the user config directory.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve config dir: %w", err)
+	}
+	return filepath.Join(dir, appDir, tokenFileName), nil
+}
+
+// New creates a Store backed by the file at path. An empty path selects
+// DefaultPath. An empty passphrase selects the machine derived key.
+func New(path, passphrase string) (*Store, error) {
+	if path == "" {
+		p, err := DefaultPath()
+		if err != nil {
+			return nil, err
+		}
+		path = p
+	}
+	return &Store{path: path, passphrase: passphrase}, nil
+}
+
+// Path returns the file the store reads and writes.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Load decrypts and returns the stored token. It returns ErrNotFound when no
+// token has been saved and ErrExpired (along with the token) when it has expired.
+func (s *Store) Load() (*Token, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token file: %w", err)
+	}
+
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("failed to parse token file: %w", err)
+	}
+	if env.Version != fileVersion {
+		return nil, fmt.Errorf("unsupported token file version: %d", env.Version)
+	}
+	if env.KDF != s.kdf() {
+		return nil, fmt.Errorf("token file was encrypted with %s, current key source is %s", env.KDF, s.kdf())
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(env.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode salt: %w", err)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(env.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode nonce: %w", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(env.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode ciphertext: %w", err)
+	}
+
+	aead, err := s.aead(salt)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt token file, wrong passphrase or different machine: %w", err)
+	}
+
+	var token Token
+	if err := json.Unmarshal(plaintext, &token); err != nil {
+		return nil, fmt.Errorf("failed to parse token: %w", err)
+	}
+	if token.Expired(time.Now()) {
+		return &token, ErrExpired
+	}
+	return &token, nil
+}
+
+// Save encrypts the token and atomically replaces the token file.
+func (s *Store) Save(token *Token) error {
+	plaintext, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("failed to serialize token: %w", err)
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+	aead, err := s.aead(salt)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	data, err := json.Marshal(envelope{
+		Version:    fileVersion,
+		KDF:        s.kdf(),
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext: base64.StdEncoding.EncodeToString(aead.Seal(nil, nonce, plaintext, nil)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to serialize token file: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create token dir: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write token file: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace token file: %w", err)
+	}
+	return nil
+}
+
+// Clear removes the stored token. Clearing an empty store is not an error.
+func (s *Store) Clear() error {
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove token file: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) kdf() string {
+	if s.passphrase != "" {
+		return kdfPassphrase
+	}
+	return kdfMachine
+}
+
+func (s *Store) aead(salt []byte) (cipher.AEAD, error) {
+	secret := s.passphrase
+	if secret == "" {
+		secret = machineSecret()
+	}
+	key, err := scrypt.Key([]byte(secret), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// machineSecret combines identifiers that are stable for a user on a machine
+// so that a copied token file cannot be decrypted elsewhere.
+func machineSecret() string {
+	parts := []string{appDir}
+	for _, f := range machineIDFiles {
+		if id, err := os.ReadFile(f); err == nil {
+			parts = append(parts, strings.TrimSpace(string(id)))
+			break
+		}
+	}
+	if host, err := os.Hostname(); err == nil {
+		parts = append(parts, host)
+	}
+	if u, err := user.Current(); err == nil {
+		parts = append(parts, u.Uid, u.Username, u.HomeDir)
+	}
+	return strings.Join(parts, "|")
+}
+
+// jwtExpiry extracts the "exp" claim from a JWT without verifying it.
+func jwtExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, false
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(claims.Exp, 0), true
+}
@@ -0,0 +1,80 @@
+package tokenstore
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreRoundTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		passphrase string
+	}{
+		{name: "machine key", passphrase: ""},
+		{name: "passphrase", passphrase: "correct horse battery staple"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "token.enc")
+			store, err := New(path, tt.passphrase)
+			require.NoError(t, err)
+
+			_, err = store.Load()
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			want := NewToken("test-token", time.Now().Truncate(time.Second))
+			require.NoError(t, store.Save(want))
+
+			raw, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.NotContains(t, string(raw), "test-token")
+
+			got, err := store.Load()
+			require.NoError(t, err)
+			assert.Equal(t, want.AccessToken, got.AccessToken)
+			assert.True(t, want.ExpiresAt.Equal(got.ExpiresAt))
+
+			require.NoError(t, store.Clear())
+			_, err = store.Load()
+			assert.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
+
+func TestStoreWrongPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.enc")
+	store, err := New(path, "first")
+	require.NoError(t, err)
+	require.NoError(t, store.Save(NewToken("test-token", time.Now())))
+
+	other, err := New(path, "second")
+	require.NoError(t, err)
+	_, err = other.Load()
+	assert.Error(t, err)
+}
+
+func TestStoreExpired(t *testing.T) {
+	store, err := New(filepath.Join(t.TempDir(), "token.enc"), "")
+	require.NoError(t, err)
+	require.NoError(t, store.Save(NewToken("test-token", time.Now().Add(-2*defaultTTL))))
+
+	got, err := store.Load()
+	assert.ErrorIs(t, err, ErrExpired)
+	require.NotNil(t, got)
+	assert.Equal(t, "test-token", got.AccessToken)
+}
+
+func TestNewTokenJWTExpiry(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Unix()
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, exp)))
+	token := NewToken("header."+payload+".signature", time.Now())
+	assert.Equal(t, exp, token.ExpiresAt.Unix())
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package transport
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// MethodHandler serves a JSON-RPC request method the MCPServer does not
+// implement, such as resources/subscribe, and returns the request's result.
+type MethodHandler func(ctx context.Context, sessionID string, params json.RawMessage) (any, error)
+
+// Methods maps JSON-RPC methods to the handlers that serve them in place of
+// the MCPServer.
+type Methods map[string]MethodHandler
+
+// handle answers msg if it is a request for one of the methods, and reports
+// whether it did.
+func (m Methods) handle(ctx context.Context, sessionID string, msg json.RawMessage) (mcp.JSONRPCMessage, bool) {
+	if len(m) == 0 || !isRequest(msg) {
+		return nil, false
+	}
+	var req struct {
+		ID     mcp.RequestId   `json:"id"`
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+	}
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return nil, false
+	}
+	handler, ok := m[req.Method]
+	if !ok {
+		return nil, false
+	}
+
+	result, err := handler(ctx, sessionID, req.Params)
+	if err != nil {
+		resp := mcp.JSONRPCError{JSONRPC: mcp.JSONRPC_VERSION, ID: req.ID}
+		resp.Error.Code = mcp.INVALID_PARAMS
+		resp.Error.Message = err.Error()
+		return resp, true
+	}
+	if result == nil {
+		result = struct{}{}
+	}
+	return mcp.JSONRPCResponse{JSONRPC: mcp.JSONRPC_VERSION, ID: req.ID, Result: result}, true
+}
+
+// InterceptStdio wraps the input and output of a stdio server, answering the
+// requests for methods itself and passing every other line on to the server.
+// The returned writer serializes the server's writes with the responses.
+func InterceptStdio(in io.Reader, out io.Writer, sessionID string, methods Methods) (io.Reader, io.Writer) {
+	w := &lockedWriter{w: out}
+	pr, pw := io.Pipe()
+	go func() {
+		r := bufio.NewReader(in)
+		for {
+			line, err := r.ReadBytes('\n')
+			if len(line) > 0 {
+				if resp, ok := methods.handle(context.Background(), sessionID, line); ok {
+					data, merr := json.Marshal(resp)
+					if merr != nil {
+						slog.Error("failed to marshal response", "error", merr)
+					} else if _, werr := w.Write(append(data, '\n')); werr != nil {
+						slog.Error("failed to write response", "error", werr)
+					}
+				} else if _, werr := pw.Write(line); werr != nil {
+					return
+				}
+			}
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					err = nil
+				}
+				pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+	return pr, w
+}
+
+// lockedWriter lets the stdio server and the intercepted methods share the
+// output, each write is one message.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
@@ -0,0 +1,86 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// subscribeMethods records the subscriptions of resources/subscribe.
+func subscribeMethods(subscribed *[]string) Methods {
+	return Methods{
+		"resources/subscribe": func(ctx context.Context, sessionID string, params json.RawMessage) (any, error) {
+			var p struct {
+				URI string `json:"uri"`
+			}
+			json.Unmarshal(params, &p)
+			if p.URI == "" {
+				return nil, errors.New("uri is required")
+			}
+			*subscribed = append(*subscribed, sessionID+" "+p.URI)
+			return nil, nil
+		},
+	}
+}
+
+func TestStreamableHTTPMethods(t *testing.T) {
+	var subscribed []string
+	s := server.NewMCPServer("test", "1.0")
+	transport := NewStreamableHTTPServer(s, WithMethods(subscribeMethods(&subscribed)))
+	ts := httptest.NewServer(transport)
+	t.Cleanup(func() {
+		transport.Close()
+		ts.Close()
+	})
+	sessionID := initialize(t, ts.URL)
+
+	resp := post(t, ts.URL, sessionID, `{"jsonrpc":"2.0","id":2,"method":"resources/subscribe","params":{"uri":"wealthy://orders"}}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":2,"result":{}}`, string(body))
+	assert.Equal(t, []string{sessionID + " wealthy://orders"}, subscribed)
+
+	resp = post(t, ts.URL, sessionID, `{"jsonrpc":"2.0","id":3,"method":"resources/subscribe","params":{}}`)
+	body, _ = io.ReadAll(resp.Body)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":3,"error":{"code":-32602,"message":"uri is required"}}`, string(body))
+
+	resp = post(t, ts.URL, sessionID, `{"jsonrpc":"2.0","id":4,"method":"ping"}`)
+	body, _ = io.ReadAll(resp.Body)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":4,"result":{}}`, string(body), "other methods reach the server")
+}
+
+func TestInterceptStdio(t *testing.T) {
+	var subscribed []string
+	input := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"ping"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"resources/subscribe","params":{"uri":"wealthy://orders"}}`,
+		`{"jsonrpc":"2.0","method":"resources/subscribe","params":{"uri":"wealthy://holdings"}}`,
+		"",
+	}, "\n")
+	var out strings.Builder
+	in, w := InterceptStdio(strings.NewReader(input), &out, "stdio", subscribeMethods(&subscribed))
+
+	passed, err := io.ReadAll(in)
+	require.NoError(t, err)
+	assert.Equal(t, `{"jsonrpc":"2.0","id":1,"method":"ping"}`+"\n"+`{"jsonrpc":"2.0","method":"resources/subscribe","params":{"uri":"wealthy://holdings"}}`+"\n", string(passed),
+		"other requests and notifications reach the server")
+	assert.Equal(t, []string{"stdio wealthy://orders"}, subscribed)
+
+	_, err = w.Write([]byte("{}\n"))
+	require.NoError(t, err)
+	lines := bufio.NewScanner(strings.NewReader(out.String()))
+	require.True(t, lines.Scan())
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":2,"result":{}}`, lines.Text())
+	require.True(t, lines.Scan())
+	assert.Equal(t, "{}", lines.Text())
+}
@@ -0,0 +1,644 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package transport implements the MCP Streamable HTTP transport on top of
+// mcp-go's MCPServer, which only ships stdio and SSE transports.
+package transport
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// SessionIDHeader carries the session ID assigned on initialize.
+	SessionIDHeader   = "Mcp-Session-Id"
+	lastEventIDHeader = "Last-Event-ID"
+
+	maxBodySize = 4 << 20
+)
+
+// HTTPContextFunc adds values from the HTTP request to the context in which
+// the session's messages are handled.
+type HTTPContextFunc func(ctx context.Context, sessionID string, r *http.Request) context.Context
+
+// StreamableHTTPServer serves an MCPServer over the Streamable HTTP
+// transport. Clients POST JSON-RPC messages to a single endpoint and get the
+// responses back as JSON. Server initiated messages are delivered on an
+// optional SSE stream opened with GET, which can be resumed with
+// Last-Event-ID after a disconnect. DELETE ends the session.
+type StreamableHTTPServer struct {
+	server      *server.MCPServer
+	contextFunc HTTPContextFunc
+	methods     Methods
+	keepAlive   time.Duration
+	idleTTL     time.Duration
+	maxEvents   int
+
+	mu       sync.Mutex
+	sessions map[string]*session
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// StreamableOption configures a StreamableHTTPServer.
+type StreamableOption func(*StreamableHTTPServer)
+
+// WithHTTPContextFunc sets a function that prepares the context of every
+// request of a session, for example to bind it to per-session credentials.
+func WithHTTPContextFunc(fn HTTPContextFunc) StreamableOption {
+	return func(s *StreamableHTTPServer) {
+		s.contextFunc = fn
+	}
+}
+
+// WithMethods serves requests for the given methods with their handlers
+// instead of the MCPServer.
+func WithMethods(methods Methods) StreamableOption {
+	return func(s *StreamableHTTPServer) {
+		s.methods = methods
+	}
+}
+
+// WithKeepAlive sets the interval of keep-alive comments on SSE streams.
+func WithKeepAlive(interval time.Duration) StreamableOption {
+	return func(s *StreamableHTTPServer) {
+		s.keepAlive = interval
+	}
+}
+
+// WithSessionIdleTTL sets how long a session without requests or an open
+// stream is kept.
+func WithSessionIdleTTL(ttl time.Duration) StreamableOption {
+	return func(s *StreamableHTTPServer) {
+		s.idleTTL = ttl
+	}
+}
+
+// WithEventHistory sets how many server messages per session are kept for
+// resuming a stream.
+func WithEventHistory(n int) StreamableOption {
+	return func(s *StreamableHTTPServer) {
+		s.maxEvents = n
+	}
+}
+
+// NewStreamableHTTPServer creates a Streamable HTTP transport for s. Idle
+// sessions are ended in the background until Close is called.
+func NewStreamableHTTPServer(s *server.MCPServer, opts ...StreamableOption) *StreamableHTTPServer {
+	srv := &StreamableHTTPServer{
+		server:    s,
+		keepAlive: 30 * time.Second,
+		idleTTL:   time.Hour,
+		maxEvents: 256,
+		sessions:  make(map[string]*session),
+		stop:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(srv)
+	}
+	go srv.pruneIdle()
+	return srv
+}
+
+// ServeHTTP implements http.Handler for the MCP endpoint.
+func (s *StreamableHTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !validOrigin(r) {
+		http.Error(w, "Forbidden origin", http.StatusForbidden)
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		s.handlePost(w, r)
+	case http.MethodGet:
+		s.handleGet(w, r)
+	case http.MethodDelete:
+		s.handleDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Close ends all sessions and stops pruning idle ones.
+func (s *StreamableHTTPServer) Close() {
+	s.closeOnce.Do(func() { close(s.stop) })
+	s.mu.Lock()
+	sessions := s.sessions
+	s.sessions = make(map[string]*session)
+	s.mu.Unlock()
+	for _, sess := range sessions {
+		s.closeSession(sess)
+	}
+}
+
+func (s *StreamableHTTPServer) handlePost(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	messages, batch, err := splitBatch(body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, parseError(err))
+		return
+	}
+
+	var sess *session
+	if hasInitialize(messages) {
+		if r.Header.Get(SessionIDHeader) != "" {
+			http.Error(w, "Session already initialized", http.StatusBadRequest)
+			return
+		}
+		if batch {
+			http.Error(w, "initialize must not be part of a batch", http.StatusBadRequest)
+			return
+		}
+		if sess, err = s.newSession(r.Context()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(SessionIDHeader, sess.id)
+	} else if sess = s.sessionFor(w, r); sess == nil {
+		return
+	}
+
+	ctx := s.server.WithContext(r.Context(), sess)
+	if s.contextFunc != nil {
+		ctx = s.contextFunc(ctx, sess.id, r)
+	}
+
+	var responses []mcp.JSONRPCMessage
+	for _, msg := range messages {
+		if !isRequest(msg) {
+			// Notifications and responses to server requests
+			s.server.HandleMessage(ctx, msg)
+			continue
+		}
+		if resp, ok := s.methods.handle(ctx, sess.id, msg); ok {
+			responses = append(responses, resp)
+			continue
+		}
+		if resp := s.server.HandleMessage(ctx, msg); resp != nil {
+			responses = append(responses, resp)
+		}
+	}
+	sess.touch()
+
+	switch {
+	case len(responses) == 0:
+		w.WriteHeader(http.StatusAccepted)
+	case batch:
+		writeJSON(w, http.StatusOK, responses)
+	default:
+		writeJSON(w, http.StatusOK, responses[0])
+	}
+}
+
+func (s *StreamableHTTPServer) handleGet(w http.ResponseWriter, r *http.Request) {
+	if !accepts(r, "text/event-stream") {
+		http.Error(w, "GET requires Accept: text/event-stream", http.StatusNotAcceptable)
+		return
+	}
+	sess := s.sessionFor(w, r)
+	if sess == nil {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	stream, replay, ok := sess.attach(r.Header.Get(lastEventIDHeader))
+	if !ok {
+		http.Error(w, "A stream is already open for this session", http.StatusConflict)
+		return
+	}
+	defer sess.detach(stream)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	for _, ev := range replay {
+		if err := writeEvent(w, ev); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(s.keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case ev := <-stream:
+			if err := writeEvent(w, ev); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-ticker.C:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-sess.done:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *StreamableHTTPServer) handleDelete(w http.ResponseWriter, r *http.Request) {
+	sess := s.sessionFor(w, r)
+	if sess == nil {
+		return
+	}
+	s.mu.Lock()
+	delete(s.sessions, sess.id)
+	s.mu.Unlock()
+	s.closeSession(sess)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sessionFor looks up the session of a request, writing the error response
+// when it is missing or unknown.
+func (s *StreamableHTTPServer) sessionFor(w http.ResponseWriter, r *http.Request) *session {
+	id := r.Header.Get(SessionIDHeader)
+	if id == "" {
+		http.Error(w, "Missing "+SessionIDHeader+" header", http.StatusBadRequest)
+		return nil
+	}
+	s.mu.Lock()
+	sess, ok := s.sessions[id]
+	s.mu.Unlock()
+	if !ok {
+		// Tells the client to start over with a new initialize
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return nil
+	}
+	return sess
+}
+
+func (s *StreamableHTTPServer) newSession(ctx context.Context) (*session, error) {
+	id, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+	sess := &session{
+		id:            id,
+		notifications: make(chan mcp.JSONRPCNotification, 100),
+		done:          make(chan struct{}),
+		maxEvents:     s.maxEvents,
+		lastActive:    time.Now(),
+	}
+
+	s.mu.Lock()
+	expired := s.pruneLocked(time.Now())
+	s.sessions[id] = sess
+	s.mu.Unlock()
+	for _, old := range expired {
+		s.closeSession(old)
+	}
+
+	if err := s.server.RegisterSession(ctx, sess); err != nil {
+		s.mu.Lock()
+		delete(s.sessions, id)
+		s.mu.Unlock()
+		return nil, fmt.Errorf("failed to register session: %w", err)
+	}
+	go sess.forwardNotifications()
+	slog.Debug("streamable HTTP session started", "session", id)
+	return sess, nil
+}
+
+// pruneIdle ends the sessions idle for longer than idleTTL, checking twice
+// per idleTTL, until the server is closed.
+func (s *StreamableHTTPServer) pruneIdle() {
+	ticker := time.NewTicker(s.idleTTL / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			expired := s.pruneLocked(now)
+			s.mu.Unlock()
+			for _, sess := range expired {
+				s.closeSession(sess)
+			}
+		}
+	}
+}
+
+func (s *StreamableHTTPServer) pruneLocked(now time.Time) []*session {
+	var expired []*session
+	for id, sess := range s.sessions {
+		if sess.idle(now, s.idleTTL) {
+			delete(s.sessions, id)
+			expired = append(expired, sess)
+		}
+	}
+	return expired
+}
+
+func (s *StreamableHTTPServer) closeSession(sess *session) {
+	s.server.UnregisterSession(sess.id)
+	sess.close()
+	slog.Debug("streamable HTTP session ended", "session", sess.id)
+}
+
+// event is a server message sent on one of a session's SSE streams.
+type event struct {
+	stream uint64
+	seq    uint64
+	data   []byte
+}
+
+func (e event) id() string {
+	return fmt.Sprintf("%d-%d", e.stream, e.seq)
+}
+
+func parseEventID(id string) (stream, seq uint64, ok bool) {
+	a, b, found := strings.Cut(id, "-")
+	if !found {
+		return 0, 0, false
+	}
+	stream, err1 := strconv.ParseUint(a, 10, 64)
+	seq, err2 := strconv.ParseUint(b, 10, 64)
+	return stream, seq, err1 == nil && err2 == nil
+}
+
+// session is a Streamable HTTP client session. It implements
+// server.ClientSession so the MCPServer can send it notifications.
+type session struct {
+	id            string
+	notifications chan mcp.JSONRPCNotification
+	done          chan struct{}
+	closeOnce     sync.Once
+	maxEvents     int
+
+	mu          sync.Mutex
+	initialized bool
+	lastActive  time.Time
+	seq         uint64
+	nextStream  uint64
+	history     []event
+	// stream receives live events while a GET stream is attached
+	stream       chan event
+	streamNumber uint64
+}
+
+func (s *session) SessionID() string { return s.id }
+
+func (s *session) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+// Done is closed when the session ends.
+func (s *session) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *session) Initialize() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.initialized = true
+}
+
+func (s *session) Initialized() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.initialized
+}
+
+func (s *session) touch() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastActive = time.Now()
+}
+
+func (s *session) idle(now time.Time, ttl time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stream == nil && now.Sub(s.lastActive) > ttl
+}
+
+// forwardNotifications moves notifications from the MCPServer onto the
+// session's GET stream until the session is closed.
+func (s *session) forwardNotifications() {
+	for {
+		select {
+		case n := <-s.notifications:
+			data, err := json.Marshal(n)
+			if err != nil {
+				slog.Error("failed to marshal notification", "error", err)
+				continue
+			}
+			s.publish(data)
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// publish records a message in the history of the current GET stream and
+// delivers it when the stream is attached. Messages sent while no stream is
+// open are kept and replayed on resume.
+func (s *session) publish(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	ev := event{stream: s.streamNumber, seq: s.seq, data: data}
+	s.history = append(s.history, ev)
+	if len(s.history) > s.maxEvents {
+		s.history = s.history[len(s.history)-s.maxEvents:]
+	}
+	if s.stream != nil {
+		select {
+		case s.stream <- ev:
+		default:
+			slog.Warn("dropping notification for slow client, it can resume from the last event ID", "session", s.id)
+		}
+	}
+}
+
+// attach opens the session's GET stream. With a Last-Event-ID it resumes
+// that stream and returns the events the client missed, otherwise a new
+// stream is started.
+func (s *session) attach(lastEventID string) (chan event, []event, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stream != nil {
+		return nil, nil, false
+	}
+
+	var replay []event
+	stream, seq, resume := parseEventID(lastEventID)
+	if resume {
+		for _, ev := range s.history {
+			if ev.stream == stream && ev.seq > seq {
+				replay = append(replay, ev)
+			}
+		}
+		s.streamNumber = stream
+	} else {
+		s.nextStream++
+		s.streamNumber = s.nextStream
+	}
+
+	s.stream = make(chan event, 64)
+	s.lastActive = time.Now()
+	return s.stream, replay, true
+}
+
+func (s *session) detach(stream chan event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stream == stream {
+		s.stream = nil
+		s.lastActive = time.Now()
+	}
+}
+
+func (s *session) close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+func writeEvent(w io.Writer, ev event) error {
+	_, err := fmt.Fprintf(w, "id: %s\nevent: message\ndata: %s\n\n", ev.id(), ev.data)
+	return err
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("failed to write response", "error", err)
+	}
+}
+
+func parseError(err error) mcp.JSONRPCError {
+	resp := mcp.JSONRPCError{JSONRPC: mcp.JSONRPC_VERSION}
+	resp.Error.Code = mcp.PARSE_ERROR
+	resp.Error.Message = fmt.Sprintf("Parse error: %v", err)
+	return resp
+}
+
+// splitBatch splits a POST body into its JSON-RPC messages.
+func splitBatch(body []byte) ([]json.RawMessage, bool, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, false, fmt.Errorf("empty body")
+	}
+	if body[0] != '[' {
+		if !json.Valid(body) {
+			return nil, false, fmt.Errorf("invalid JSON")
+		}
+		return []json.RawMessage{body}, false, nil
+	}
+	var messages []json.RawMessage
+	if err := json.Unmarshal(body, &messages); err != nil {
+		return nil, true, err
+	}
+	if len(messages) == 0 {
+		return nil, true, fmt.Errorf("empty batch")
+	}
+	return messages, true, nil
+}
+
+type messageHeader struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+}
+
+func peek(msg json.RawMessage) messageHeader {
+	var h messageHeader
+	_ = json.Unmarshal(msg, &h)
+	return h
+}
+
+// isRequest reports whether msg is a request, which expects a response, as
+// opposed to a notification or a response to a server request.
+func isRequest(msg json.RawMessage) bool {
+	h := peek(msg)
+	return h.Method != "" && len(h.ID) > 0 && string(h.ID) != "null"
+}
+
+func hasInitialize(messages []json.RawMessage) bool {
+	for _, msg := range messages {
+		if peek(msg).Method == string(mcp.MethodInitialize) {
+			return true
+		}
+	}
+	return false
+}
+
+func accepts(r *http.Request, mediaType string) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			t, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+			if t == mediaType || t == "*/*" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validOrigin guards against DNS rebinding: browsers may only call the
+// endpoint from the server's own origin or from localhost.
+func validOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return true
+	}
+	reqHost, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		reqHost = r.Host
+	}
+	return strings.EqualFold(host, reqHost)
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,219 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const initializeRequest = `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"1.0"}}}`
+
+func setupStreamableServer(t *testing.T, opts ...StreamableOption) (*server.MCPServer, *StreamableHTTPServer, *httptest.Server) {
+	s := server.NewMCPServer("test", "1.0", server.WithToolCapabilities(true))
+	s.AddTool(mcp.NewTool("echo"), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	})
+	transport := NewStreamableHTTPServer(s, opts...)
+	ts := httptest.NewServer(transport)
+	t.Cleanup(func() {
+		transport.Close()
+		ts.Close()
+	})
+	return s, transport, ts
+}
+
+func post(t *testing.T, url, sessionID, body string) *http.Response {
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if sessionID != "" {
+		req.Header.Set(SessionIDHeader, sessionID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func initialize(t *testing.T, url string) string {
+	resp := post(t, url, "", initializeRequest)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	sessionID := resp.Header.Get(SessionIDHeader)
+	require.NotEmpty(t, sessionID)
+
+	resp = post(t, url, sessionID, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	return sessionID
+}
+
+func openStream(t *testing.T, url, sessionID, lastEventID string) (*bufio.Reader, context.CancelFunc) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set(SessionIDHeader, sessionID)
+	if lastEventID != "" {
+		req.Header.Set(lastEventIDHeader, lastEventID)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	return bufio.NewReader(resp.Body), cancel
+}
+
+// readEvent returns the id and data of the next event on the stream.
+func readEvent(t *testing.T, r *bufio.Reader) (string, string) {
+	var id, data string
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		case line == "" && data != "":
+			return id, data
+		}
+	}
+}
+
+func TestStreamableHTTPRequests(t *testing.T) {
+	_, _, ts := setupStreamableServer(t)
+
+	tests := []struct {
+		name       string
+		sessionID  func(valid string) string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "tool call",
+			sessionID:  func(valid string) string { return valid },
+			body:       `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"echo","arguments":{}}}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"text":"ok"`,
+		},
+		{
+			name:       "batch",
+			sessionID:  func(valid string) string { return valid },
+			body:       `[{"jsonrpc":"2.0","id":3,"method":"ping"},{"jsonrpc":"2.0","id":4,"method":"tools/list"}]`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"name":"echo"`,
+		},
+		{
+			name:       "missing session",
+			sessionID:  func(string) string { return "" },
+			body:       `{"jsonrpc":"2.0","id":5,"method":"ping"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown session",
+			sessionID:  func(string) string { return "unknown" },
+			body:       `{"jsonrpc":"2.0","id":6,"method":"ping"}`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "invalid JSON",
+			sessionID:  func(valid string) string { return valid },
+			body:       `{"jsonrpc":`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `"code":-32700`,
+		},
+	}
+
+	sessionID := initialize(t, ts.URL)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := post(t, ts.URL, tt.sessionID(sessionID), tt.body)
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantBody != "" {
+				var body json.RawMessage
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				assert.Contains(t, string(body), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestStreamableHTTPStreamResume(t *testing.T) {
+	s, transport, ts := setupStreamableServer(t)
+	sessionID := initialize(t, ts.URL)
+
+	stream, disconnect := openStream(t, ts.URL, sessionID, "")
+	s.AddTool(mcp.NewTool("first"), nil)
+	firstID, data := readEvent(t, stream)
+	assert.Contains(t, data, "notifications/tools/list_changed")
+
+	// A second stream for the same session is refused while one is open
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set(SessionIDHeader, sessionID)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	// Messages sent while disconnected are replayed on resume
+	disconnect()
+	require.Eventually(t, func() bool {
+		transport.mu.Lock()
+		sess := transport.sessions[sessionID]
+		transport.mu.Unlock()
+		sess.mu.Lock()
+		defer sess.mu.Unlock()
+		return sess.stream == nil
+	}, time.Second, 10*time.Millisecond)
+	s.AddTool(mcp.NewTool("second"), nil)
+
+	resumed, _ := openStream(t, ts.URL, sessionID, firstID)
+	secondID, data := readEvent(t, resumed)
+	assert.NotEqual(t, firstID, secondID)
+	assert.Contains(t, data, "notifications/tools/list_changed")
+}
+
+func TestStreamableHTTPDelete(t *testing.T) {
+	_, _, ts := setupStreamableServer(t)
+	sessionID := initialize(t, ts.URL)
+
+	req, err := http.NewRequest(http.MethodDelete, ts.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set(SessionIDHeader, sessionID)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp = post(t, ts.URL, sessionID, `{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestStreamableHTTPPrunesIdleSessions(t *testing.T) {
+	_, transport, ts := setupStreamableServer(t, WithSessionIdleTTL(20*time.Millisecond))
+	sessionID := initialize(t, ts.URL)
+
+	// No further client connects, the idle session still ends
+	assert.Eventually(t, func() bool {
+		transport.mu.Lock()
+		defer transport.mu.Unlock()
+		return len(transport.sessions) == 0
+	}, time.Second, 5*time.Millisecond)
+
+	resp := post(t, ts.URL, sessionID, `{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
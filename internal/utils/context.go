@@ -5,7 +5,11 @@
 
 package utils
 
-import "context"
+import (
+	"context"
+
+	"github.com/wealthy/wealthy-mcp/internal"
+)
 
 type ContextKey string
 
@@ -19,3 +23,11 @@ func GetAuthToken(ctx context.Context) string {
 	}
 	return ""
 }
+
+// WithSession binds ctx to the credentials of an MCP client session, so
+// that API calls made with it use the session's own login.
+func WithSession(ctx context.Context, sessionID string) context.Context {
+	auth := internal.Sessions.Get(sessionID)
+	ctx = internal.WithAuthManager(ctx, auth)
+	return context.WithValue(ctx, AuthTokenKey, auth.Token())
+}
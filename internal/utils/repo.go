@@ -1,10 +1,40 @@
 package utils
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"time"
 
+	"github.com/wealthy/wealthy-mcp/internal"
 	"github.com/wealthy/wealthy-mcp/internal/falcon"
+	"github.com/wealthy/wealthy-mcp/internal/websocket"
 )
 
 var FalconService = falcon.NewFalconService(&http.Client{Timeout: 10 * time.Second})
+
+// Stream is the live price feed, started by the first tool that needs it.
+var Stream = websocket.NewStreamManager(FalconService)
+
+// FollowOrders starts the feed that carries order updates. The feed uses the
+// server login, it is not started for a session with its own login.
+func FollowOrders(ctx context.Context) {
+	if internal.AuthFromContext(ctx) == internal.Auth {
+		Stream.Start()
+	}
+}
+
+// ErrSessionStream is returned to a session with its own login asking for
+// the live feed, which streams with the server login.
+var ErrSessionStream = errors.New("live prices are streamed with the server login and are not available to a session with its own login, use get_price instead")
+
+// StartStream starts the live price feed for a caller using the server
+// login. A session with its own login gets ErrSessionStream, starting the
+// feed would log the server account in on its behalf.
+func StartStream(ctx context.Context) error {
+	if internal.AuthFromContext(ctx) != internal.Auth {
+		return ErrSessionStream
+	}
+	Stream.Start()
+	return nil
+}
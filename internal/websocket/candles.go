@@ -0,0 +1,149 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package websocket
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+)
+
+const (
+	// defaultCandleWindow is how many bars of every interval are kept per
+	// instrument
+	defaultCandleWindow = 500
+	// sessionAnchor aligns the bars to the 09:15 IST market open, 03:45 UTC,
+	// so the hourly bars run 09:15 to 10:15 and so on
+	sessionAnchor = 45 * time.Minute
+)
+
+// intervals are the bar sizes the builder keeps, by name.
+var intervals = map[string]time.Duration{
+	"1m":  time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"1h":  time.Hour,
+}
+
+// ParseInterval returns the bar size named 1m, 5m, 15m or 1h.
+func ParseInterval(name string) (time.Duration, error) {
+	d, ok := intervals[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("invalid interval %q, must be 1m, 5m, 15m or 1h", name)
+	}
+	return d, nil
+}
+
+// Candle is an OHLC bar of the ticks received in an interval. Volume is the
+// growth of the day's volume over the bar, so it is only known for
+// instruments streamed in the Full or Extended mode.
+type Candle struct {
+	Start  time.Time    `json:"start"`
+	Open   falcon.Money `json:"open"`
+	High   falcon.Money `json:"high"`
+	Low    falcon.Money `json:"low"`
+	Close  falcon.Money `json:"close"`
+	Volume int64        `json:"volume"`
+	Ticks  int          `json:"ticks"`
+}
+
+// candleStart returns the start of the bar of the given size t falls in.
+func candleStart(t time.Time, size time.Duration) time.Time {
+	return t.Add(-sessionAnchor).Truncate(size).Add(sessionAnchor)
+}
+
+// series is the bars of one instrument.
+type series struct {
+	// volume is the day's volume of the last tick
+	volume int64
+	bars   map[time.Duration][]Candle
+}
+
+// CandleBuilder aggregates the live ticks of the price store into 1m, 5m,
+// 15m and 1h bars, keeping a rolling window of the most recent bars.
+type CandleBuilder struct {
+	window int
+
+	mu     sync.RWMutex
+	series map[string]*series
+}
+
+// Candles is the builder the websocket feed writes to.
+var Candles = NewCandleBuilder(defaultCandleWindow)
+
+// NewCandleBuilder keeps the last window bars of every interval.
+func NewCandleBuilder(window int) *CandleBuilder {
+	return &CandleBuilder{window: window, series: make(map[string]*series)}
+}
+
+// Add adds a tick to the bars of its instrument. Ticks outside market hours
+// and ticks older than the current bar are ignored.
+func (b *CandleBuilder) Add(t Tick) {
+	if !t.Live || t.LastPrice <= 0 {
+		return
+	}
+	key := storeKey(t.Exchange, t.Token)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.series[key]
+	if !ok {
+		s = &series{bars: make(map[time.Duration][]Candle)}
+		b.series[key] = s
+	}
+	var traded int64
+	switch {
+	case s.volume > 0 && t.Volume >= s.volume:
+		traded = t.Volume - s.volume
+	case t.Volume < s.volume:
+		// the day's volume restarted with a new session
+		traded = t.Volume
+	}
+	if t.Volume > 0 {
+		s.volume = t.Volume
+	}
+
+	for _, size := range intervals {
+		start := candleStart(t.UpdatedAt, size)
+		bars := s.bars[size]
+		n := len(bars)
+		switch {
+		case n > 0 && bars[n-1].Start.Equal(start):
+			c := &bars[n-1]
+			c.High, c.Low = max(c.High, t.LastPrice), min(c.Low, t.LastPrice)
+			c.Close = t.LastPrice
+			c.Volume += traded
+			c.Ticks++
+		case n > 0 && start.Before(bars[n-1].Start):
+			continue
+		default:
+			bars = append(bars, Candle{Start: start, Open: t.LastPrice, High: t.LastPrice, Low: t.LastPrice, Close: t.LastPrice, Volume: traded, Ticks: 1})
+			if len(bars) > b.window {
+				bars = append(bars[:0:0], bars[len(bars)-b.window:]...)
+			}
+		}
+		s.bars[size] = bars
+	}
+}
+
+// Get returns the last n bars of an instrument of the given size, oldest
+// first. The last bar is still forming while its interval lasts.
+func (b *CandleBuilder) Get(exchange int, token string, size time.Duration, n int) []Candle {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	s, ok := b.series[storeKey(exchange, token)]
+	if !ok {
+		return []Candle{}
+	}
+	bars := s.bars[size]
+	if n > 0 && len(bars) > n {
+		bars = bars[len(bars)-n:]
+	}
+	return append([]Candle{}, bars...)
+}
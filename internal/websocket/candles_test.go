@@ -0,0 +1,88 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+)
+
+func TestCandleStart(t *testing.T) {
+	ist := time.FixedZone("IST", 5*3600+1800)
+	at := func(h, m, s int) time.Time {
+		return time.Date(2025, 7, 1, h, m, s, 0, ist)
+	}
+	tests := []struct {
+		at   time.Time
+		size time.Duration
+		want time.Time
+	}{
+		{at(9, 15, 0), time.Minute, at(9, 15, 0)},
+		{at(9, 16, 59), time.Minute, at(9, 16, 0)},
+		{at(9, 19, 59), 5 * time.Minute, at(9, 15, 0)},
+		{at(9, 44, 0), 15 * time.Minute, at(9, 30, 0)},
+		{at(9, 15, 0), time.Hour, at(9, 15, 0)},
+		{at(10, 14, 59), time.Hour, at(9, 15, 0)},
+		{at(15, 29, 0), time.Hour, at(15, 15, 0)},
+	}
+	for _, tt := range tests {
+		got := candleStart(tt.at, tt.size)
+		assert.True(t, tt.want.Equal(got), "%s bar of %s: got %s, want %s", tt.size, tt.at.Format(time.TimeOnly), got.In(ist).Format(time.TimeOnly), tt.want.Format(time.TimeOnly))
+	}
+}
+
+func TestCandleBuilder(t *testing.T) {
+	b := NewCandleBuilder(3)
+	open := time.Date(2025, 7, 1, 3, 45, 0, 0, time.UTC)
+	tick := func(offset time.Duration, ltp float64, volume int64) Tick {
+		return Tick{Exchange: falcon.ExchangeNSE, Token: "2885", LastPrice: falcon.Rupees(ltp), Volume: volume, Live: true, UpdatedAt: open.Add(offset)}
+	}
+
+	b.Add(tick(0, 2450, 1000))
+	b.Add(tick(10*time.Second, 2455, 1200))
+	b.Add(tick(20*time.Second, 2440, 1250))
+	b.Add(tick(59*time.Second, 2445, 1300))
+	b.Add(tick(61*time.Second, 2460, 1400))
+	b.Add(tick(30*time.Second, 2400, 1500)) // late, for a closed bar
+	b.Add(Tick{Exchange: falcon.ExchangeNSE, Token: "2885", LastPrice: falcon.Rupees(2000), UpdatedAt: open.Add(62 * time.Second)})
+
+	bars := b.Get(falcon.ExchangeNSE, "2885", time.Minute, 0)
+	require.Len(t, bars, 2)
+	assert.Equal(t, Candle{Start: open, Open: falcon.Rupees(2450), High: falcon.Rupees(2455), Low: falcon.Rupees(2440), Close: falcon.Rupees(2445), Volume: 300, Ticks: 4}, bars[0])
+	assert.Equal(t, Candle{Start: open.Add(time.Minute), Open: falcon.Rupees(2460), High: falcon.Rupees(2460), Low: falcon.Rupees(2460), Close: falcon.Rupees(2460), Volume: 100, Ticks: 1}, bars[1], "late and snapshot ticks are ignored")
+
+	five := b.Get(falcon.ExchangeNSE, "2885", 5*time.Minute, 0)
+	require.Len(t, five, 1)
+	assert.Equal(t, falcon.Rupees(2460), five[0].High)
+	assert.Equal(t, falcon.Rupees(2400), five[0].Low, "a late tick still counts for the open bar")
+	assert.Equal(t, int64(500), five[0].Volume)
+
+	for i := 2; i < 6; i++ {
+		b.Add(tick(time.Duration(i)*time.Minute, 2470, 1600))
+	}
+	bars = b.Get(falcon.ExchangeNSE, "2885", time.Minute, 0)
+	require.Len(t, bars, 3, "only the window is kept")
+	assert.True(t, open.Add(5*time.Minute).Equal(bars[2].Start))
+	assert.Len(t, b.Get(falcon.ExchangeNSE, "2885", time.Minute, 2), 2)
+	assert.Empty(t, b.Get(falcon.ExchangeBSE, "2885", time.Minute, 0))
+
+	// a new session restarts the day's volume
+	b.Add(tick(24*time.Hour, 2480, 50))
+	bars = b.Get(falcon.ExchangeNSE, "2885", time.Minute, 1)
+	assert.Equal(t, int64(50), bars[0].Volume)
+}
+
+func TestStreamCandles(t *testing.T) {
+	candles := NewCandleBuilder(10)
+	m := NewStreamManager(&stubService{}, WithPriceStore(NewPriceStore()), WithOrderTracker(NewOrderTracker()), WithCandleBuilder(candles))
+	at := time.Date(2025, 7, 1, 3, 45, 0, 0, time.UTC)
+	m.handleMessage(&Message{Data: &Message_Feed{Feed: &Feed{Exchange: 1, Token: 2885, Live: true, Ltpc: &LTPC{Ltp: 245000}, Full: &Full{Volume: 10}}}}, at)
+	m.handleMessage(&Message{Data: &Message_Feed{Feed: &Feed{Exchange: 1, Token: 2885, Live: true, Ltpc: &LTPC{Ltp: 246000}, Full: &Full{Volume: 25}}}}, at.Add(time.Second))
+
+	bars := m.Candles().Get(falcon.ExchangeNSE, "2885", time.Hour, 0)
+	require.Len(t, bars, 1)
+	assert.Equal(t, falcon.Rupees(2460), bars[0].Close)
+	assert.Equal(t, int64(15), bars[0].Volume)
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package websocket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+)
+
+// BookLevels is the number of price levels the exchanges publish per side.
+const BookLevels = 5
+
+var ErrNoDepth = errors.New("no market depth")
+
+// Book is the order book of an instrument with the figures traders look at
+// before placing a limit order.
+type Book struct {
+	Exchange int          `json:"exchange_name"`
+	Token    string       `json:"token"`
+	Bids     []DepthLevel `json:"bids"`
+	Asks     []DepthLevel `json:"asks"`
+	BestBid  falcon.Money `json:"best_bid,omitempty"`
+	BestAsk  falcon.Money `json:"best_ask,omitempty"`
+	// Spread and Mid are only set when both sides have a price
+	Spread            falcon.Money `json:"spread,omitempty"`
+	SpreadPercent     float64      `json:"spread_percent,omitempty"`
+	Mid               falcon.Money `json:"mid_price,omitempty"`
+	TotalBuyQuantity  int64        `json:"total_buy_quantity"`
+	TotalSellQuantity int64        `json:"total_sell_quantity"`
+	// Imbalance is (buy - sell) / (buy + sell) of the total quantities, from
+	// -1 when there are only sellers to 1 when there are only buyers
+	Imbalance float64      `json:"imbalance"`
+	LastPrice falcon.Money `json:"ltp"`
+	Live      bool         `json:"live"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// Book normalizes the market depth of a tick: empty levels are dropped, bids
+// are sorted from the highest price and asks from the lowest, at most
+// BookLevels per side.
+func (t Tick) Book() (*Book, error) {
+	if t.Depth == nil {
+		return nil, ErrNoDepth
+	}
+	b := &Book{
+		Exchange:          t.Exchange,
+		Token:             t.Token,
+		Bids:              levels(t.Depth.Buys, func(a, b falcon.Money) bool { return a > b }),
+		Asks:              levels(t.Depth.Sells, func(a, b falcon.Money) bool { return a < b }),
+		TotalBuyQuantity:  t.Depth.TotalBuyQuantity,
+		TotalSellQuantity: t.Depth.TotalSellQuantity,
+		LastPrice:         t.LastPrice,
+		Live:              t.Live,
+		UpdatedAt:         t.UpdatedAt,
+	}
+	if b.TotalBuyQuantity == 0 && b.TotalSellQuantity == 0 {
+		b.TotalBuyQuantity, b.TotalSellQuantity = quantity(b.Bids), quantity(b.Asks)
+	}
+	if total := b.TotalBuyQuantity + b.TotalSellQuantity; total > 0 {
+		b.Imbalance = float64(b.TotalBuyQuantity-b.TotalSellQuantity) / float64(total)
+	}
+	if len(b.Bids) > 0 {
+		b.BestBid = b.Bids[0].Price
+	}
+	if len(b.Asks) > 0 {
+		b.BestAsk = b.Asks[0].Price
+	}
+	if b.BestBid > 0 && b.BestAsk > 0 {
+		b.Spread = b.BestAsk - b.BestBid
+		b.Mid = (b.BestBid + b.BestAsk + 1) / 2
+		b.SpreadPercent = float64(b.Spread) / float64(b.Mid) * 100
+	}
+	return b, nil
+}
+
+func levels(in []DepthLevel, better func(a, b falcon.Money) bool) []DepthLevel {
+	out := make([]DepthLevel, 0, BookLevels)
+	for _, l := range in {
+		if l.Price > 0 && l.Quantity > 0 {
+			out = append(out, l)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return better(out[i].Price, out[j].Price) })
+	if len(out) > BookLevels {
+		out = out[:BookLevels]
+	}
+	return out
+}
+
+func quantity(levels []DepthLevel) int64 {
+	var q int64
+	for _, l := range levels {
+		q += l.Quantity
+	}
+	return q
+}
+
+// GetDepth subscribes an instrument in the Extended mode and returns its
+// order book, waiting for the first snapshot with depth until ctx is done.
+// The subscription is released afterwards.
+func (m *StreamManager) GetDepth(ctx context.Context, exchange int, token string) (*Book, error) {
+	sub, err := m.Subscribe(ModeExtended, Instrument{Exchange: exchange, Token: token})
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Close()
+
+	var after time.Time
+	for {
+		tick, err := m.prices.Next(ctx, exchange, token, after)
+		if err != nil {
+			return nil, fmt.Errorf("no market depth for %s yet: %w", subscriptionSymbol(exchange, token), err)
+		}
+		if tick.Depth != nil {
+			return tick.Book()
+		}
+		after = tick.UpdatedAt
+	}
+}
@@ -0,0 +1,113 @@
+package websocket
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+)
+
+func level(price float64, qty int64) DepthLevel {
+	return DepthLevel{Price: falcon.Rupees(price), Quantity: qty, Orders: 1}
+}
+
+func TestTickBook(t *testing.T) {
+	tests := []struct {
+		name  string
+		depth *Depth
+		want  Book
+	}{
+		{
+			name: "levels are sorted and trimmed",
+			depth: &Depth{
+				Buys:              []DepthLevel{level(99.9, 10), level(100, 20), {}, level(99.5, 5), level(99.8, 1), level(99.7, 1), level(99.6, 1)},
+				Sells:             []DepthLevel{level(100.2, 10), level(100.1, 30)},
+				TotalBuyQuantity:  300,
+				TotalSellQuantity: 100,
+			},
+			want: Book{
+				Bids:              []DepthLevel{level(100, 20), level(99.9, 10), level(99.8, 1), level(99.7, 1), level(99.6, 1)},
+				Asks:              []DepthLevel{level(100.1, 30), level(100.2, 10)},
+				BestBid:           falcon.Rupees(100),
+				BestAsk:           falcon.Rupees(100.1),
+				Spread:            falcon.Rupees(0.1),
+				Mid:               falcon.Rupees(100.05),
+				TotalBuyQuantity:  300,
+				TotalSellQuantity: 100,
+				Imbalance:         0.5,
+			},
+		},
+		{
+			name:  "one sided book has no spread",
+			depth: &Depth{Sells: []DepthLevel{level(50, 10)}},
+			want: Book{
+				Bids:              []DepthLevel{},
+				Asks:              []DepthLevel{level(50, 10)},
+				BestAsk:           falcon.Rupees(50),
+				TotalSellQuantity: 10,
+				Imbalance:         -1,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book, err := Tick{Depth: tt.depth}.Book()
+			require.NoError(t, err)
+			spread := book.SpreadPercent
+			book.SpreadPercent = 0
+			assert.Equal(t, tt.want, *book)
+			if tt.want.Spread != 0 {
+				assert.InDelta(t, float64(tt.want.Spread)/float64(tt.want.Mid)*100, spread, 1e-9)
+			}
+		})
+	}
+
+	_, err := Tick{}.Book()
+	assert.ErrorIs(t, err, ErrNoDepth)
+}
+
+func TestGetDepth(t *testing.T) {
+	requests := make(chan PriceSubscriptionReq, 4)
+	svc := feedServer(t, func(n int, c *websocket.Conn) {
+		var req PriceSubscriptionReq
+		require.NoError(t, c.ReadJSON(&req))
+		requests <- req
+		// a snapshot without depth comes first
+		sendFeed(t, c, &Feed{Exchange: 1, Token: 2885, Ltpc: &LTPC{Ltp: 245050}})
+		sendFeed(t, c, &Feed{Exchange: 1, Token: 2885, Ltpc: &LTPC{Ltp: 245050}, Extended: &Extended{
+			MarketDepth: &MarketDepth{
+				Buys:  []*MarketDepth_Buy{{BidPrice: 245000, BidQuantity: 10, NoOfBidOrders: 2}},
+				Sells: []*MarketDepth_Sell{{AskPrice: 245100, AskQuantity: 30, NoOfAskOrders: 3}},
+			},
+			TotalBuyQuantity:  10,
+			TotalSellQuantity: 30,
+		}})
+		for {
+			var req PriceSubscriptionReq
+			if err := c.ReadJSON(&req); err != nil {
+				return
+			}
+			requests <- req
+		}
+	})
+	m := NewStreamManager(svc, WithPriceStore(NewPriceStore()))
+	m.Start()
+	defer m.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, m.WaitConnected(ctx))
+	book, err := m.GetDepth(ctx, falcon.ExchangeNSE, "2885")
+	require.NoError(t, err)
+	assert.Equal(t, falcon.Rupees(2450.50), book.Mid)
+	assert.Equal(t, falcon.Rupees(1), book.Spread)
+	assert.Equal(t, -0.5, book.Imbalance)
+	assert.Equal(t, []DepthLevel{{Price: falcon.Rupees(2450), Quantity: 10, Orders: 2}}, book.Bids)
+
+	assert.Equal(t, PriceSubscriptionReq{Operation: OperationSubscribe, Mode: ModeExtended, Symbol: []string{"NSE|2885"}}, <-requests)
+	assert.Equal(t, PriceSubscriptionReq{Operation: OperationUnsubscribe, Mode: ModeExtended, Symbol: []string{"NSE|2885"}}, <-requests, "the depth subscription is released")
+}
@@ -1,5 +1,22 @@
 package websocket
 
+// Subscription modes of the price feed, each includes the data of the
+// previous one.
+const (
+	// ModeLTPC streams the last traded price and close
+	ModeLTPC = 1
+	// ModeFull adds OHLC, volume, open interest and circuits
+	ModeFull = 2
+	// ModeExtended adds the market depth
+	ModeExtended = 3
+)
+
+// Operations of a subscription request.
+const (
+	OperationSubscribe   = 1
+	OperationUnsubscribe = 2
+)
+
 type PriceSubscriptionReq struct {
 	Operation int      `json:"operation"`
 	Mode      int      `json:"mode"`
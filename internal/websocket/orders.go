@@ -0,0 +1,244 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package websocket
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+)
+
+// OrderState is a stage of the life of an order, as tracked from the order
+// updates of the feed.
+type OrderState string
+
+const (
+	OrderOpen            OrderState = "open"
+	OrderPartiallyFilled OrderState = "partially_filled"
+	OrderFilled          OrderState = "filled"
+	OrderCancelled       OrderState = "cancelled"
+	OrderRejected        OrderState = "rejected"
+)
+
+// Terminal reports whether an order in the state can no longer change.
+func (s OrderState) Terminal() bool {
+	return s == OrderFilled || s == OrderCancelled || s == OrderRejected
+}
+
+// LiveOrder is the state of an order built from its updates.
+type LiveOrder struct {
+	OrderID           string       `json:"order_id"`
+	ExchangeOrderID   string       `json:"exchange_order_id,omitempty"`
+	TradingSymbol     string       `json:"trading_symbol"`
+	ExchangeName      int          `json:"exchange_name"`
+	TransactionType   int          `json:"transaction_type"`
+	PriceType         int          `json:"price_type"`
+	State             OrderState   `json:"state"`
+	Status            int          `json:"status"`
+	Quantity          int          `json:"quantity"`
+	FilledShares      int          `json:"filled_shares"`
+	CancelledQuantity int          `json:"cancelled_quantity,omitempty"`
+	AveragePrice      falcon.Money `json:"average_price,omitempty"`
+	LastFillPrice     falcon.Money `json:"last_fill_price,omitempty"`
+	LastFillQuantity  int          `json:"last_fill_quantity,omitempty"`
+	RejectReason      string       `json:"reject_reason,omitempty"`
+	RejectedBy        string       `json:"rejected_by,omitempty"`
+	UpdatedAt         time.Time    `json:"updated_at"`
+}
+
+// Describe is a one line account of the order's state for the user.
+func (o LiveOrder) Describe() string {
+	order := fmt.Sprintf("%s %s order %s", o.TradingSymbol, falcon.TransactionName(o.TransactionType), o.OrderID)
+	switch o.State {
+	case OrderFilled:
+		return fmt.Sprintf("Your %s was filled: %d at an average price of %s", order, o.FilledShares, o.AveragePrice)
+	case OrderPartiallyFilled:
+		return fmt.Sprintf("Your %s was partially filled: %d of %d at an average price of %s", order, o.FilledShares, o.Quantity, o.AveragePrice)
+	case OrderRejected:
+		if o.RejectReason == "" {
+			return fmt.Sprintf("Your %s was rejected", order)
+		}
+		return fmt.Sprintf("Your %s was rejected: %s", order, o.RejectReason)
+	case OrderCancelled:
+		if o.FilledShares > 0 {
+			return fmt.Sprintf("Your %s was cancelled after %d of %d filled", order, o.FilledShares, o.Quantity)
+		}
+		return fmt.Sprintf("Your %s was cancelled", order)
+	default:
+		return fmt.Sprintf("Your %s is open", order)
+	}
+}
+
+// OrderEvent is published when an order changes state or fills further.
+type OrderEvent struct {
+	Order LiveOrder `json:"order"`
+	// Previous is the state before the update, "" for a new order
+	Previous OrderState `json:"previous_state,omitempty"`
+	Message  string     `json:"message"`
+}
+
+// OrderTracker keeps the live state of every order the feed reports.
+type OrderTracker struct {
+	mu       sync.Mutex
+	orders   map[string]*LiveOrder
+	watchers map[int]chan OrderEvent
+	nextID   int
+}
+
+// Orders is the tracker the websocket feed writes to.
+var Orders = NewOrderTracker()
+
+func NewOrderTracker() *OrderTracker {
+	return &OrderTracker{orders: make(map[string]*LiveOrder), watchers: make(map[int]chan OrderEvent)}
+}
+
+// Get returns the live state of an order.
+func (t *OrderTracker) Get(orderID string) (LiveOrder, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	o, ok := t.orders[orderID]
+	if !ok {
+		return LiveOrder{}, false
+	}
+	return *o, true
+}
+
+// List returns every tracked order, the most recently updated first.
+func (t *OrderTracker) List() []LiveOrder {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	orders := make([]LiveOrder, 0, len(t.orders))
+	for _, o := range t.orders {
+		orders = append(orders, *o)
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].UpdatedAt.After(orders[j].UpdatedAt)
+	})
+	return orders
+}
+
+// Watch returns a channel receiving every subsequent order event and a
+// function to cancel the watch. Slow watchers miss events rather than
+// blocking the feed.
+func (t *OrderTracker) Watch() (<-chan OrderEvent, func()) {
+	ch := make(chan OrderEvent, 32)
+	t.mu.Lock()
+	id := t.nextID
+	t.nextID++
+	t.watchers[id] = ch
+	t.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			t.mu.Lock()
+			delete(t.watchers, id)
+			t.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Apply moves an order to the state an update reports. Updates can arrive out
+// of order, so an order never leaves a terminal state and its filled quantity
+// never decreases. It returns the event published, if the update changed the
+// order's state or filled quantity.
+func (t *OrderTracker) Apply(update *OrderUpdate, at time.Time) (OrderEvent, bool) {
+	id := update.GetOrderId()
+	if id == "" {
+		return OrderEvent{}, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	o, known := t.orders[id]
+	if !known {
+		o = &LiveOrder{OrderID: id}
+		t.orders[id] = o
+	}
+	previous, filled := o.State, o.FilledShares
+	if previous.Terminal() {
+		return OrderEvent{}, false
+	}
+	if update.GetFilledShares() < int64(o.FilledShares) {
+		// a stale update from before a fill we already applied
+		return OrderEvent{}, false
+	}
+
+	o.apply(update, at)
+	if known && o.State == previous && o.FilledShares == filled {
+		return OrderEvent{}, false
+	}
+	event := OrderEvent{Order: *o, Previous: previous, Message: o.Describe()}
+	for _, ch := range t.watchers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+	return event, true
+}
+
+func (o *LiveOrder) apply(u *OrderUpdate, at time.Time) {
+	set := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	set(&o.ExchangeOrderID, u.GetExchangeOrderId())
+	set(&o.TradingSymbol, u.GetTradingSymbol())
+	set(&o.RejectReason, u.GetRejectReason())
+	set(&o.RejectedBy, u.GetRejectedBy())
+	if u.GetExchangeName() != 0 {
+		o.ExchangeName = int(u.GetExchangeName())
+	}
+	if u.GetTransactionType() != 0 {
+		o.TransactionType = int(u.GetTransactionType())
+	}
+	if u.GetPriceType() != 0 {
+		o.PriceType = int(u.GetPriceType())
+	}
+	if u.GetQuantity() != 0 {
+		o.Quantity = int(u.GetQuantity())
+	}
+	o.Status = int(u.GetStatus())
+	o.FilledShares = int(u.GetFilledShares())
+	o.CancelledQuantity = int(u.GetCancelledQuantity())
+	if p, err := falcon.ParsePaisa(u.GetAveragePrice()); err == nil && p != 0 {
+		o.AveragePrice = p
+	}
+	if u.GetFillId() != "" {
+		if p, err := falcon.ParsePaisa(u.GetFillPrice()); err == nil {
+			o.LastFillPrice = p
+		}
+		if q, err := strconv.Atoi(u.GetFillQuantity()); err == nil {
+			o.LastFillQuantity = q
+		}
+	}
+	o.UpdatedAt = at
+	o.State = o.derive()
+}
+
+// derive works out the state from the quantities and reject reason, the
+// status codes of the feed are not documented.
+func (o *LiveOrder) derive() OrderState {
+	switch {
+	case o.RejectReason != "":
+		return OrderRejected
+	case o.Quantity > 0 && o.FilledShares >= o.Quantity:
+		return OrderFilled
+	case o.CancelledQuantity > 0:
+		return OrderCancelled
+	case o.FilledShares > 0:
+		return OrderPartiallyFilled
+	default:
+		return OrderOpen
+	}
+}
@@ -0,0 +1,129 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+)
+
+func TestOrderTracker(t *testing.T) {
+	placed := &OrderUpdate{OrderId: "24052800001", TradingSymbol: "RELIANCE-EQ", ExchangeName: 1, TransactionType: 1, Quantity: 10}
+	tests := []struct {
+		name    string
+		updates []*OrderUpdate
+		state   OrderState
+		events  int
+		message string
+	}{
+		{
+			name:    "open",
+			updates: []*OrderUpdate{placed},
+			state:   OrderOpen,
+			events:  1,
+			message: "Your RELIANCE-EQ BUY order 24052800001 is open",
+		},
+		{
+			name: "partial then full fill",
+			updates: []*OrderUpdate{
+				placed,
+				{OrderId: "24052800001", Quantity: 10, FilledShares: 4, FillId: "1", FillQuantity: "4", FillPrice: "245000", AveragePrice: "245000"},
+				{OrderId: "24052800001", Quantity: 10, FilledShares: 10, FillId: "2", FillQuantity: "6", FillPrice: "245100", AveragePrice: "245060"},
+			},
+			state:   OrderFilled,
+			events:  3,
+			message: "Your RELIANCE-EQ BUY order 24052800001 was filled: 10 at an average price of 2450.60",
+		},
+		{
+			name: "rejected",
+			updates: []*OrderUpdate{
+				placed,
+				{OrderId: "24052800001", RejectReason: "insufficient margin", RejectedBy: "RMS"},
+			},
+			state:   OrderRejected,
+			events:  2,
+			message: "Your RELIANCE-EQ BUY order 24052800001 was rejected: insufficient margin",
+		},
+		{
+			name: "cancelled after a partial fill",
+			updates: []*OrderUpdate{
+				placed,
+				{OrderId: "24052800001", FilledShares: 4},
+				{OrderId: "24052800001", FilledShares: 4, CancelledQuantity: 6},
+			},
+			state:   OrderCancelled,
+			events:  3,
+			message: "Your RELIANCE-EQ BUY order 24052800001 was cancelled after 4 of 10 filled",
+		},
+		{
+			name: "stale and repeated updates are ignored",
+			updates: []*OrderUpdate{
+				placed,
+				{OrderId: "24052800001", FilledShares: 4},
+				{OrderId: "24052800001", FilledShares: 4},
+				placed,
+			},
+			state:   OrderPartiallyFilled,
+			events:  2,
+			message: "Your RELIANCE-EQ BUY order 24052800001 was partially filled: 4 of 10 at an average price of 0.00",
+		},
+		{
+			name: "terminal states are final",
+			updates: []*OrderUpdate{
+				placed,
+				{OrderId: "24052800001", FilledShares: 10},
+				{OrderId: "24052800001", FilledShares: 10, RejectReason: "late"},
+			},
+			state:  OrderFilled,
+			events: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewOrderTracker()
+			events, cancel := tracker.Watch()
+			defer cancel()
+			for _, u := range tt.updates {
+				tracker.Apply(u, time.Now())
+			}
+
+			o, ok := tracker.Get("24052800001")
+			require.True(t, ok)
+			assert.Equal(t, tt.state, o.State)
+			require.Len(t, events, tt.events)
+			var last OrderEvent
+			for range tt.events {
+				last = <-events
+			}
+			assert.Equal(t, tt.state, last.Order.State)
+			if tt.message != "" {
+				assert.Equal(t, tt.message, last.Message)
+			}
+		})
+	}
+}
+
+func TestOrderUpdateFills(t *testing.T) {
+	tracker := NewOrderTracker()
+	tracker.Apply(&OrderUpdate{OrderId: "1", Quantity: 10, FilledShares: 4, FillId: "7", FillQuantity: "4", FillPrice: "245050", AveragePrice: "245050"}, time.Now())
+	o, _ := tracker.Get("1")
+	assert.Equal(t, falcon.Rupees(2450.50), o.LastFillPrice)
+	assert.Equal(t, 4, o.LastFillQuantity)
+	assert.Equal(t, falcon.Rupees(2450.50), o.AveragePrice)
+	assert.Equal(t, []LiveOrder{o}, tracker.List())
+
+	_, changed := tracker.Apply(&OrderUpdate{}, time.Now())
+	assert.False(t, changed, "updates without an order ID are ignored")
+}
+
+func TestStreamAppliesOrderUpdates(t *testing.T) {
+	tracker := NewOrderTracker()
+	m := NewStreamManager(&stubService{}, WithOrderTracker(tracker), WithPriceStore(NewPriceStore()))
+	m.handleMessage(&Message{Data: &Message_OrderUpdate{OrderUpdate: &OrderUpdate{OrderId: "1", Quantity: 5, RejectReason: "insufficient margin"}}}, time.Now())
+	o, ok := tracker.Get("1")
+	require.True(t, ok)
+	assert.Equal(t, OrderRejected, o.State)
+}
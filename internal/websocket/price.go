@@ -3,121 +3,60 @@
 // This software is released under the MIT License.
 // https://opensource.org/licenses/MIT
 
+// Package websocket streams live prices from the Wealthy websocket feed.
 package websocket
 
 import (
-	"context"
-	"fmt"
-	sync "sync"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+	"google.golang.org/protobuf/proto"
 )
 
-var wealthyWebsocket *websocket.Conn = nil
-
-var priceStore sync.Map
-
-// Add global context and cancel function
-var (
-	msgCtx    context.Context
-	msgCancel context.CancelFunc
-)
-
-func Connect(ctx context.Context, url string) error {
-	// Cancel previous message processing goroutine if it exists
-
-	// Check if existing connection is still alive
-	if wealthyWebsocket != nil {
-		if err := wealthyWebsocket.WriteMessage(websocket.PingMessage, nil); err != nil {
-			// Connection is dead, set to nil so we can reconnect
-			wealthyWebsocket = nil
-			if msgCancel != nil {
-				msgCancel()
-				msgCancel = nil
-			}
-		} else {
-			return nil
-		}
-	}
-	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
-	if err != nil {
-		return fmt.Errorf("failed to connect to websocket: %w", err)
-	}
-	wealthyWebsocket = conn
-
-	// Create a new context for the new goroutine
-	msgCtx, msgCancel = context.WithCancel(ctx)
-	go processMessages(msgCtx)
-	return nil
+// subscriptionSymbol is the exchange|token form the feed subscribes to.
+func subscriptionSymbol(exchange int, token string) string {
+	return strings.ToUpper(falcon.ExchangeName(exchange)) + "|" + token
 }
 
-func SubscribePrice(ctx context.Context, token string) (any, error) {
-	msg := &PriceSubscriptionReq{
-		Operation: 1,
-		Mode:      1,
-		Symbol:    []string{token},
-	}
-	if wealthyWebsocket == nil {
-		return nil, fmt.Errorf("websocket connection not established")
-	}
-
-	if err := wealthyWebsocket.WriteJSON(msg); err != nil {
-		return nil, fmt.Errorf("failed to write to websocket: %w", err)
-	}
-	return nil, nil
-}
-func processMessages(ctx context.Context) {
-	messages, err := readMessages(ctx)
-	if err != nil {
-		return
-	}
-	for msg := range messages {
-		switch msg.Data.(type) {
-		case *Message_Feed:
-			fmt.Println(msg.Data)
-		}
-	}
+// received is a feed message with the time it arrived.
+type received struct {
+	msg *Message
+	at  time.Time
+	// replayed is set for messages read from a recording
+	replayed bool
 }
 
-func readMessages(ctx context.Context) (<-chan *Message, error) {
-	if wealthyWebsocket == nil {
-		return nil, fmt.Errorf("websocket connection not established")
-	}
-
-	messages := make(chan *Message)
-
+// readMessages decodes the binary protobuf frames of a connection until it
+// fails or is closed, and stores the error it stopped on in *err before the
+// channel is closed. Every frame extends the read deadline by timeout.
+func readMessages(c *websocket.Conn, timeout time.Duration, err *error) <-chan received {
+	messages := make(chan received)
 	go func() {
 		defer close(messages)
 		for {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				msg := &Message{}
-				err := wealthyWebsocket.ReadJSON(msg)
-				if err != nil {
-					if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-						// Log unexpected close errors
-						continue
-					}
-					// Connection is closed, exit goroutine
-					return
+			typ, data, readErr := c.ReadMessage()
+			if readErr != nil {
+				if !errors.Is(readErr, websocket.ErrCloseSent) && !websocket.IsCloseError(readErr, websocket.CloseNormalClosure) {
+					*err = readErr
 				}
-				messages <- msg
+				return
+			}
+			c.SetReadDeadline(time.Now().Add(timeout))
+			if typ != websocket.BinaryMessage {
+				slog.Debug("ignoring price feed text frame", "data", string(data))
+				continue
 			}
+			msg := &Message{}
+			if err := proto.Unmarshal(data, msg); err != nil {
+				slog.Warn("failed to decode price feed message", "error", err)
+				continue
+			}
+			messages <- received{msg: msg, at: time.Now()}
 		}
 	}()
-	return messages, nil
-}
-
-func GetLTP(ctx context.Context, token string) (any, error) {
-	price, ok := priceStore.Load(token)
-	if !ok {
-		return nil, fmt.Errorf("price not found")
-	}
-	return price, nil
-}
-
-func storePrice(ctx context.Context, token string, price any) {
-	priceStore.Store(token, price)
+	return messages
 }
@@ -0,0 +1,242 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// A recording is a sequence of records, each prefixed with its length as a
+// varint like protodelim writes them. A record is the protobuf message
+//
+//	message Record {
+//	  int64 received_at = 1;  // unix nanoseconds
+//	  Message message = 2;
+//	}
+//
+// which is encoded by hand, as it is not part of the feed's price.proto.
+const (
+	recordReceivedAt protowire.Number = 1
+	recordMessage    protowire.Number = 2
+
+	// maxRecordSize guards against reading a corrupt length prefix
+	maxRecordSize = 1 << 20
+)
+
+// Recorder writes the feed and order update messages of a stream to files of
+// length-delimited records, one file per day.
+type Recorder struct {
+	dir string
+
+	mu   sync.Mutex
+	day  string
+	file *os.File
+}
+
+// NewRecorder records to files in dir, which is created if needed.
+func NewRecorder(dir string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create recording dir: %w", err)
+	}
+	return &Recorder{dir: dir}, nil
+}
+
+// RecordingPath returns the file the messages received on the local date of
+// day are recorded to.
+func RecordingPath(dir string, day time.Time) string {
+	return filepath.Join(dir, "feed-"+day.Format(time.DateOnly)+".pb")
+}
+
+// Record appends a message with the time it was received. Error messages of
+// the feed are not recorded.
+func (r *Recorder) Record(msg *Message, at time.Time) error {
+	switch msg.Data.(type) {
+	case *Message_Feed, *Message_OrderUpdate:
+	default:
+		return nil
+	}
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode feed message: %w", err)
+	}
+	var rec []byte
+	rec = protowire.AppendTag(rec, recordReceivedAt, protowire.VarintType)
+	rec = protowire.AppendVarint(rec, uint64(at.UnixNano()))
+	rec = protowire.AppendTag(rec, recordMessage, protowire.BytesType)
+	rec = protowire.AppendBytes(rec, data)
+	framed := protowire.AppendVarint(make([]byte, 0, len(rec)+binary.MaxVarintLen64), uint64(len(rec)))
+	framed = append(framed, rec...)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.rotate(at.Local()); err != nil {
+		return err
+	}
+	if _, err := r.file.Write(framed); err != nil {
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+	return nil
+}
+
+// rotate opens the file of the day, closing the previous day's. Callers must
+// hold r.mu.
+func (r *Recorder) rotate(at time.Time) error {
+	day := at.Format(time.DateOnly)
+	if r.file != nil && r.day == day {
+		return nil
+	}
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+	f, err := os.OpenFile(RecordingPath(r.dir, at), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open recording: %w", err)
+	}
+	r.file, r.day = f, day
+	return nil
+}
+
+// Close closes the current file, the next record opens it again.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// RecordReader reads the records of a recording.
+type RecordReader struct {
+	r *bufio.Reader
+}
+
+func NewRecordReader(r io.Reader) *RecordReader {
+	return &RecordReader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next message and the time it was received. It returns
+// io.EOF after the last record.
+func (r *RecordReader) Next() (*Message, time.Time, error) {
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, time.Time{}, io.EOF
+		}
+		return nil, time.Time{}, fmt.Errorf("failed to read record: %w", err)
+	}
+	if size > maxRecordSize {
+		return nil, time.Time{}, fmt.Errorf("record of %d bytes is too large, the recording is corrupt", size)
+	}
+	rec := make([]byte, size)
+	if _, err := io.ReadFull(r.r, rec); err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to read record: %w", io.ErrUnexpectedEOF)
+	}
+
+	var at time.Time
+	msg := &Message{}
+	for len(rec) > 0 {
+		num, typ, n := protowire.ConsumeTag(rec)
+		if n < 0 {
+			return nil, time.Time{}, fmt.Errorf("invalid record: %w", protowire.ParseError(n))
+		}
+		rec = rec[n:]
+		switch {
+		case num == recordReceivedAt && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(rec)
+			if n < 0 {
+				return nil, time.Time{}, fmt.Errorf("invalid record time: %w", protowire.ParseError(n))
+			}
+			at, rec = time.Unix(0, int64(v)), rec[n:]
+		case num == recordMessage && typ == protowire.BytesType:
+			data, n := protowire.ConsumeBytes(rec)
+			if n < 0 {
+				return nil, time.Time{}, fmt.Errorf("invalid record message: %w", protowire.ParseError(n))
+			}
+			if err := proto.Unmarshal(data, msg); err != nil {
+				return nil, time.Time{}, fmt.Errorf("invalid record message: %w", err)
+			}
+			rec = rec[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, rec)
+			if n < 0 {
+				return nil, time.Time{}, fmt.Errorf("invalid record: %w", protowire.ParseError(n))
+			}
+			rec = rec[n:]
+		}
+	}
+	return msg, at, nil
+}
+
+// Replay feeds a recording through the message pipeline of the stream, as if
+// its messages arrived from the feed at the times they were recorded. speed
+// scales the pauses between messages: 1 replays in real time, 10 ten times
+// as fast and 0 without pauses. Replayed messages are not recorded, so a
+// recording of the stream can be replayed while it records.
+func (m *StreamManager) Replay(ctx context.Context, src *RecordReader, speed float64) error {
+	if speed < 0 {
+		return fmt.Errorf("invalid replay speed %v", speed)
+	}
+	messages := make(chan received)
+	var replayErr error
+	go func() {
+		defer close(messages)
+		var first time.Time
+		start := time.Now()
+		for {
+			msg, at, err := src.Next()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					replayErr = err
+				}
+				return
+			}
+			if first.IsZero() {
+				first = at
+			}
+			if speed > 0 {
+				due := start.Add(time.Duration(float64(at.Sub(first)) / speed))
+				if !sleep(ctx, time.Until(due)) {
+					replayErr = ctx.Err()
+					return
+				}
+			}
+			select {
+			case messages <- received{msg: msg, at: at, replayed: true}:
+			case <-ctx.Done():
+				replayErr = ctx.Err()
+				return
+			}
+		}
+	}()
+	m.processMessages(messages)
+	return replayErr
+}
+
+// ReplayFile replays the recording at path, see Replay.
+func (m *StreamManager) ReplayFile(ctx context.Context, path string, speed float64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer f.Close()
+	return m.Replay(ctx, NewRecordReader(f), speed)
+}
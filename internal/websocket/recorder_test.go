@@ -0,0 +1,121 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+	"google.golang.org/protobuf/proto"
+)
+
+func feedMessage(token uint32, ltp uint32) *Message {
+	return &Message{Data: &Message_Feed{Feed: &Feed{Exchange: 1, Token: token, Live: true, Ltpc: &LTPC{Ltp: ltp, Close: 240000}}}}
+}
+
+func TestRecorder(t *testing.T) {
+	dir := t.TempDir()
+	rec, err := NewRecorder(dir)
+	require.NoError(t, err)
+
+	day1 := time.Date(2025, 7, 1, 9, 15, 0, 123, time.Local)
+	day2 := day1.AddDate(0, 0, 1)
+	order := &Message{Data: &Message_OrderUpdate{OrderUpdate: &OrderUpdate{OrderId: "1", Quantity: 5, FilledShares: 5}}}
+	require.NoError(t, rec.Record(feedMessage(2885, 245050), day1))
+	require.NoError(t, rec.Record(order, day1.Add(time.Second)))
+	require.NoError(t, rec.Record(&Message{Data: &Message_Error{Error: &Error{Code: "1", Message: "bad"}}}, day1.Add(2*time.Second)))
+	require.NoError(t, rec.Record(feedMessage(2885, 246000), day2))
+	require.NoError(t, rec.Close())
+
+	f, err := os.Open(RecordingPath(dir, day1))
+	require.NoError(t, err)
+	defer f.Close()
+	r := NewRecordReader(f)
+	msg, at, err := r.Next()
+	require.NoError(t, err)
+	assert.True(t, day1.Equal(at), "the receive time is kept to the nanosecond")
+	assert.True(t, proto.Equal(feedMessage(2885, 245050), msg))
+	msg, _, err = r.Next()
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(order, msg))
+	_, _, err = r.Next()
+	assert.ErrorIs(t, err, io.EOF, "feed errors are not recorded")
+
+	data, err := os.ReadFile(RecordingPath(dir, day2))
+	require.NoError(t, err)
+	msg, at, err = NewRecordReader(bytes.NewReader(data)).Next()
+	require.NoError(t, err)
+	assert.True(t, day2.Equal(at))
+	assert.Equal(t, uint32(246000), msg.GetFeed().GetLtpc().GetLtp())
+
+	_, _, err = NewRecordReader(bytes.NewReader(data[:len(data)-1])).Next()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestReplay(t *testing.T) {
+	dir := t.TempDir()
+	rec, err := NewRecorder(dir)
+	require.NoError(t, err)
+	start := time.Date(2025, 7, 1, 9, 15, 0, 0, time.Local)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, rec.Record(feedMessage(2885, uint32(245000+i*100)), start.Add(time.Duration(i)*time.Second)))
+	}
+	require.NoError(t, rec.Record(&Message{Data: &Message_OrderUpdate{OrderUpdate: &OrderUpdate{OrderId: "7", Quantity: 5, FilledShares: 5}}}, start.Add(5*time.Second)))
+	require.NoError(t, rec.Close())
+	path := RecordingPath(dir, start)
+
+	t.Run("as fast as possible", func(t *testing.T) {
+		tracker, store := NewOrderTracker(), NewPriceStore()
+		m := NewStreamManager(&stubService{}, WithOrderTracker(tracker), WithPriceStore(store))
+		require.NoError(t, m.ReplayFile(context.Background(), path, 0))
+
+		tick, ok := store.Get(falcon.ExchangeNSE, "2885")
+		require.True(t, ok)
+		assert.Equal(t, falcon.Rupees(2454), tick.LastPrice)
+		assert.True(t, start.Add(4*time.Second).Equal(tick.UpdatedAt), "ticks carry the recorded time")
+		o, ok := tracker.Get("7")
+		require.True(t, ok)
+		assert.Equal(t, OrderFilled, o.State)
+	})
+
+	t.Run("paced", func(t *testing.T) {
+		m := NewStreamManager(&stubService{}, WithOrderTracker(NewOrderTracker()), WithPriceStore(NewPriceStore()))
+		began := time.Now()
+		require.NoError(t, m.ReplayFile(context.Background(), path, 100))
+		// 5 recorded seconds at 100 times the speed
+		assert.GreaterOrEqual(t, time.Since(began), 50*time.Millisecond)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		m := NewStreamManager(&stubService{}, WithOrderTracker(NewOrderTracker()), WithPriceStore(NewPriceStore()))
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, m.ReplayFile(ctx, path, 1), context.DeadlineExceeded)
+	})
+
+	t.Run("recording the stream", func(t *testing.T) {
+		// Replaying into the directory being recorded reads the file the
+		// recorder writes to
+		recording, err := os.ReadFile(path)
+		require.NoError(t, err)
+		rec, err := NewRecorder(dir)
+		require.NoError(t, err)
+		store := NewPriceStore()
+		m := NewStreamManager(&stubService{}, WithOrderTracker(NewOrderTracker()), WithPriceStore(store))
+		m.SetRecorder(rec)
+		require.NoError(t, m.ReplayFile(context.Background(), path, 0))
+		require.NoError(t, rec.Close())
+
+		tick, ok := store.Get(falcon.ExchangeNSE, "2885")
+		require.True(t, ok)
+		assert.Equal(t, falcon.Rupees(2454), tick.LastPrice)
+		got, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, recording, got, "replayed messages are not recorded again")
+	})
+}
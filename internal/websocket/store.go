@@ -0,0 +1,199 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+)
+
+// Tick is the latest market data of an instrument, merged from the LTPC, Full
+// and Extended feeds it is subscribed to. Prices are converted from paisa.
+type Tick struct {
+	Exchange          int          `json:"exchange_name"`
+	Token             string       `json:"token"`
+	LastPrice         falcon.Money `json:"ltp"`
+	Close             falcon.Money `json:"close"`
+	Open              falcon.Money `json:"open,omitempty"`
+	High              falcon.Money `json:"high,omitempty"`
+	Low               falcon.Money `json:"low,omitempty"`
+	AverageTradePrice falcon.Money `json:"average_trade_price,omitempty"`
+	UpperCircuit      falcon.Money `json:"upper_circuit,omitempty"`
+	LowerCircuit      falcon.Money `json:"lower_circuit,omitempty"`
+	Volume            int64        `json:"volume,omitempty"`
+	OpenInterest      int64        `json:"open_interest,omitempty"`
+	LastTradeQuantity int64        `json:"last_trade_quantity,omitempty"`
+	LastTradeTime     time.Time    `json:"last_trade_time,omitempty"`
+	ExchangeTime      time.Time    `json:"exchange_time,omitempty"`
+	Depth             *Depth       `json:"depth,omitempty"`
+	// Live is false for the snapshot sent on subscription outside market hours
+	Live      bool      `json:"live"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Change is the change of the last price from the previous close.
+func (t Tick) Change() falcon.Money {
+	return t.LastPrice - t.Close
+}
+
+// Depth is the order book of an instrument from the Extended feed.
+type Depth struct {
+	Buys              []DepthLevel `json:"buys"`
+	Sells             []DepthLevel `json:"sells"`
+	TotalBuyQuantity  int64        `json:"total_buy_quantity"`
+	TotalSellQuantity int64        `json:"total_sell_quantity"`
+}
+
+// DepthLevel is one price level of the order book.
+type DepthLevel struct {
+	Price    falcon.Money `json:"price"`
+	Quantity int64        `json:"quantity"`
+	Orders   int64        `json:"orders"`
+}
+
+// PriceStore keeps the latest tick of every instrument the feed delivers.
+type PriceStore struct {
+	mu    sync.RWMutex
+	ticks map[string]Tick
+	// changed is closed and replaced on every update
+	changed chan struct{}
+}
+
+// Prices is the store the websocket feed writes to.
+var Prices = NewPriceStore()
+
+func NewPriceStore() *PriceStore {
+	return &PriceStore{ticks: make(map[string]Tick), changed: make(chan struct{})}
+}
+
+func storeKey(exchange int, token string) string {
+	return fmt.Sprintf("%d:%s", exchange, token)
+}
+
+// Get returns the latest tick of an instrument.
+func (s *PriceStore) Get(exchange int, token string) (Tick, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.ticks[storeKey(exchange, token)]
+	return t, ok
+}
+
+// Wait returns the tick of an instrument, waiting for the first one until ctx
+// is done.
+func (s *PriceStore) Wait(ctx context.Context, exchange int, token string) (Tick, error) {
+	return s.Next(ctx, exchange, token, time.Time{})
+}
+
+// Next waits for a tick of an instrument updated after the given time, until
+// ctx is done.
+func (s *PriceStore) Next(ctx context.Context, exchange int, token string, after time.Time) (Tick, error) {
+	key := storeKey(exchange, token)
+	for {
+		s.mu.RLock()
+		t, ok := s.ticks[key]
+		changed := s.changed
+		s.mu.RUnlock()
+		if ok && t.UpdatedAt.After(after) {
+			return t, nil
+		}
+		select {
+		case <-ctx.Done():
+			return Tick{}, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Changed returns a channel that is closed by the next update of any
+// instrument.
+func (s *PriceStore) Changed() <-chan struct{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.changed
+}
+
+// Update merges a feed message into the tick of its instrument and returns
+// the updated tick.
+func (s *PriceStore) Update(feed *Feed, at time.Time) Tick {
+	exchange, token := int(feed.GetExchange()), fmt.Sprint(feed.GetToken())
+	key := storeKey(exchange, token)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.ticks[key]
+	t.Exchange, t.Token, t.Live, t.UpdatedAt = exchange, token, feed.GetLive(), at
+	if ltpc := feed.GetLtpc(); ltpc != nil {
+		t.LastPrice = paisa(ltpc.GetLtp())
+		t.Close = paisa(ltpc.GetClose())
+	}
+	if full := feed.GetFull(); full != nil {
+		t.Open = paisa(full.GetOpen())
+		t.High = paisa(full.GetHigh())
+		t.Low = paisa(full.GetLow())
+		if full.GetClose() != 0 {
+			t.Close = paisa(full.GetClose())
+		}
+		t.AverageTradePrice = paisa(full.GetAverageTradePrice())
+		t.UpperCircuit = paisa(full.GetUpperCircuit())
+		t.LowerCircuit = paisa(full.GetLowerCircuit())
+		t.Volume = int64(full.GetVolume())
+		t.OpenInterest = int64(full.GetOpenInterest())
+		t.LastTradeQuantity = int64(full.GetLastTradeQuantity())
+		t.LastTradeTime = unixTime(full.GetLastTradeTime())
+		t.ExchangeTime = unixTime(full.GetExchangeTimestamp())
+	}
+	if ext := feed.GetExtended(); ext != nil {
+		depth := &Depth{
+			TotalBuyQuantity:  int64(ext.GetTotalBuyQuantity()),
+			TotalSellQuantity: int64(ext.GetTotalSellQuantity()),
+		}
+		for _, b := range ext.GetMarketDepth().GetBuys() {
+			depth.Buys = append(depth.Buys, DepthLevel{Price: paisa(b.GetBidPrice()), Quantity: int64(b.GetBidQuantity()), Orders: int64(b.GetNoOfBidOrders())})
+		}
+		for _, a := range ext.GetMarketDepth().GetSells() {
+			depth.Sells = append(depth.Sells, DepthLevel{Price: paisa(a.GetAskPrice()), Quantity: int64(a.GetAskQuantity()), Orders: int64(a.GetNoOfAskOrders())})
+		}
+		t.Depth = depth
+	}
+	s.ticks[key] = t
+	close(s.changed)
+	s.changed = make(chan struct{})
+	return t
+}
+
+// delete forgets the tick of an instrument that is no longer streamed.
+func (s *PriceStore) delete(exchange int, token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.ticks, storeKey(exchange, token))
+}
+
+// dropDepth clears the market depth of an instrument no longer streamed in
+// the Extended mode, so it is not served stale.
+func (s *PriceStore) dropDepth(exchange int, token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := storeKey(exchange, token)
+	if t, ok := s.ticks[key]; ok {
+		t.Depth = nil
+		s.ticks[key] = t
+	}
+}
+
+func paisa(p uint32) falcon.Money {
+	return falcon.Paisa(int64(p))
+}
+
+func unixTime(sec uint32) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(sec), 0).UTC()
+}
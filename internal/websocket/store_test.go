@@ -0,0 +1,60 @@
+package websocket
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+)
+
+func TestPriceStoreUpdate(t *testing.T) {
+	store := NewPriceStore()
+	at := time.Date(2025, 7, 1, 9, 15, 0, 0, time.UTC)
+
+	store.Update(&Feed{Exchange: 1, Token: 2885, Live: true, Ltpc: &LTPC{Ltp: 245050, Close: 240000}}, at)
+	store.Update(&Feed{Exchange: 1, Token: 2885, Live: true,
+		Full: &Full{Open: 241000, High: 246000, Low: 240500, Volume: 12000, LastTradeTime: 1751361300},
+		Extended: &Extended{
+			MarketDepth: &MarketDepth{
+				Buys:  []*MarketDepth_Buy{{BidPrice: 245000, BidQuantity: 10, NoOfBidOrders: 2}},
+				Sells: []*MarketDepth_Sell{{AskPrice: 245100, AskQuantity: 5, NoOfAskOrders: 1}},
+			},
+			TotalBuyQuantity:  1000,
+			TotalSellQuantity: 800,
+		},
+	}, at)
+
+	tick, ok := store.Get(falcon.ExchangeNSE, "2885")
+	require.True(t, ok)
+	assert.Equal(t, falcon.Rupees(2450.50), tick.LastPrice)
+	assert.Equal(t, falcon.Rupees(2400), tick.Close, "a Full feed without close keeps the LTPC close")
+	assert.Equal(t, falcon.Rupees(50.50), tick.Change())
+	assert.Equal(t, falcon.Rupees(2460), tick.High)
+	assert.Equal(t, int64(12000), tick.Volume)
+	assert.Equal(t, time.Unix(1751361300, 0).UTC(), tick.LastTradeTime)
+	require.NotNil(t, tick.Depth)
+	assert.Equal(t, []DepthLevel{{Price: falcon.Rupees(2450), Quantity: 10, Orders: 2}}, tick.Depth.Buys)
+	assert.Equal(t, int64(800), tick.Depth.TotalSellQuantity)
+
+	_, ok = store.Get(falcon.ExchangeBSE, "2885")
+	assert.False(t, ok, "ticks are kept per exchange")
+}
+
+func TestPriceStoreWait(t *testing.T) {
+	store := NewPriceStore()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		store.Update(&Feed{Exchange: 1, Token: 11536, Ltpc: &LTPC{Ltp: 100}}, time.Now())
+	}()
+	tick, err := store.Wait(context.Background(), falcon.ExchangeNSE, "11536")
+	require.NoError(t, err)
+	assert.Equal(t, falcon.Paisa(100), tick.LastPrice)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = store.Wait(ctx, falcon.ExchangeNSE, "1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,454 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package websocket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// StreamState is a stage of the feed connection.
+type StreamState int
+
+const (
+	STREAM_DISCONNECTED StreamState = iota
+	STREAM_CONNECTING
+	STREAM_CONNECTED
+	STREAM_STOPPED
+)
+
+func (s StreamState) String() string {
+	switch s {
+	case STREAM_DISCONNECTED:
+		return "disconnected"
+	case STREAM_CONNECTING:
+		return "connecting"
+	case STREAM_CONNECTED:
+		return "connected"
+	case STREAM_STOPPED:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
+
+var ErrStreamStopped = errors.New("price stream is stopped")
+
+// StreamEvent is published to watchers on every state transition.
+type StreamEvent struct {
+	State StreamState
+	Err   error
+	At    time.Time
+}
+
+// URLSource hands out the feed URL, FalconService does so for the current
+// login. It is asked again before every connection attempt, so an expired
+// URL token is refreshed on reconnect.
+type URLSource interface {
+	GetWebsocketURL(ctx context.Context) (string, error)
+}
+
+const (
+	defaultHeartbeat  = 15 * time.Second
+	defaultMinBackoff = time.Second
+	defaultMaxBackoff = time.Minute
+)
+
+// StreamOption configures a StreamManager.
+type StreamOption func(*StreamManager)
+
+// WithHeartbeat sets how often the connection is pinged. A connection that
+// stays silent for two intervals is considered dead and reconnected.
+func WithHeartbeat(d time.Duration) StreamOption {
+	return func(m *StreamManager) {
+		m.heartbeat = d
+	}
+}
+
+// WithBackoff sets the first and the longest wait between reconnect attempts.
+func WithBackoff(min, max time.Duration) StreamOption {
+	return func(m *StreamManager) {
+		m.minBackoff, m.maxBackoff = min, max
+	}
+}
+
+// WithCandleBuilder sets the builder ticks are aggregated by, Candles by
+// default.
+func WithCandleBuilder(builder *CandleBuilder) StreamOption {
+	return func(m *StreamManager) {
+		m.candles = builder
+	}
+}
+
+// WithOrderTracker sets the tracker order updates are applied to, Orders by
+// default.
+func WithOrderTracker(tracker *OrderTracker) StreamOption {
+	return func(m *StreamManager) {
+		m.orders = tracker
+	}
+}
+
+// WithPriceStore sets the store feed messages are written to, Prices by
+// default.
+func WithPriceStore(store *PriceStore) StreamOption {
+	return func(m *StreamManager) {
+		m.prices = store
+	}
+}
+
+// StreamManager owns the feed connection, which carries the prices of the
+// subscribed instruments and the updates of the user's orders. It reconnects
+// with exponential backoff when the connection drops or stops answering
+// pings, and subscribes the active instruments again once reconnected.
+type StreamManager struct {
+	src        URLSource
+	dialer     *websocket.Dialer
+	heartbeat  time.Duration
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	prices     *PriceStore
+	orders     *OrderTracker
+	candles    *CandleBuilder
+	recorder   atomic.Pointer[Recorder]
+
+	mu    sync.Mutex
+	state StreamState
+	err   error
+	// conn is the open connection, writes are serialized by mu
+	conn    *websocket.Conn
+	cancel  context.CancelFunc
+	done    chan struct{}
+	subs    map[string]*entry
+	watches map[int]chan StreamEvent
+	nextID  int
+	// connected is closed and replaced whenever the stream connects
+	connected chan struct{}
+}
+
+// NewStreamManager creates a stopped StreamManager. Start connects it.
+func NewStreamManager(src URLSource, opts ...StreamOption) *StreamManager {
+	m := &StreamManager{
+		src:        src,
+		dialer:     websocket.DefaultDialer,
+		heartbeat:  defaultHeartbeat,
+		minBackoff: defaultMinBackoff,
+		maxBackoff: defaultMaxBackoff,
+		prices:     Prices,
+		orders:     Orders,
+		candles:    Candles,
+		state:      STREAM_STOPPED,
+		subs:       make(map[string]*entry),
+		watches:    make(map[int]chan StreamEvent),
+		connected:  make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Prices returns the store the stream writes to.
+func (m *StreamManager) Prices() *PriceStore {
+	return m.prices
+}
+
+// Candles returns the builder the stream aggregates ticks with.
+func (m *StreamManager) Candles() *CandleBuilder {
+	return m.candles
+}
+
+// SetRecorder records every message the stream receives with rec from now
+// on, nil stops recording.
+func (m *StreamManager) SetRecorder(rec *Recorder) {
+	m.recorder.Store(rec)
+}
+
+// Start connects the stream in the background, it is a no-op when the stream
+// is running.
+func (m *StreamManager) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.done = make(chan struct{})
+	m.transition(STREAM_CONNECTING, nil)
+	go m.run(ctx, m.done)
+}
+
+// Stop closes the connection and stops reconnecting. The subscriptions are
+// kept for the next Start.
+func (m *StreamManager) Stop() {
+	m.mu.Lock()
+	cancel, done := m.cancel, m.done
+	m.cancel = nil
+	if m.conn != nil {
+		m.conn.Close()
+	}
+	m.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+	m.setState(STREAM_STOPPED, nil)
+}
+
+// State returns the current state and the error of the last failed attempt.
+func (m *StreamManager) State() (StreamState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state, m.err
+}
+
+// WaitConnected blocks until the stream is connected or ctx is done.
+func (m *StreamManager) WaitConnected(ctx context.Context) error {
+	m.mu.Lock()
+	state, connected := m.state, m.connected
+	m.mu.Unlock()
+	if state == STREAM_CONNECTED {
+		return nil
+	}
+	if state == STREAM_STOPPED {
+		return ErrStreamStopped
+	}
+	select {
+	case <-connected:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Watch returns a channel receiving every subsequent state transition and a
+// function to cancel the watch. Slow watchers miss events rather than
+// blocking the stream.
+func (m *StreamManager) Watch() (<-chan StreamEvent, func()) {
+	ch := make(chan StreamEvent, 8)
+	m.mu.Lock()
+	id := m.nextID
+	m.nextID++
+	m.watches[id] = ch
+	m.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			m.mu.Lock()
+			delete(m.watches, id)
+			m.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// GetLTP returns the live tick of an instrument, waiting for the first one
+// after a subscription until ctx is done.
+func (m *StreamManager) GetLTP(ctx context.Context, exchange int, token string) (Tick, error) {
+	tick, err := m.prices.Wait(ctx, exchange, token)
+	if err != nil {
+		return Tick{}, fmt.Errorf("no live price for %s yet: %w", subscriptionSymbol(exchange, token), err)
+	}
+	return tick, nil
+}
+
+// send writes a subscription request when connected. Callers must hold m.mu.
+func (m *StreamManager) send(operation, mode int, instruments []Instrument) error {
+	if m.conn == nil {
+		return nil
+	}
+	req := &PriceSubscriptionReq{Operation: operation, Mode: mode}
+	for _, instr := range instruments {
+		req.Symbol = append(req.Symbol, subscriptionSymbol(instr.Exchange, instr.Token))
+	}
+	if err := m.conn.WriteJSON(req); err != nil {
+		return fmt.Errorf("failed to write to websocket: %w", err)
+	}
+	return nil
+}
+
+// resubscribe sends the active subscriptions, one request per mode. Callers
+// must hold m.mu.
+func (m *StreamManager) resubscribe() error {
+	for mode, instruments := range m.active() {
+		if err := m.send(OperationSubscribe, mode, instruments); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run connects and serves the stream until ctx is done, backing off between
+// failed attempts.
+func (m *StreamManager) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	backoff := m.minBackoff
+	for ctx.Err() == nil {
+		m.setState(STREAM_CONNECTING, nil)
+		conn, err := m.connect(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Warn("price stream connection failed", "error", err, "retry_in", backoff)
+			m.setState(STREAM_DISCONNECTED, err)
+			if !sleep(ctx, jitter(backoff)) {
+				return
+			}
+			backoff = min(backoff*2, m.maxBackoff)
+			continue
+		}
+		backoff = m.minBackoff
+		err = m.serve(ctx, conn)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Warn("price stream disconnected, reconnecting", "error", err)
+		m.setState(STREAM_DISCONNECTED, err)
+	}
+}
+
+// connect dials a fresh URL and replays the subscriptions.
+func (m *StreamManager) connect(ctx context.Context) (*websocket.Conn, error) {
+	url, err := m.src.GetWebsocketURL(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn, _, err := m.dialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to websocket: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ctx.Err() != nil {
+		conn.Close()
+		return nil, ctx.Err()
+	}
+	m.conn = conn
+	if err := m.resubscribe(); err != nil {
+		m.conn = nil
+		conn.Close()
+		return nil, err
+	}
+	m.transition(STREAM_CONNECTED, nil)
+	return conn, nil
+}
+
+// serve reads the connection and pings it until it fails.
+func (m *StreamManager) serve(ctx context.Context, conn *websocket.Conn) error {
+	deadline := 2 * m.heartbeat
+	conn.SetReadDeadline(time.Now().Add(deadline))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(deadline))
+	})
+
+	stopPing := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(m.heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopPing:
+				return
+			case <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(m.heartbeat)); err != nil {
+					conn.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	var readErr error
+	m.processMessages(readMessages(conn, deadline, &readErr))
+	close(stopPing)
+
+	m.mu.Lock()
+	if m.conn == conn {
+		m.conn = nil
+	}
+	m.mu.Unlock()
+	conn.Close()
+	return readErr
+}
+
+// processMessages records and applies decoded feed messages until the
+// channel closes. Replayed messages are not recorded again, they would be
+// appended to the recording they come from.
+func (m *StreamManager) processMessages(messages <-chan received) {
+	for r := range messages {
+		if rec := m.recorder.Load(); rec != nil && !r.replayed {
+			if err := rec.Record(r.msg, r.at); err != nil {
+				slog.Warn("failed to record feed message", "error", err)
+			}
+		}
+		m.handleMessage(r.msg, r.at)
+	}
+}
+
+func (m *StreamManager) handleMessage(msg *Message, at time.Time) {
+	switch data := msg.Data.(type) {
+	case *Message_Feed:
+		m.candles.Add(m.prices.Update(data.Feed, at))
+	case *Message_OrderUpdate:
+		m.orders.Apply(data.OrderUpdate, at)
+	case *Message_Error:
+		slog.Warn("price feed error", "code", data.Error.GetCode(), "message", data.Error.GetMessage())
+	}
+}
+
+func (m *StreamManager) setState(state StreamState, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.transition(state, err)
+}
+
+// transition records a state and publishes it. Callers must hold m.mu.
+func (m *StreamManager) transition(state StreamState, err error) {
+	if m.state == state && err == nil {
+		return
+	}
+	m.state, m.err = state, err
+	if state == STREAM_CONNECTED {
+		close(m.connected)
+		m.connected = make(chan struct{})
+	}
+	event := StreamEvent{State: state, Err: err, At: time.Now()}
+	for _, ch := range m.watches {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// sleep waits for d, it returns false when ctx is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// jitter spreads reconnects of many clients by up to a fifth of d.
+func jitter(d time.Duration) time.Duration {
+	return d + time.Duration(rand.Int64N(int64(d)/5+1))
+}
@@ -0,0 +1,180 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+	"google.golang.org/protobuf/proto"
+)
+
+// stubService hands out the URL of a test feed, failing the first fail calls.
+type stubService struct {
+	falcon.FalconService
+	url   string
+	fail  int32
+	calls atomic.Int32
+}
+
+func (s *stubService) GetWebsocketURL(ctx context.Context) (string, error) {
+	if s.calls.Add(1) <= s.fail {
+		return "", errors.New("token expired")
+	}
+	return s.url, nil
+}
+
+// feedServer runs a test feed, handle serves the n-th connection from 1.
+func feedServer(t *testing.T, handle func(n int, c *websocket.Conn)) *stubService {
+	var conns atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer c.Close()
+		handle(int(conns.Add(1)), c)
+	}))
+	t.Cleanup(server.Close)
+	return &stubService{url: "ws" + strings.TrimPrefix(server.URL, "http")}
+}
+
+func sendFeed(t *testing.T, c *websocket.Conn, feed *Feed) {
+	data, err := proto.Marshal(&Message{Data: &Message_Feed{Feed: feed}})
+	require.NoError(t, err)
+	require.NoError(t, c.WriteMessage(websocket.BinaryMessage, data))
+}
+
+func waitState(t *testing.T, events <-chan StreamEvent, want StreamState) StreamEvent {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case e := <-events:
+			if e.State == want {
+				return e
+			}
+		case <-timeout:
+			t.Fatalf("stream did not reach state %s", want)
+		}
+	}
+}
+
+func TestStreamPrices(t *testing.T) {
+	subscriptions := make(chan PriceSubscriptionReq, 1)
+	svc := feedServer(t, func(n int, c *websocket.Conn) {
+		var req PriceSubscriptionReq
+		require.NoError(t, c.ReadJSON(&req))
+		subscriptions <- req
+		sendFeed(t, c, &Feed{Exchange: 1, Token: 2885, Live: true, Ltpc: &LTPC{Ltp: 245050}})
+		c.ReadMessage()
+	})
+
+	m := NewStreamManager(svc, WithPriceStore(NewPriceStore()))
+	defer m.Stop()
+	m.Start()
+	m.Start()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, m.WaitConnected(ctx))
+	_, err := m.Subscribe(ModeFull, Instrument{Exchange: falcon.ExchangeNSE, Token: "2885"})
+	require.NoError(t, err)
+	assert.Equal(t, PriceSubscriptionReq{Operation: OperationSubscribe, Mode: ModeFull, Symbol: []string{"NSE|2885"}}, <-subscriptions)
+
+	tick, err := m.GetLTP(ctx, falcon.ExchangeNSE, "2885")
+	require.NoError(t, err)
+	assert.Equal(t, falcon.Rupees(2450.50), tick.LastPrice)
+	assert.True(t, tick.Live)
+	assert.Equal(t, int32(1), svc.calls.Load(), "starting twice runs one stream")
+}
+
+func TestStreamReconnects(t *testing.T) {
+	var mu sync.Mutex
+	var replayed []PriceSubscriptionReq
+	svc := feedServer(t, func(n int, c *websocket.Conn) {
+		var req PriceSubscriptionReq
+		require.NoError(t, c.ReadJSON(&req))
+		if n == 1 {
+			// drop the first connection once subscribed
+			return
+		}
+		mu.Lock()
+		replayed = append(replayed, req)
+		mu.Unlock()
+		sendFeed(t, c, &Feed{Exchange: 1, Token: 2885, Live: true, Ltpc: &LTPC{Ltp: 100}})
+		c.ReadMessage()
+	})
+	svc.fail = 1
+
+	m := NewStreamManager(svc, WithPriceStore(NewPriceStore()), WithBackoff(10*time.Millisecond, 20*time.Millisecond))
+	events, cancelWatch := m.Watch()
+	defer cancelWatch()
+	_, err := m.Subscribe(ModeLTPC, Instrument{Exchange: falcon.ExchangeNSE, Token: "2885"})
+	require.NoError(t, err)
+	m.Start()
+	defer m.Stop()
+
+	failed := waitState(t, events, STREAM_DISCONNECTED)
+	assert.ErrorContains(t, failed.Err, "token expired")
+	waitState(t, events, STREAM_CONNECTED)
+	dropped := waitState(t, events, STREAM_DISCONNECTED)
+	assert.Error(t, dropped.Err)
+	waitState(t, events, STREAM_CONNECTED)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	tick, err := m.GetLTP(ctx, falcon.ExchangeNSE, "2885")
+	require.NoError(t, err)
+	assert.Equal(t, falcon.Rupees(1), tick.LastPrice)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []PriceSubscriptionReq{{Operation: OperationSubscribe, Mode: ModeLTPC, Symbol: []string{"NSE|2885"}}}, replayed)
+	assert.Equal(t, int32(3), svc.calls.Load(), "every attempt fetches a fresh URL")
+}
+
+func TestStreamHeartbeat(t *testing.T) {
+	svc := feedServer(t, func(n int, c *websocket.Conn) {
+		if n == 1 {
+			// never read, so pings are not answered
+			time.Sleep(time.Second)
+			return
+		}
+		c.ReadMessage()
+	})
+
+	m := NewStreamManager(svc, WithHeartbeat(20*time.Millisecond), WithBackoff(10*time.Millisecond, 10*time.Millisecond))
+	events, cancelWatch := m.Watch()
+	defer cancelWatch()
+	m.Start()
+	defer m.Stop()
+
+	waitState(t, events, STREAM_CONNECTED)
+	dropped := waitState(t, events, STREAM_DISCONNECTED)
+	assert.Error(t, dropped.Err)
+	waitState(t, events, STREAM_CONNECTED)
+}
+
+func TestStreamStop(t *testing.T) {
+	svc := feedServer(t, func(n int, c *websocket.Conn) {
+		c.ReadMessage()
+	})
+
+	m := NewStreamManager(svc)
+	ctx := context.Background()
+	assert.ErrorIs(t, m.WaitConnected(ctx), ErrStreamStopped)
+	m.Start()
+	require.NoError(t, m.WaitConnected(ctx))
+	m.Stop()
+	state, err := m.State()
+	assert.Equal(t, STREAM_STOPPED, state)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, m.WaitConnected(ctx), ErrStreamStopped)
+}
@@ -0,0 +1,202 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package websocket
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+)
+
+// Instrument identifies an instrument of the feed.
+type Instrument struct {
+	Exchange int    `json:"exchange_name"`
+	Token    string `json:"token"`
+}
+
+func (i Instrument) key() string {
+	return storeKey(i.Exchange, i.Token)
+}
+
+// Subscription is the hold of one consumer on a set of instruments. The
+// instruments stay subscribed until every consumer holding them has closed
+// its subscription.
+type Subscription struct {
+	m           *StreamManager
+	mode        int
+	instruments []Instrument
+	once        sync.Once
+}
+
+// Mode returns the mode the subscription asked for.
+func (s *Subscription) Mode() int {
+	return s.mode
+}
+
+// Instruments returns the instruments the subscription holds.
+func (s *Subscription) Instruments() []Instrument {
+	return s.instruments
+}
+
+// Close releases the instruments of the subscription. Instruments nobody else
+// holds are unsubscribed, the others drop to the highest mode still held.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.m.release(s.mode, s.instruments)
+	})
+}
+
+// entry counts the consumers of an instrument per mode.
+type entry struct {
+	instrument Instrument
+	refs       map[int]int
+	// mode is the mode the feed streams the instrument in
+	mode int
+}
+
+// wanted is the highest mode any consumer holds, 0 when nobody does.
+func (e *entry) wanted() int {
+	mode := 0
+	for m, n := range e.refs {
+		if n > 0 && m > mode {
+			mode = m
+		}
+	}
+	return mode
+}
+
+// change is a subscription request still to be sent.
+type change struct {
+	operation int
+	mode      int
+}
+
+// batch collects the requests of one registry update, so instruments that
+// change the same way are sent in one request.
+type batch map[change][]Instrument
+
+func (b batch) add(operation, mode int, instr Instrument) {
+	c := change{operation: operation, mode: mode}
+	b[c] = append(b[c], instr)
+}
+
+// changes returns the requests in a stable order, unsubscriptions first.
+func (b batch) changes() []change {
+	changes := make([]change, 0, len(b))
+	for c := range b {
+		changes = append(changes, c)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].operation != changes[j].operation {
+			return changes[i].operation > changes[j].operation
+		}
+		return changes[i].mode < changes[j].mode
+	})
+	return changes
+}
+
+// Subscribe holds instruments in one of the Mode* modes. An instrument already
+// streamed in a lower mode is upgraded, and all instruments that need a
+// request are sent in one batch. The subscription is sent again on every
+// reconnect until it is closed.
+func (m *StreamManager) Subscribe(mode int, instruments ...Instrument) (*Subscription, error) {
+	if mode < ModeLTPC || mode > ModeExtended {
+		return nil, fmt.Errorf("invalid subscription mode %d", mode)
+	}
+	if len(instruments) == 0 {
+		return nil, errors.New("nothing to subscribe to")
+	}
+	for _, instr := range instruments {
+		if instr.Token == "" {
+			return nil, errors.New("instrument token is required")
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b := batch{}
+	for _, instr := range instruments {
+		e, ok := m.subs[instr.key()]
+		if !ok {
+			e = &entry{instrument: instr, refs: make(map[int]int)}
+			m.subs[instr.key()] = e
+		}
+		e.refs[mode]++
+		if mode > e.mode {
+			e.mode = mode
+			b.add(OperationSubscribe, mode, instr)
+		}
+	}
+	m.flush(b)
+	return &Subscription{m: m, mode: mode, instruments: append([]Instrument(nil), instruments...)}, nil
+}
+
+// release drops a consumer's hold on instruments.
+func (m *StreamManager) release(mode int, instruments []Instrument) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b := batch{}
+	for _, instr := range instruments {
+		e, ok := m.subs[instr.key()]
+		if !ok || e.refs[mode] == 0 {
+			continue
+		}
+		e.refs[mode]--
+		wanted := e.wanted()
+		if wanted == e.mode {
+			continue
+		}
+		// the feed has no downgrade, the instrument is subscribed again
+		b.add(OperationUnsubscribe, e.mode, instr)
+		if wanted == 0 {
+			delete(m.subs, instr.key())
+			m.prices.delete(instr.Exchange, instr.Token)
+			continue
+		}
+		b.add(OperationSubscribe, wanted, instr)
+		if e.mode == ModeExtended {
+			m.prices.dropDepth(instr.Exchange, instr.Token)
+		}
+		e.mode = wanted
+	}
+	m.flush(b)
+}
+
+// Subscriptions returns the instruments the feed streams by mode.
+func (m *StreamManager) Subscriptions() map[int][]Instrument {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.active()
+}
+
+// active groups the subscribed instruments by mode. Callers must hold m.mu.
+func (m *StreamManager) active() map[int][]Instrument {
+	byMode := make(map[int][]Instrument)
+	for _, e := range m.subs {
+		byMode[e.mode] = append(byMode[e.mode], e.instrument)
+	}
+	for _, instruments := range byMode {
+		sort.Slice(instruments, func(i, j int) bool {
+			return instruments[i].key() < instruments[j].key()
+		})
+	}
+	return byMode
+}
+
+// flush sends a batch when connected. The registry is already updated, so a
+// failed write drops the connection and the reconnect replays it. Callers
+// must hold m.mu.
+func (m *StreamManager) flush(b batch) {
+	for _, c := range b.changes() {
+		if err := m.send(c.operation, c.mode, b[c]); err != nil {
+			slog.Warn("price subscription failed, retrying after reconnect", "error", err)
+			m.conn.Close()
+			return
+		}
+	}
+}
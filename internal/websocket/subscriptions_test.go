@@ -0,0 +1,109 @@
+package websocket
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+)
+
+// connectedStream starts a stream against a test feed that forwards every
+// request it reads.
+func connectedStream(t *testing.T) (*StreamManager, <-chan PriceSubscriptionReq) {
+	requests := make(chan PriceSubscriptionReq, 16)
+	svc := feedServer(t, func(n int, c *websocket.Conn) {
+		for {
+			var req PriceSubscriptionReq
+			if err := c.ReadJSON(&req); err != nil {
+				return
+			}
+			requests <- req
+		}
+	})
+	m := NewStreamManager(svc, WithPriceStore(NewPriceStore()))
+	m.Start()
+	t.Cleanup(m.Stop)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, m.WaitConnected(ctx))
+	return m, requests
+}
+
+func nextRequest(t *testing.T, requests <-chan PriceSubscriptionReq) PriceSubscriptionReq {
+	t.Helper()
+	select {
+	case req := <-requests:
+		return req
+	case <-time.After(2 * time.Second):
+		t.Fatal("no subscription request sent")
+		return PriceSubscriptionReq{}
+	}
+}
+
+func TestSubscriptionRefCounts(t *testing.T) {
+	m, requests := connectedStream(t)
+	infy := Instrument{Exchange: falcon.ExchangeNSE, Token: "1594"}
+	tcs := Instrument{Exchange: falcon.ExchangeNSE, Token: "11536"}
+
+	first, err := m.Subscribe(ModeLTPC, infy, tcs)
+	require.NoError(t, err)
+	assert.Equal(t, PriceSubscriptionReq{Operation: OperationSubscribe, Mode: ModeLTPC, Symbol: []string{"NSE|1594", "NSE|11536"}}, nextRequest(t, requests), "instruments are batched")
+
+	second, err := m.Subscribe(ModeLTPC, infy)
+	require.NoError(t, err)
+	first.Close()
+	first.Close()
+	assert.Equal(t, PriceSubscriptionReq{Operation: OperationUnsubscribe, Mode: ModeLTPC, Symbol: []string{"NSE|11536"}}, nextRequest(t, requests), "an instrument still held stays subscribed")
+	assert.Equal(t, map[int][]Instrument{ModeLTPC: {infy}}, m.Subscriptions())
+
+	second.Close()
+	assert.Equal(t, PriceSubscriptionReq{Operation: OperationUnsubscribe, Mode: ModeLTPC, Symbol: []string{"NSE|1594"}}, nextRequest(t, requests))
+	assert.Empty(t, m.Subscriptions())
+}
+
+func TestSubscriptionModes(t *testing.T) {
+	m, requests := connectedStream(t)
+	infy := Instrument{Exchange: falcon.ExchangeNSE, Token: "1594"}
+
+	ltpc, err := m.Subscribe(ModeLTPC, infy)
+	require.NoError(t, err)
+	nextRequest(t, requests)
+
+	depth, err := m.Subscribe(ModeExtended, infy)
+	require.NoError(t, err)
+	assert.Equal(t, PriceSubscriptionReq{Operation: OperationSubscribe, Mode: ModeExtended, Symbol: []string{"NSE|1594"}}, nextRequest(t, requests), "a higher mode upgrades")
+
+	full, err := m.Subscribe(ModeFull, infy)
+	require.NoError(t, err)
+	m.prices.Update(&Feed{Exchange: 1, Token: 1594, Extended: &Extended{TotalBuyQuantity: 10}}, time.Now())
+
+	depth.Close()
+	assert.Equal(t, PriceSubscriptionReq{Operation: OperationUnsubscribe, Mode: ModeExtended, Symbol: []string{"NSE|1594"}}, nextRequest(t, requests))
+	assert.Equal(t, PriceSubscriptionReq{Operation: OperationSubscribe, Mode: ModeFull, Symbol: []string{"NSE|1594"}}, nextRequest(t, requests), "the highest mode still held remains")
+	tick, ok := m.prices.Get(falcon.ExchangeNSE, "1594")
+	require.True(t, ok)
+	assert.Nil(t, tick.Depth, "depth is not served stale after a downgrade")
+
+	full.Close()
+	assert.Equal(t, PriceSubscriptionReq{Operation: OperationUnsubscribe, Mode: ModeFull, Symbol: []string{"NSE|1594"}}, nextRequest(t, requests))
+	assert.Equal(t, PriceSubscriptionReq{Operation: OperationSubscribe, Mode: ModeLTPC, Symbol: []string{"NSE|1594"}}, nextRequest(t, requests))
+
+	ltpc.Close()
+	nextRequest(t, requests)
+	_, ok = m.prices.Get(falcon.ExchangeNSE, "1594")
+	assert.False(t, ok, "the tick of an unsubscribed instrument is forgotten")
+}
+
+func TestSubscribeValidates(t *testing.T) {
+	m := NewStreamManager(&stubService{})
+	_, err := m.Subscribe(4, Instrument{Exchange: falcon.ExchangeNSE, Token: "1594"})
+	assert.ErrorContains(t, err, "invalid subscription mode")
+	_, err = m.Subscribe(ModeLTPC)
+	assert.Error(t, err)
+	_, err = m.Subscribe(ModeLTPC, Instrument{Exchange: falcon.ExchangeNSE})
+	assert.ErrorContains(t, err, "token is required")
+}
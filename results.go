@@ -0,0 +1,189 @@
+package mcp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// resultTTL is how long a large result can be paged through after the call
+	resultTTL = 15 * time.Minute
+	// maxStoredResults bounds the memory held by large results
+	maxStoredResults = 32
+)
+
+// ErrResultExpired is returned by FetchMore for unknown or expired handles.
+var ErrResultExpired = errors.New("result handle not found or expired, call the tool again")
+
+// ResultChunk is one page of a tool result too large for a single response.
+// The client passes ResultHandle and NextCursor to the fetch_more tool to get
+// the following page until NextCursor is empty. Concatenating Data of all
+// chunks gives the complete JSON result.
+type ResultChunk struct {
+	ResultHandle string `json:"result_handle"`
+	ChunkIndex   int    `json:"chunk_index"`
+	TotalChunks  int    `json:"total_chunks"`
+	NextCursor   string `json:"next_cursor,omitempty"`
+	Data         string `json:"data"`
+}
+
+type storedResult struct {
+	payload   []byte
+	bounds    []int
+	expiresAt time.Time
+}
+
+// resultStore keeps large tool results for paging.
+type resultStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	max     int
+	results map[string]*storedResult
+}
+
+func newResultStore(ttl time.Duration, max int) *resultStore {
+	return &resultStore{ttl: ttl, max: max, results: make(map[string]*storedResult)}
+}
+
+var results = newResultStore(resultTTL, maxStoredResults)
+
+// put stores payload split into chunks of at most size bytes and returns its
+// handle.
+func (s *resultStore) put(payload []byte, size int) (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate result handle: %w", err)
+	}
+	handle := hex.EncodeToString(b)
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked(now)
+	if len(s.results) >= s.max {
+		s.evictOldestLocked()
+	}
+	s.results[handle] = &storedResult{
+		payload:   payload,
+		bounds:    chunkBounds(payload, size),
+		expiresAt: now.Add(s.ttl),
+	}
+	return handle, nil
+}
+
+// chunk returns the chunk at index of the result stored under handle.
+func (s *resultStore) chunk(handle string, index int) (ResultChunk, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked(time.Now())
+
+	r, ok := s.results[handle]
+	if !ok {
+		return ResultChunk{}, ErrResultExpired
+	}
+	total := len(r.bounds) - 1
+	if index < 0 || index >= total {
+		return ResultChunk{}, fmt.Errorf("cursor out of range, result has %d chunks", total)
+	}
+
+	c := ResultChunk{
+		ResultHandle: handle,
+		ChunkIndex:   index,
+		TotalChunks:  total,
+		Data:         string(r.payload[r.bounds[index]:r.bounds[index+1]]),
+	}
+	if index+1 < total {
+		c.NextCursor = strconv.Itoa(index + 1)
+	}
+	return c, nil
+}
+
+func (s *resultStore) pruneLocked(now time.Time) {
+	for handle, r := range s.results {
+		if now.After(r.expiresAt) {
+			delete(s.results, handle)
+		}
+	}
+}
+
+func (s *resultStore) evictOldestLocked() {
+	var oldest string
+	for handle, r := range s.results {
+		if oldest == "" || r.expiresAt.Before(s.results[oldest].expiresAt) {
+			oldest = handle
+		}
+	}
+	delete(s.results, oldest)
+}
+
+// chunkBounds returns the offsets splitting payload into chunks of at most
+// size bytes, never cutting a UTF-8 character in two.
+func chunkBounds(payload []byte, size int) []int {
+	bounds := []int{0}
+	for start := 0; start < len(payload); {
+		end := start + size
+		if end >= len(payload) {
+			end = len(payload)
+		} else {
+			for end > start+1 && !utf8.RuneStart(payload[end]) {
+				end--
+			}
+		}
+		bounds = append(bounds, end)
+		start = end
+	}
+	return bounds
+}
+
+// FetchMore returns the chunk of a large result at cursor, as handed out in
+// the next_cursor of the previous chunk.
+func FetchMore(handle, cursor string) (ResultChunk, error) {
+	index, err := strconv.Atoi(cursor)
+	if err != nil {
+		return ResultChunk{}, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return results.chunk(handle, index)
+}
+
+// ToolResult returns the chunk as a tool result. It is returned as is rather
+// than through the size check of ConvertTool, escaping the JSON in Data would
+// push a full chunk over maxResponseSize and page it again.
+func (c ResultChunk) ToolResult() (*mcp.CallToolResult, error) {
+	chunkJSON, err := json.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal chunk: %w", err)
+	}
+	return mcp.NewToolResultText(string(chunkJSON)), nil
+}
+
+// largeResult stores a JSON result larger than maxResponseSize and returns
+// its first chunk, telling the client how to fetch the rest.
+func largeResult(jsonBytes []byte) (*mcp.CallToolResult, error) {
+	handle, err := results.put(jsonBytes, maxResponseSize)
+	if err != nil {
+		return nil, err
+	}
+	first, err := results.chunk(handle, 0)
+	if err != nil {
+		return nil, err
+	}
+	result, err := first.ToolResult()
+	if err != nil {
+		return nil, err
+	}
+
+	hint := fmt.Sprintf("The result is split into %d chunks. Call the fetch_more tool with result_handle %q "+
+		"and the next_cursor of each chunk to get the rest, then join the data fields in order.",
+		first.TotalChunks, handle)
+	result.Content = append(result.Content, mcp.NewTextContent(hint))
+	return result, nil
+}
@@ -0,0 +1,71 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type largeReq struct{}
+
+func TestConvertToolLargeResult(t *testing.T) {
+	// Multi byte characters straddle the chunk boundaries
+	rows := make([]string, 0, 60000)
+	for i := 0; i < 60000; i++ {
+		rows = append(rows, "₹ holding "+strings.Repeat("x", i%7))
+	}
+	_, handler, err := ConvertTool("large", "large", func(ctx context.Context, args largeReq) (any, error) {
+		return rows, nil
+	})
+	require.NoError(t, err)
+
+	result, err := handler(context.Background(), mcp.CallToolRequest{})
+	require.NoError(t, err)
+	require.Len(t, result.Content, 2)
+
+	var chunk ResultChunk
+	require.NoError(t, json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &chunk))
+	assert.Greater(t, chunk.TotalChunks, 1)
+
+	var data strings.Builder
+	data.WriteString(chunk.Data)
+	for chunk.NextCursor != "" {
+		assert.True(t, utf8.ValidString(chunk.Data))
+		chunk, err = FetchMore(chunk.ResultHandle, chunk.NextCursor)
+		require.NoError(t, err)
+		data.WriteString(chunk.Data)
+	}
+	assert.Equal(t, chunk.TotalChunks-1, chunk.ChunkIndex)
+
+	var got []string
+	require.NoError(t, json.Unmarshal([]byte(data.String()), &got))
+	assert.Equal(t, rows, got)
+}
+
+func TestFetchMoreErrors(t *testing.T) {
+	handle, err := results.put([]byte(`{"a":1}`), maxResponseSize)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		handle  string
+		cursor  string
+		wantErr string
+	}{
+		{name: "unknown handle", handle: "missing", cursor: "0", wantErr: ErrResultExpired.Error()},
+		{name: "invalid cursor", handle: handle, cursor: "next", wantErr: "invalid cursor"},
+		{name: "cursor out of range", handle: handle, cursor: "1", wantErr: "out of range"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := FetchMore(tt.handle, tt.cursor)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -47,43 +47,10 @@ func MustTool[T any, R any](name, description string, toolHandler ToolHandlerFun
 // ToolHandlerFunc is the type of a handler function for a tool.
 type ToolHandlerFunc[T any, R any] func(ctx context.Context, request T) (R, error)
 
-// maxResponseSize is the maximum size of a single response chunk in bytes
+// maxResponseSize is the maximum size of a single response chunk in bytes,
+// larger results are paged through with the fetch_more tool
 const maxResponseSize = 1024 * 1024 // 1MB
 
-// splitJSONResponse splits a large JSON response into chunks if needed
-func splitJSONResponse(jsonBytes []byte) ([]*mcp.CallToolResult, error) {
-	if len(jsonBytes) <= maxResponseSize {
-		return []*mcp.CallToolResult{mcp.NewToolResultText(string(jsonBytes))}, nil
-	}
-
-	// For large responses, we'll split into chunks
-	var chunks []*mcp.CallToolResult
-	totalChunks := (len(jsonBytes) + maxResponseSize - 1) / maxResponseSize
-
-	for i := 0; i < totalChunks; i++ {
-		start := i * maxResponseSize
-		end := start + maxResponseSize
-		if end > len(jsonBytes) {
-			end = len(jsonBytes)
-		}
-
-		chunk := map[string]interface{}{
-			"chunk_index":  i,
-			"total_chunks": totalChunks,
-			"data":         string(jsonBytes[start:end]),
-		}
-
-		chunkJSON, err := json.Marshal(chunk)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal chunk: %w", err)
-		}
-
-		chunks = append(chunks, mcp.NewToolResultText(string(chunkJSON)))
-	}
-
-	return chunks, nil
-}
-
 // ConvertTool converts a toolHandler function to a Tool and ToolHandlerFunc.
 //
 // The toolHandler function must have two arguments: a context.Context and a struct
@@ -206,14 +173,11 @@ func ConvertTool[T any, R any](name, description string, toolHandler ToolHandler
 			return nil, fmt.Errorf("failed to marshal return value: %s", err)
 		}
 
-		// Handle potential large responses
-		chunks, err := splitJSONResponse(jsonBytes)
-		if err != nil {
-			return nil, err
+		// Large responses are returned a chunk at a time, see FetchMore
+		if len(jsonBytes) > maxResponseSize {
+			return largeResult(jsonBytes)
 		}
-
-		// Return first chunk immediately, subsequent chunks will be sent automatically
-		return chunks[0], nil
+		return mcp.NewToolResultText(string(jsonBytes)), nil
 	}
 
 	jsonSchema := createJSONSchemaFromHandler(toolHandler)
@@ -0,0 +1,196 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/server"
+	mcp "github.com/wealthy/wealthy-mcp"
+	"github.com/wealthy/wealthy-mcp/internal"
+	"github.com/wealthy/wealthy-mcp/internal/alerts"
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+)
+
+var CreateAlertTool = mcp.MustTool(
+	"create_alert",
+	"Tool for setting a price alert on an instrument. The user is notified in chat when the last price goes above or below a level, crosses it, or moves by a percentage, or when a technical condition on prices and indicators holds at the close of a candle, such as rsi(14) < 30 or ema(9) crosses_above ema(21). An alert fires once",
+	createAlert,
+)
+
+var ListAlertsTool = mcp.MustTool(
+	"list_alerts",
+	"Tool for listing the price alerts, active and triggered",
+	listAlerts,
+)
+
+var ModifyAlertTool = mcp.MustTool(
+	"modify_alert",
+	"Tool for changing a price alert. The alert is armed again, so a triggered alert can fire once more",
+	modifyAlert,
+)
+
+var DeleteAlertTool = mcp.MustTool(
+	"delete_alert",
+	"Tool for deleting a price alert",
+	deleteAlert,
+)
+
+type CreateAlertReq struct {
+	ExchangeName   int     `json:"exchange_name" jsonschema:"required,description=Exchange name identifier\\, NSE=1\\, NFO=2\\, BSE=3\\, BFO=4"`
+	Token          string  `json:"token" jsonschema:"required,description=Token of the instrument\\, find it with the search tool"`
+	TradingSymbol  string  `json:"trading_symbol" jsonschema:"required,description=Trading symbol of the instrument\\, e.g. RELIANCE-EQ"`
+	Condition      string  `json:"condition" jsonschema:"required,enum=above,enum=below,enum=crossing,enum=pct_change,enum=technical,description=above or below fire when the last price is at or beyond price\\, crossing when it moves through price in either direction\\, pct_change when it has moved by percent from the reference price\\, technical when expression holds"`
+	Price          string  `json:"price,omitempty" jsonschema:"description=Price level in rupees for the above\\, below and crossing conditions"`
+	Percent        float64 `json:"percent,omitempty" jsonschema:"description=Change in percent for pct_change\\, positive for a rise and negative for a fall"`
+	ReferencePrice string  `json:"reference_price,omitempty" jsonschema:"description=Price in rupees pct_change measures from\\, the current price when omitted"`
+	Expression     string  `json:"expression,omitempty" jsonschema:"description=Condition of a technical alert: comparisons (<\\, <=\\, >\\, >=\\, crosses_above\\, crosses_below) of numbers\\, open\\, high\\, low\\, close\\, volume and the indicators sma\\, ema\\, rsi\\, macd\\, bollinger\\, atr\\, vwap and supertrend joined with and/or. The last argument may be the candle interval\\, e.g. rsi(14\\,5m) < 30\\, ema(9) crosses_above ema(21)\\, close > high(20d) for a breakout above the high of the last 20 days\\, macd.histogram > 0"`
+	Interval       string  `json:"interval,omitempty" jsonschema:"enum=1m,enum=5m,enum=15m,enum=1h,description=Candles a technical alert is evaluated on at every close\\, and of the operands without an interval\\, 5m by default"`
+	Note           string  `json:"note,omitempty" jsonschema:"description=Why the user wants the alert\\, repeated when it fires"`
+}
+
+type ListAlertsReq struct {
+	Status string `json:"status,omitempty" jsonschema:"enum=active,enum=triggered,description=Only list the alerts with this status\\, all alerts when omitted"`
+}
+
+type ModifyAlertReq struct {
+	AlertID        string  `json:"alert_id" jsonschema:"required,description=ID of the alert"`
+	Condition      string  `json:"condition,omitempty" jsonschema:"description=New condition: above\\, below\\, crossing\\, pct_change or technical"`
+	Price          string  `json:"price,omitempty" jsonschema:"description=New price level in rupees"`
+	Percent        float64 `json:"percent,omitempty" jsonschema:"description=New change in percent for pct_change"`
+	ReferencePrice string  `json:"reference_price,omitempty" jsonschema:"description=New price in rupees pct_change measures from"`
+	Expression     string  `json:"expression,omitempty" jsonschema:"description=New condition of a technical alert"`
+	Interval       string  `json:"interval,omitempty" jsonschema:"description=New candle interval of a technical alert: 1m\\, 5m\\, 15m or 1h"`
+	Note           string  `json:"note,omitempty" jsonschema:"description=New note"`
+}
+
+type DeleteAlertReq struct {
+	AlertID string `json:"alert_id" jsonschema:"required,description=ID of the alert"`
+}
+
+// checkAlertLogin refuses alerts to sessions with their own login. Alerts are
+// checked in the background with the server login.
+func checkAlertLogin(ctx context.Context) error {
+	if internal.AuthFromContext(ctx) != internal.Auth {
+		return errors.New("price alerts use the server login and are not available with per session logins")
+	}
+	return nil
+}
+
+func parsePrice(name, s string) (falcon.Money, error) {
+	p, err := falcon.ParseRupees(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", name, s, err)
+	}
+	return p, nil
+}
+
+func createAlert(ctx context.Context, args CreateAlertReq) (*alerts.Alert, error) {
+	if err := checkAlertLogin(ctx); err != nil {
+		return nil, err
+	}
+	price, err := parsePrice("price", args.Price)
+	if err != nil {
+		return nil, err
+	}
+	reference, err := parsePrice("reference_price", args.ReferencePrice)
+	if err != nil {
+		return nil, err
+	}
+	a, err := alerts.Current.Create(alerts.Alert{
+		ExchangeName:  args.ExchangeName,
+		Token:         args.Token,
+		TradingSymbol: args.TradingSymbol,
+		Condition:     alerts.Condition(args.Condition),
+		Price:         price,
+		Percent:       args.Percent,
+		Reference:     reference,
+		Expression:    args.Expression,
+		Interval:      falcon.CandleInterval(args.Interval),
+		Note:          args.Note,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func listAlerts(ctx context.Context, args ListAlertsReq) ([]alerts.Alert, error) {
+	if err := checkAlertLogin(ctx); err != nil {
+		return nil, err
+	}
+	list := alerts.Current.List()
+	if args.Status == "" {
+		return list, nil
+	}
+	filtered := []alerts.Alert{}
+	for _, a := range list {
+		if string(a.Status) == args.Status {
+			filtered = append(filtered, a)
+		}
+	}
+	return filtered, nil
+}
+
+func modifyAlert(ctx context.Context, args ModifyAlertReq) (*alerts.Alert, error) {
+	if err := checkAlertLogin(ctx); err != nil {
+		return nil, err
+	}
+	price, err := parsePrice("price", args.Price)
+	if err != nil {
+		return nil, err
+	}
+	reference, err := parsePrice("reference_price", args.ReferencePrice)
+	if err != nil {
+		return nil, err
+	}
+	a, err := alerts.Current.Modify(args.AlertID, func(a *alerts.Alert) {
+		if args.Condition != "" {
+			a.Condition = alerts.Condition(args.Condition)
+		}
+		if price != 0 {
+			a.Price = price
+		}
+		if args.Percent != 0 {
+			a.Percent = args.Percent
+		}
+		if reference != 0 {
+			a.Reference = reference
+		}
+		if args.Expression != "" {
+			a.Expression = args.Expression
+		}
+		if args.Interval != "" {
+			a.Interval = falcon.CandleInterval(args.Interval)
+		}
+		if args.Note != "" {
+			a.Note = args.Note
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func deleteAlert(ctx context.Context, args DeleteAlertReq) (string, error) {
+	if err := checkAlertLogin(ctx); err != nil {
+		return "", err
+	}
+	if err := alerts.Current.Delete(args.AlertID); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Alert %s deleted", args.AlertID), nil
+}
+
+func AddAlertTool(mcp *server.MCPServer) {
+	CreateAlertTool.Register(mcp)
+	ListAlertsTool.Register(mcp)
+	ModifyAlertTool.Register(mcp)
+	DeleteAlertTool.Register(mcp)
+}
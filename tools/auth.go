@@ -0,0 +1,59 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package tools
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mark3labs/mcp-go/server"
+	mcp "github.com/wealthy/wealthy-mcp"
+	"github.com/wealthy/wealthy-mcp/internal"
+)
+
+type SubmitAuthTokenReq struct {
+	AuthorizationToken string `json:"authorization_token" jsonschema:"required,description=authorization_token from the URL the Wealthy login page redirected to\\, or that whole URL"`
+}
+
+type GetLoginLinkReq struct {
+}
+
+type loginLinkResponse struct {
+	*internal.LoginLink
+	Instructions string `json:"instructions"`
+}
+
+func submitAuthToken(ctx context.Context, args SubmitAuthTokenReq) (any, error) {
+	if err := internal.CompleteLogin(ctx, args.AuthorizationToken); err != nil {
+		return nil, err
+	}
+	return "Login successful", nil
+}
+
+func getLoginLink(ctx context.Context, args GetLoginLinkReq) (any, error) {
+	link := internal.LoginLinkFor(ctx)
+	if link == nil {
+		return nil, errors.New("no login is pending, the user is already logged in")
+	}
+	return loginLinkResponse{LoginLink: link, Instructions: internal.LoginHint(ctx)}, nil
+}
+
+var SubmitAuthTokenTool = mcp.MustTool(
+	"submit_authorization_token",
+	"Tool for completing a pending Wealthy login with the authorization_token the user copied after logging in",
+	submitAuthToken,
+)
+
+var GetLoginLinkTool = mcp.MustTool(
+	"get_login_link",
+	"Tool for getting the Wealthy login link and code of a pending login, show it to the user",
+	getLoginLink,
+)
+
+func AddAuthTool(mcp *server.MCPServer) {
+	SubmitAuthTokenTool.Register(mcp)
+	GetLoginLinkTool.Register(mcp)
+}
@@ -0,0 +1,142 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+	mcp "github.com/wealthy/wealthy-mcp"
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+	"github.com/wealthy/wealthy-mcp/internal/indicators"
+	"github.com/wealthy/wealthy-mcp/internal/utils"
+)
+
+// resolveSymbol finds the instrument of a trading symbol or name through the
+// security search.
+func resolveSymbol(ctx context.Context, exchange int, symbol string) (*falcon.Instrument, error) {
+	query := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(symbol)), "-EQ")
+	if query == "" {
+		return nil, fmt.Errorf("symbol is required")
+	}
+	found, err := utils.FalconService.GetSecurityInfo(ctx, &falcon.SecurityInfoReq{Name: query})
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(found)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read search result: %w", err)
+	}
+	var result struct {
+		Stocks []falcon.Instrument `json:"stocks"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to read search result: %w", err)
+	}
+	var byName *falcon.Instrument
+	for i := range result.Stocks {
+		instr := &result.Stocks[i]
+		if instr.ExchangeName != exchange {
+			continue
+		}
+		if strings.TrimSuffix(strings.ToUpper(instr.TradingSymbol), "-EQ") == query {
+			return instr, nil
+		}
+		if byName == nil && strings.EqualFold(instr.Name, strings.TrimSpace(symbol)) {
+			byName = instr
+		}
+	}
+	if byName != nil {
+		return byName, nil
+	}
+	return nil, fmt.Errorf("%s not found on %s, check the trading symbol with the search tool", symbol, strings.ToUpper(falcon.ExchangeName(exchange)))
+}
+
+type GetIndicatorsArgs struct {
+	Symbol       string   `json:"symbol" jsonschema:"required,description=Trading symbol of the security\\, e.g. RELIANCE or INFY"`
+	ExchangeName int      `json:"exchange_name,omitempty" jsonschema:"description=Exchange name identifier\\, NSE=1\\, NFO=2\\, BSE=3\\, BFO=4\\, NSE by default"`
+	Interval     string   `json:"interval,omitempty" jsonschema:"enum=1m,enum=5m,enum=15m,enum=1h,enum=1d,description=Size of the candles\\, 1d by default"`
+	Indicators   []string `json:"indicators,omitempty" jsonschema:"description=Indicators to compute: sma\\, ema\\, rsi\\, macd\\, bollinger\\, atr\\, vwap and supertrend\\, all by default. Parameters go in brackets\\, e.g. sma(50)\\, rsi(7)\\, macd(12\\,26\\,9)\\, bollinger(20\\,2.5)"`
+}
+
+// IndicatorReadings is the result of get_indicators.
+type IndicatorReadings struct {
+	TradingSymbol string               `json:"trading_symbol"`
+	ExchangeName  int                  `json:"exchange_name"`
+	Token         string               `json:"token"`
+	Interval      string               `json:"interval"`
+	Time          time.Time            `json:"time"`
+	Close         float64              `json:"close"`
+	Candles       int                  `json:"candles"`
+	Readings      []indicators.Reading `json:"readings"`
+	Errors        []string             `json:"errors,omitempty"`
+}
+
+func getIndicators(ctx context.Context, args GetIndicatorsArgs) (*IndicatorReadings, error) {
+	if args.ExchangeName == 0 {
+		args.ExchangeName = falcon.ExchangeNSE
+	}
+	if args.Interval == "" {
+		args.Interval = string(falcon.Interval1Day)
+	}
+	interval, err := falcon.ParseCandleInterval(args.Interval)
+	if err != nil {
+		return nil, err
+	}
+	specs := indicators.Defaults()
+	if len(args.Indicators) > 0 {
+		specs = specs[:0]
+		for _, s := range args.Indicators {
+			spec, err := indicators.ParseSpec(s)
+			if err != nil {
+				return nil, err
+			}
+			specs = append(specs, spec)
+		}
+	}
+
+	instr, err := resolveSymbol(ctx, args.ExchangeName, args.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	bars, err := indicators.Load(ctx, utils.FalconService, utils.Stream.Candles(), instr.ExchangeName, instr.Token, interval, time.Now().In(falcon.IST))
+	if err != nil {
+		return nil, err
+	}
+	if len(bars) == 0 {
+		return nil, fmt.Errorf("no %s candles of %s, the instrument may not have traded recently", interval, instr.TradingSymbol)
+	}
+
+	last := bars[len(bars)-1]
+	result := &IndicatorReadings{
+		TradingSymbol: instr.TradingSymbol,
+		ExchangeName:  instr.ExchangeName,
+		Token:         instr.Token,
+		Interval:      string(interval),
+		Time:          last.Time,
+		Close:         last.Close,
+		Candles:       len(bars),
+		Readings:      []indicators.Reading{},
+	}
+	for _, spec := range specs {
+		reading, err := indicators.Evaluate(bars, spec)
+		if err != nil {
+			result.Errors = append(result.Errors, err.Error())
+			continue
+		}
+		result.Readings = append(result.Readings, reading)
+	}
+	return result, nil
+}
+
+var indicatorsTool = mcp.MustTool(
+	"get_indicators",
+	"Get technical indicators of a stock (SMA, EMA, RSI, MACD, Bollinger Bands, ATR, VWAP, supertrend) at the latest candle of an interval, each with a bullish, bearish, overbought, oversold or neutral signal and a short interpretation. Use it for technical analysis; it is not investment advice",
+	getIndicators,
+)
+
+func AddIndicatorsTool(mcp *server.MCPServer) {
+	indicatorsTool.Register(mcp)
+}
@@ -0,0 +1,34 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package tools
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/server"
+	mcp "github.com/wealthy/wealthy-mcp"
+	"github.com/wealthy/wealthy-mcp/internal/risk"
+	"github.com/wealthy/wealthy-mcp/internal/utils"
+)
+
+var KillSwitchTool = mcp.MustTool(
+	"kill_switch",
+	"Tool for stopping all trading at once: cancels every open order and refuses further orders until the user re-arms the server. Use it when the user asks to stop trading or something has gone wrong",
+	killSwitch,
+)
+
+type KillSwitchReq struct {
+	Reason    string `json:"reason,omitempty" jsonschema:"description=Why trading is stopped"`
+	SquareOff bool   `json:"square_off,omitempty" jsonschema:"description=Also close the intraday (MIS) positions with market orders"`
+}
+
+func killSwitch(ctx context.Context, args KillSwitchReq) (*risk.KillReport, error) {
+	return risk.Switch.Engage(ctx, utils.FalconService, args.Reason, args.SquareOff), nil
+}
+
+func AddKillSwitchTool(mcp *server.MCPServer) {
+	KillSwitchTool.Register(mcp)
+}
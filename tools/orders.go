@@ -6,41 +6,126 @@ import (
 	"github.com/mark3labs/mcp-go/server"
 	mcp "github.com/wealthy/wealthy-mcp"
 	"github.com/wealthy/wealthy-mcp/internal/falcon"
+	"github.com/wealthy/wealthy-mcp/internal/orders"
+	"github.com/wealthy/wealthy-mcp/internal/risk"
 	"github.com/wealthy/wealthy-mcp/internal/utils"
 )
 
-func placeOrder(ctx context.Context, args falcon.OrderReq) (any, error) {
-	return utils.FalconService.PlaceOrder(ctx, []falcon.OrderReq{args})
+// OrderConfirmation holds the preview options shared by the order tools.
+type OrderConfirmation struct {
+	DryRun            bool   `json:"dry_run,omitempty" jsonschema:"description=Only preview the order\\, nothing is sent to the exchange. Show the preview to the user and repeat the call with its confirmation_token once they agree"`
+	ConfirmationToken string `json:"confirmation_token,omitempty" jsonschema:"description=confirmation_token of the preview\\, the order must be exactly the previewed one"`
 }
 
-func modifyOrder(ctx context.Context, args falcon.ModifyOrderReq) (any, error) {
-	return utils.FalconService.ModifyOrder(ctx, args)
+// preview reports whether the call only previews the order. In dry run mode
+// every call without a confirmation token is a preview.
+func (c OrderConfirmation) preview() bool {
+	return c.DryRun || (orders.DryRun && c.ConfirmationToken == "")
 }
 
-func cancelOrder(ctx context.Context, args falcon.CancelOrderReq) (any, error) {
-	return utils.FalconService.CancelOrder(ctx, args)
+type PlaceOrderReq struct {
+	falcon.OrderReq
+	OrderConfirmation
+}
+
+type ModifyOrderReq struct {
+	falcon.ModifyOrderReq
+	OrderConfirmation
+}
+
+type CancelOrderReq struct {
+	falcon.CancelOrderReq
+	OrderConfirmation
+}
+
+type PlaceBasketOrderReq struct {
+	falcon.BasketOrderReq
+	Rollback bool `json:"rollback,omitempty" jsonschema:"description=Cancel the legs that were placed when any leg fails\\, so the basket is placed in full or not at all"`
+	OrderConfirmation
+}
+
+func placeOrder(ctx context.Context, args PlaceOrderReq) (any, error) {
+	if err := risk.CheckPlace(ctx, utils.FalconService, args.OrderReq); err != nil {
+		return nil, err
+	}
+	if args.preview() {
+		return orders.PreviewPlace(ctx, utils.FalconService, args.OrderReq)
+	}
+	if err := orders.Confirm(ctx, orders.ActionPlace, args.OrderReq, args.ConfirmationToken); err != nil {
+		return nil, err
+	}
+	// Notifies the clients when the order fills or is rejected
+	utils.FollowOrders(ctx)
+	return utils.FalconService.PlaceOrder(ctx, []falcon.OrderReq{args.OrderReq})
+}
+
+func modifyOrder(ctx context.Context, args ModifyOrderReq) (any, error) {
+	if err := risk.CheckModify(ctx, utils.FalconService, args.ModifyOrderReq); err != nil {
+		return nil, err
+	}
+	if args.preview() {
+		return orders.PreviewModify(ctx, utils.FalconService, args.ModifyOrderReq)
+	}
+	if err := orders.Confirm(ctx, orders.ActionModify, args.ModifyOrderReq, args.ConfirmationToken); err != nil {
+		return nil, err
+	}
+	// Notifies the clients when the order fills or is rejected
+	utils.FollowOrders(ctx)
+	return utils.FalconService.ModifyOrder(ctx, args.ModifyOrderReq)
+}
+
+func cancelOrder(ctx context.Context, args CancelOrderReq) (any, error) {
+	if args.preview() {
+		return orders.PreviewCancel(ctx, utils.FalconService, args.CancelOrderReq)
+	}
+	if err := orders.Confirm(ctx, orders.ActionCancel, args.CancelOrderReq, args.ConfirmationToken); err != nil {
+		return nil, err
+	}
+	return utils.FalconService.CancelOrder(ctx, args.CancelOrderReq)
+}
+
+func placeBasketOrder(ctx context.Context, args PlaceBasketOrderReq) (any, error) {
+	if err := risk.CheckBasket(ctx, utils.FalconService, args.Orders); err != nil {
+		return nil, err
+	}
+	if args.preview() {
+		return orders.PreviewBasket(ctx, utils.FalconService, args.BasketOrderReq)
+	}
+	if err := orders.Confirm(ctx, orders.ActionBasket, args.BasketOrderReq, args.ConfirmationToken); err != nil {
+		return nil, err
+	}
+	// Notifies the clients when the order fills or is rejected
+	utils.FollowOrders(ctx)
+	return orders.PlaceBasket(ctx, utils.FalconService, args.Orders, args.Rollback)
 }
 
 func AddOrderTool(mcp *server.MCPServer) {
 	PlaceOrderTool.Register(mcp)
 	ModifyOrderTool.Register(mcp)
 	CancelOrderTool.Register(mcp)
+	PlaceBasketOrderTool.Register(mcp)
 }
 
 var PlaceOrderTool = mcp.MustTool(
 	"place_order",
-	"Tool for placing buy/sell order",
+	"Tool for placing buy/sell order, set dry_run to preview it first",
 	placeOrder,
 )
 
 var ModifyOrderTool = mcp.MustTool(
 	"modify_order",
-	"Tool for modifying an order",
+	"Tool for modifying an order, set dry_run to preview the changes first",
 	modifyOrder,
 )
 
 var CancelOrderTool = mcp.MustTool(
 	"cancel_order",
-	"Tool for cancelling an order",
+	"Tool for cancelling an order, set dry_run to preview it first",
 	cancelOrder,
 )
+
+var PlaceBasketOrderTool = mcp.MustTool(
+	"place_basket_order",
+	"Tool for placing several orders at once, e.g. the legs of an F&O strategy. Set rollback to cancel the placed legs when any leg fails, and dry_run to preview the basket first",
+	placeBasketOrder,
+)
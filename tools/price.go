@@ -2,18 +2,102 @@ package tools
 
 import (
 	"context"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/mark3labs/mcp-go/server"
 	mcp "github.com/wealthy/wealthy-mcp"
 	"github.com/wealthy/wealthy-mcp/internal/falcon"
 	"github.com/wealthy/wealthy-mcp/internal/utils"
+	"github.com/wealthy/wealthy-mcp/internal/websocket"
 )
 
+const (
+	// liveQuoteWait is how long get_live_quote waits for the first tick of a
+	// new subscription.
+	liveQuoteWait = 5 * time.Second
+	// liveQuoteHold is how long an instrument stays streamed after the last
+	// get_live_quote or get_intraday_candles call for it.
+	liveQuoteHold = 30 * time.Minute
+)
+
+// quoteHolds keeps the instruments get_live_quote and get_intraday_candles
+// subscribed, so later calls are served from the stream. An instrument not
+// asked for in ttl is released.
+type quoteHolds struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	holds map[websocket.Instrument]*quoteHold
+	now   func() time.Time
+}
+
+type quoteHold struct {
+	sub      *websocket.Subscription
+	lastUsed time.Time
+	timer    *time.Timer
+}
+
+func newQuoteHolds(ttl time.Duration) *quoteHolds {
+	return &quoteHolds{ttl: ttl, holds: make(map[websocket.Instrument]*quoteHold), now: time.Now}
+}
+
+var liveQuotes = newQuoteHolds(liveQuoteHold)
+
+// hold keeps an instrument streamed in the Full mode for another ttl.
+func (h *quoteHolds) hold(stream *websocket.StreamManager, instr websocket.Instrument) error {
+	if h.touch(instr) {
+		return nil
+	}
+	sub, err := stream.Subscribe(websocket.ModeFull, instr)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if held, ok := h.holds[instr]; ok {
+		held.lastUsed = h.now()
+		sub.Close()
+		return nil
+	}
+	held := &quoteHold{sub: sub, lastUsed: h.now()}
+	held.timer = time.AfterFunc(h.ttl, func() { h.expire(instr, held) })
+	h.holds[instr] = held
+	return nil
+}
+
+// touch marks a held instrument used, reporting whether it is held.
+func (h *quoteHolds) touch(instr websocket.Instrument) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	held, ok := h.holds[instr]
+	if ok {
+		held.lastUsed = h.now()
+	}
+	return ok
+}
+
+// expire releases a hold unless it was used since its timer was set.
+func (h *quoteHolds) expire(instr websocket.Instrument, held *quoteHold) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.holds[instr] != held {
+		return
+	}
+	if left := h.ttl - h.now().Sub(held.lastUsed); left > 0 {
+		held.timer.Reset(left)
+		return
+	}
+	delete(h.holds, instr)
+	held.sub.Close()
+}
+
 type GetPriceArgs struct {
 	Symbols []string `json:"symbols" jsonschema:"description=Symbol of the stock, add -EQ in the end for trading symbol if already not present, correct format: exchange:trading_symbol, nse:RELIANCE-EQ, bse:RELIANCE-EQ, nse:INFY-EQ, bse:INFY, nfo:RELIANCE29MAY25F, 1-nse, 2-nfo, 3-bse, 4-bfo"`
 }
 
-func getPrice(ctx context.Context, args GetPriceArgs) (any, error) {
+func getPrice(ctx context.Context, args GetPriceArgs) (falcon.Quotes, error) {
 	return utils.FalconService.GetPrice(ctx, falcon.MakePriceReq(args.Symbols))
 }
 
@@ -23,6 +107,197 @@ var priceTool = mcp.MustTool(
 	getPrice,
 )
 
+type GetLiveQuoteArgs struct {
+	ExchangeName int    `json:"exchange_name" jsonschema:"required,description=Exchange name identifier\\, NSE=1\\, NFO=2\\, BSE=3\\, BFO=4"`
+	Token        string `json:"token" jsonschema:"required,description=Token of the instrument\\, find it with the search tool"`
+}
+
+func getLiveQuote(ctx context.Context, args GetLiveQuoteArgs) (*websocket.Tick, error) {
+	if err := utils.StartStream(ctx); err != nil {
+		return nil, err
+	}
+	if err := liveQuotes.hold(utils.Stream, websocket.Instrument{Exchange: args.ExchangeName, Token: args.Token}); err != nil {
+		return nil, err
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, liveQuoteWait)
+	defer cancel()
+	tick, err := utils.Stream.GetLTP(waitCtx, args.ExchangeName, args.Token)
+	if err != nil {
+		if state, streamErr := utils.Stream.State(); streamErr != nil {
+			return nil, fmt.Errorf("%w, the price stream is %s: %v", err, state, streamErr)
+		}
+		return nil, fmt.Errorf("%w, the market may be closed, use get_price instead", err)
+	}
+	return &tick, nil
+}
+
+var liveQuoteTool = mcp.MustTool(
+	"get_live_quote",
+	"Get the live price of an instrument from the streaming feed, with OHLC, volume and circuits. Faster and fresher than get_price for repeated checks",
+	getLiveQuote,
+)
+
+type GetMarketDepthArgs struct {
+	ExchangeName int    `json:"exchange_name" jsonschema:"required,description=Exchange name identifier\\, NSE=1\\, NFO=2\\, BSE=3\\, BFO=4"`
+	Token        string `json:"token" jsonschema:"required,description=Token of the instrument\\, find it with the search tool"`
+}
+
+func getMarketDepth(ctx context.Context, args GetMarketDepthArgs) (*websocket.Book, error) {
+	if err := utils.StartStream(ctx); err != nil {
+		return nil, err
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, liveQuoteWait)
+	defer cancel()
+	book, err := utils.Stream.GetDepth(waitCtx, args.ExchangeName, args.Token)
+	if err != nil {
+		if state, streamErr := utils.Stream.State(); streamErr != nil {
+			return nil, fmt.Errorf("%w, the price stream is %s: %v", err, state, streamErr)
+		}
+		return nil, fmt.Errorf("%w, the market may be closed", err)
+	}
+	return book, nil
+}
+
+var marketDepthTool = mcp.MustTool(
+	"get_market_depth",
+	"Get the order book of an instrument: the best 5 bid and ask levels, spread, mid price and the imbalance between buy and sell quantities. Use it to judge liquidity before placing a limit order",
+	getMarketDepth,
+)
+
+type GetIntradayCandlesArgs struct {
+	ExchangeName int    `json:"exchange_name" jsonschema:"required,description=Exchange name identifier\\, NSE=1\\, NFO=2\\, BSE=3\\, BFO=4"`
+	Token        string `json:"token" jsonschema:"required,description=Token of the instrument\\, find it with the search tool"`
+	Interval     string `json:"interval,omitempty" jsonschema:"enum=1m,enum=5m,enum=15m,enum=1h,description=Size of the candles\\, 5m by default"`
+	Count        int    `json:"count,omitempty" jsonschema:"description=Number of most recent candles to return\\, 50 by default"`
+}
+
+// IntradayCandles is the result of get_intraday_candles.
+type IntradayCandles struct {
+	ExchangeName int                `json:"exchange_name"`
+	Token        string             `json:"token"`
+	Interval     string             `json:"interval"`
+	Candles      []websocket.Candle `json:"candles"`
+	Note         string             `json:"note"`
+}
+
+func getIntradayCandles(ctx context.Context, args GetIntradayCandlesArgs) (*IntradayCandles, error) {
+	if args.Interval == "" {
+		args.Interval = "5m"
+	}
+	if args.Count <= 0 {
+		args.Count = 50
+	}
+	size, err := websocket.ParseInterval(args.Interval)
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.StartStream(ctx); err != nil {
+		return nil, err
+	}
+	if err := liveQuotes.hold(utils.Stream, websocket.Instrument{Exchange: args.ExchangeName, Token: args.Token}); err != nil {
+		return nil, err
+	}
+	result := &IntradayCandles{
+		ExchangeName: args.ExchangeName,
+		Token:        args.Token,
+		Interval:     args.Interval,
+		Candles:      utils.Stream.Candles().Get(args.ExchangeName, args.Token, size, args.Count),
+		Note:         "Candles are built from the live ticks received since the server started streaming the instrument, the last one is still forming",
+	}
+	if len(result.Candles) == 0 {
+		result.Note = "The instrument is streamed from now on, candles build up as ticks arrive during market hours. Ask again in a few minutes"
+	}
+	return result, nil
+}
+
+var intradayCandlesTool = mcp.MustTool(
+	"get_intraday_candles",
+	"Get intraday OHLC candles of an instrument (1m, 5m, 15m or 1h) built from the live tick stream, with volume. Use it to describe the intraday trend. The first call starts streaming the instrument, so candles only cover the time since then",
+	getIntradayCandles,
+)
+
+type GetHistoricalPricesArgs struct {
+	ExchangeName int    `json:"exchange_name" jsonschema:"required,description=Exchange name identifier\\, NSE=1\\, NFO=2\\, BSE=3\\, BFO=4"`
+	Token        string `json:"token" jsonschema:"required,description=Token of the instrument\\, find it with the search tool"`
+	Interval     string `json:"interval,omitempty" jsonschema:"enum=1m,enum=5m,enum=15m,enum=1h,enum=1d,description=Size of the candles\\, 1d by default"`
+	From         string `json:"from,omitempty" jsonschema:"description=Start date as YYYY-MM-DD or an RFC 3339 time\\, 30 days before to by default"`
+	To           string `json:"to,omitempty" jsonschema:"description=End date as YYYY-MM-DD or an RFC 3339 time\\, now by default"`
+	FillHolidays bool   `json:"fill_holidays,omitempty" jsonschema:"description=For daily candles\\, add a flat candle at the previous close for weekdays the exchange was closed"`
+}
+
+// HistoricalPrices is the result of get_historical_prices.
+type HistoricalPrices struct {
+	ExchangeName int            `json:"exchange_name"`
+	Token        string         `json:"token"`
+	Interval     string         `json:"interval"`
+	From         time.Time      `json:"from"`
+	To           time.Time      `json:"to"`
+	Candles      falcon.Candles `json:"candles"`
+}
+
+// parseDate reads a date of the market's time zone or an RFC 3339 time. A
+// date ends at its last moment when end is set.
+func parseDate(s string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, s, falcon.IST)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, use YYYY-MM-DD or an RFC 3339 time", s)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1).Add(-time.Second)
+	}
+	return t, nil
+}
+
+func getHistoricalPrices(ctx context.Context, args GetHistoricalPricesArgs) (*HistoricalPrices, error) {
+	if args.Interval == "" {
+		args.Interval = string(falcon.Interval1Day)
+	}
+	interval, err := falcon.ParseCandleInterval(args.Interval)
+	if err != nil {
+		return nil, err
+	}
+	to := time.Now().In(falcon.IST)
+	if args.To != "" {
+		if to, err = parseDate(args.To, true); err != nil {
+			return nil, err
+		}
+	}
+	from := to.AddDate(0, 0, -30)
+	if args.From != "" {
+		if from, err = parseDate(args.From, false); err != nil {
+			return nil, err
+		}
+	}
+	candles, err := utils.FalconService.GetHistoricalCandles(ctx, args.ExchangeName, args.Token, interval, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if args.FillHolidays && interval == falcon.Interval1Day {
+		candles = falcon.FillHolidays(candles)
+	}
+	return &HistoricalPrices{
+		ExchangeName: args.ExchangeName,
+		Token:        args.Token,
+		Interval:     args.Interval,
+		From:         from,
+		To:           to,
+		Candles:      candles,
+	}, nil
+}
+
+var historicalPricesTool = mcp.MustTool(
+	"get_historical_prices",
+	"Get historical OHLC candles of an instrument for a date range, daily (1d) or intraday (1m, 5m, 15m, 1h), with volume. Use it for past performance, returns over a period or the last close when the live price is unavailable",
+	getHistoricalPrices,
+)
+
 func AddPriceTool(mcp *server.MCPServer) {
 	priceTool.Register(mcp)
+	liveQuoteTool.Register(mcp)
+	marketDepthTool.Register(mcp)
+	intradayCandlesTool.Register(mcp)
+	historicalPricesTool.Register(mcp)
 }
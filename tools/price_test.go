@@ -0,0 +1,54 @@
+package tools
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
+	"github.com/wealthy/wealthy-mcp/internal/websocket"
+)
+
+type urlSource string
+
+func (u urlSource) GetWebsocketURL(ctx context.Context) (string, error) {
+	return string(u), nil
+}
+
+func TestQuoteHoldsExpire(t *testing.T) {
+	stream := websocket.NewStreamManager(urlSource("ws://127.0.0.1:1"))
+	defer stream.Stop()
+	// The timers never fire during the test, expiry is driven by hand
+	holds := newQuoteHolds(time.Hour)
+	now := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	holds.now = func() time.Time { return now }
+	infy := websocket.Instrument{Exchange: falcon.ExchangeNSE, Token: "1594"}
+	tcs := websocket.Instrument{Exchange: falcon.ExchangeNSE, Token: "11536"}
+	expire := func() {
+		for _, instr := range []websocket.Instrument{infy, tcs} {
+			holds.mu.Lock()
+			held := holds.holds[instr]
+			holds.mu.Unlock()
+			if held != nil {
+				holds.expire(instr, held)
+			}
+		}
+	}
+
+	require.NoError(t, holds.hold(stream, infy))
+	require.NoError(t, holds.hold(stream, tcs))
+	require.NoError(t, holds.hold(stream, infy))
+	assert.ElementsMatch(t, []websocket.Instrument{infy, tcs}, stream.Subscriptions()[websocket.ModeFull], "an instrument is held once")
+
+	now = now.Add(40 * time.Minute)
+	require.NoError(t, holds.hold(stream, infy))
+	now = now.Add(30 * time.Minute)
+	expire()
+	assert.Equal(t, []websocket.Instrument{infy}, stream.Subscriptions()[websocket.ModeFull], "using infy keeps it held past the expiry of tcs")
+
+	now = now.Add(30 * time.Minute)
+	expire()
+	assert.Empty(t, stream.Subscriptions(), "an idle instrument is released")
+}
@@ -5,13 +5,14 @@ import (
 
 	"github.com/mark3labs/mcp-go/server"
 	mcp "github.com/wealthy/wealthy-mcp"
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
 	"github.com/wealthy/wealthy-mcp/internal/utils"
 )
 
 type ResearchReq struct {
 }
 
-func getResearch(ctx context.Context, args ResearchReq) (any, error) {
+func getResearch(ctx context.Context, args ResearchReq) (falcon.TradeIdeas, error) {
 	return utils.FalconService.GetTradeIdeas(ctx)
 }
 
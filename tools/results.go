@@ -0,0 +1,36 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package tools
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/server"
+	mcp "github.com/wealthy/wealthy-mcp"
+)
+
+type FetchMoreReq struct {
+	ResultHandle string `json:"result_handle" jsonschema:"required,description=result_handle of the chunked result"`
+	Cursor       string `json:"cursor" jsonschema:"required,description=next_cursor of the previous chunk"`
+}
+
+func fetchMore(ctx context.Context, args FetchMoreReq) (any, error) {
+	chunk, err := mcp.FetchMore(args.ResultHandle, args.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	return chunk.ToolResult()
+}
+
+var FetchMoreTool = mcp.MustTool(
+	"fetch_more",
+	"Tool for fetching the next chunk of a tool result that was too large for a single response",
+	fetchMore,
+)
+
+func AddResultsTool(mcp *server.MCPServer) {
+	FetchMoreTool.Register(mcp)
+}
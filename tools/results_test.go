@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	wmcp "github.com/wealthy/wealthy-mcp"
+)
+
+type largeReq struct{}
+
+func TestFetchMoreToolReturnsRequestedChunk(t *testing.T) {
+	// <, > and & are escaped to six bytes each, so a full chunk grows well
+	// past the response size once it is marshaled again
+	rows := make([]string, 0, 200000)
+	for i := 0; i < 200000; i++ {
+		rows = append(rows, "<holding & "+strings.Repeat(">", i%5))
+	}
+	_, handler, err := wmcp.ConvertTool("large", "large", func(ctx context.Context, args largeReq) (any, error) {
+		return rows, nil
+	})
+	require.NoError(t, err)
+
+	result, err := handler(context.Background(), mcp.CallToolRequest{})
+	require.NoError(t, err)
+	var chunk wmcp.ResultChunk
+	require.NoError(t, json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &chunk))
+	require.Greater(t, chunk.TotalChunks, 2)
+	handle, total := chunk.ResultHandle, chunk.TotalChunks
+
+	var data strings.Builder
+	data.WriteString(chunk.Data)
+	for chunk.NextCursor != "" {
+		var req mcp.CallToolRequest
+		req.Params.Arguments = map[string]any{"result_handle": handle, "cursor": chunk.NextCursor}
+		next := chunk.ChunkIndex + 1
+
+		result, err := FetchMoreTool.Handler(context.Background(), req)
+		require.NoError(t, err)
+		require.Len(t, result.Content, 1)
+		chunk = wmcp.ResultChunk{}
+		require.NoError(t, json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &chunk))
+		assert.Equal(t, handle, chunk.ResultHandle)
+		assert.Equal(t, next, chunk.ChunkIndex)
+		assert.Equal(t, total, chunk.TotalChunks)
+		data.WriteString(chunk.Data)
+	}
+
+	var got []string
+	require.NoError(t, json.Unmarshal([]byte(data.String()), &got))
+	assert.Equal(t, rows, got)
+}
@@ -0,0 +1,32 @@
+// Copyright (c) 2024 Wealthy
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package tools
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/server"
+	mcp "github.com/wealthy/wealthy-mcp"
+	"github.com/wealthy/wealthy-mcp/internal/risk"
+	"github.com/wealthy/wealthy-mcp/internal/utils"
+)
+
+var GetRiskLimitsTool = mcp.MustTool(
+	"get_risk_limits",
+	"Tool for getting the risk limits orders are checked against, with the open orders, turnover and profit or loss of the day",
+	getRiskLimits,
+)
+
+type GetRiskLimitsReq struct {
+}
+
+func getRiskLimits(ctx context.Context, args GetRiskLimitsReq) (*risk.Status, error) {
+	return risk.CurrentStatus(ctx, utils.FalconService), nil
+}
+
+func AddRiskTool(mcp *server.MCPServer) {
+	GetRiskLimitsTool.Register(mcp)
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/mark3labs/mcp-go/server"
 	mcp "github.com/wealthy/wealthy-mcp"
+	"github.com/wealthy/wealthy-mcp/internal/falcon"
 	"github.com/wealthy/wealthy-mcp/internal/utils"
 )
 
@@ -22,7 +23,7 @@ var GetUserMarginTool = mcp.MustTool(
 type GetUserMarginReq struct {
 }
 
-func getUserMargin(ctx context.Context, args GetUserMarginReq) (any, error) {
+func getUserMargin(ctx context.Context, args GetUserMarginReq) (*falcon.Margin, error) {
 	return utils.FalconService.GetUserMargin(ctx)
 }
 
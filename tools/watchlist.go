@@ -6,6 +6,8 @@ import (
 	"github.com/mark3labs/mcp-go/server"
 	mcp "github.com/wealthy/wealthy-mcp"
 	"github.com/wealthy/wealthy-mcp/internal/falcon"
+	"github.com/wealthy/wealthy-mcp/internal/notify"
+	"github.com/wealthy/wealthy-mcp/internal/resources"
 	"github.com/wealthy/wealthy-mcp/internal/utils"
 )
 
@@ -42,7 +44,12 @@ func UpdateWatchlist(mcp *server.MCPServer) {
 }
 
 func updateWatchlist(ctx context.Context, args falcon.WatchlistReq) (any, error) {
-	return utils.FalconService.AddToWatchlist(ctx, &args)
+	resp, err := utils.FalconService.AddToWatchlist(ctx, &args)
+	if err != nil {
+		return nil, err
+	}
+	notify.Clients.ResourceUpdated(resources.WatchlistURI(args.Name))
+	return resp, nil
 }
 
 func getWatchlist(ctx context.Context, req getWatchlistReq) (any, error) {